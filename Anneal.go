@@ -0,0 +1,305 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// =============================================================================
+// struct AnnealSchedule
+// brief description: This is the cooling schedule of Anneal. The temperature
+//	starts at InitialTemperature, and is multiplied by CoolingFactor after
+//	every MovesPerTemperature proposed moves, until it is below
+//	StopTemperature.
+type AnnealSchedule struct {
+	// InitialTemperature is the first temperature, must be > 0. It is in the
+	// unit of the quality model: a move losing InitialTemperature of quality
+	// is accepted with probability 1/e at first. A few times the typical
+	// DeltaQuality of a single node move is a good start: hotter starts only
+	// spend moves on random partitions.
+	InitialTemperature float64
+
+	// CoolingFactor is the factor of the geometric cooling, must be in (0, 1).
+	CoolingFactor float64
+
+	// MovesPerTemperature is the number of proposed moves at each
+	// temperature, must be > 0.
+	MovesPerTemperature int
+
+	// StopTemperature is the temperature at which the annealing stops, must
+	// be > 0.
+	StopTemperature float64
+}
+
+// the probability that a proposed move is a merge or a split of communities
+// instead of a single node move
+const annealCommunityMoveProbability = 0.1
+
+// the maximum number of iterations on each level of the multi-level Louvain
+// that gives the initial partition of Anneal when none is given
+const annealLouvainMaxIters = 100
+
+// =============================================================================
+// struct annealState
+// brief description: This is the current partition of Anneal, with the
+//	community of each node and the communities left empty by moves.
+type annealState struct {
+	qm           QualityModel
+	communities  []map[int]bool
+	communityIDs []int
+
+	// the IDs of communities that became empty. It may hold IDs of communities
+	// that are not empty anymore, which getEmpty skips.
+	empties []int
+}
+
+// =============================================================================
+// func (s *annealState) move
+// brief description: move a node into a community
+// input:
+//	u: a node ID
+//	newCu: the ID of the community u moves in
+func (s *annealState) move(u, newCu int) {
+	oldCu := s.communityIDs[u]
+	delete(s.communities[oldCu], u)
+	s.communities[newCu][u] = true
+	s.communityIDs[u] = newCu
+	if len(s.communities[oldCu]) == 0 {
+		s.empties = append(s.empties, oldCu)
+	}
+}
+
+// =============================================================================
+// func (s *annealState) getEmpty
+// brief description: get the ID of an empty community, adding one if none is
+//	left
+func (s *annealState) getEmpty() int {
+	for len(s.empties) > 0 {
+		idxC := s.empties[len(s.empties)-1]
+		if len(s.communities[idxC]) == 0 {
+			return idxC
+		}
+		s.empties = s.empties[:len(s.empties)-1]
+	}
+	s.communities = append(s.communities, map[int]bool{})
+	s.empties = append(s.empties, len(s.communities)-1)
+	return len(s.communities) - 1
+}
+
+// =============================================================================
+// func (s *annealState) moveAll
+// brief description: move nodes one by one into a community, summing the
+//	quality changes of the moves
+// input:
+//	nodes: the node IDs, none of them in newCu
+//	newCu: the ID of the community they move in
+// output:
+//	output 1: the total change of quality
+//	output 2: the communities the nodes came from, to revert the moves
+func (s *annealState) moveAll(nodes []int, newCu int) (float64, []int) {
+	delta := 0.0
+	oldCus := make([]int, len(nodes))
+	for i, u := range nodes {
+		oldCus[i] = s.communityIDs[u]
+		delta += s.qm.DeltaQuality(s.communities, u, oldCus[i], newCu)
+		s.move(u, newCu)
+	}
+	return delta, oldCus
+}
+
+// =============================================================================
+// func (s *annealState) revertAll
+// brief description: revert the moves of moveAll, in reverse order
+func (s *annealState) revertAll(nodes []int, oldCus []int) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		s.move(nodes[i], oldCus[i])
+	}
+}
+
+// =============================================================================
+// func getSortedMembers
+// brief description: get the members of a community in increasing order, so
+//	that the moves depend on rng only
+func getSortedMembers(c map[int]bool) []int {
+	result := make([]int, 0, len(c))
+	for u, _ := range c {
+		result = append(result, u)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// =============================================================================
+// func Anneal
+// brief description: optimize a quality model by simulated annealing, which
+//	can leave the local optima Louvain gets stuck in, at a much higher cost.
+// input:
+//	qm: a quality model.
+//	communities: the initial partition of the nodes 0..n-1. If it is nil,
+//		the coarsest level of the multi-level Louvain is used, with a seed
+//		drawn from rng. It is only read.
+//	schedule: the cooling schedule.
+//	rng: the source of randomness. It must not be nil.
+// output:
+//	output 1: the best partition seen, not the last one, in canonical order.
+//		So it is never worse than the initial partition, and by default never
+//		worse than Louvain. It is nil if output 2 is not nil.
+//	output 2: an error if communities do not partition the nodes, see
+//		ValidatePartition, or if Louvain gives no initial partition, nil
+//		otherwise
+// note:
+//	Starting from single node communities instead, the annealing rarely
+//	reaches the quality of Louvain with a practical schedule: once it has
+//	merged two groups at a low temperature, random splits seldom separate
+//	them again.
+//	Most proposed moves move a random node into the community of a random
+//	neighbor, or into an empty community. With probability
+//	annealCommunityMoveProbability, the proposal merges the community of a
+//	random node into that of a random neighbor, or splits a random half out
+//	of the community of a random node, instead. A proposal changing the
+//	quality by delta is accepted with the Metropolis criterion, i.e. always if
+//	delta >= 0 and with probability exp(delta / T) otherwise. The changes are
+//	computed with DeltaQuality, one node at a time for merges and splits, so
+//	the quality is evaluated in full only once.
+func Anneal(qm QualityModel, communities []map[int]bool, schedule AnnealSchedule, rng *rand.Rand,
+) ([]map[int]bool, error) {
+	// -------------------------------------------------------------------------
+	// step 1: check the input
+	n := qm.GetN()
+	if schedule.InitialTemperature <= 0.0 || schedule.StopTemperature <= 0.0 {
+		log.Fatalln(fmt.Sprintf("temperatures %v and %v must be > 0",
+			schedule.InitialTemperature, schedule.StopTemperature))
+	}
+	if schedule.CoolingFactor <= 0.0 || schedule.CoolingFactor >= 1.0 {
+		log.Fatalln(fmt.Sprintf("CoolingFactor = %v must be in (0, 1)", schedule.CoolingFactor))
+	}
+	if schedule.MovesPerTemperature <= 0 {
+		log.Fatalln(fmt.Sprintf("MovesPerTemperature = %d must be > 0",
+			schedule.MovesPerTemperature))
+	}
+	if n == 0 {
+		return []map[int]bool{}, nil
+	}
+	if communities == nil {
+		levels, err := LouvainHierarchyWithOptions(qm, nil, nil,
+			ClusteringOptions{MaxIters: annealLouvainMaxIters, Seed: rng.Int63() | 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(levels) == 0 {
+			return nil, fmt.Errorf("no initial partition from Louvain")
+		}
+		communities = levels[len(levels)-1]
+	}
+	communityIDs, err := getAssignment(communities, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: copy the initial partition and sort the neighbors of each node
+	state := &annealState{
+		qm:           qm,
+		communities:  make([]map[int]bool, len(communities)),
+		communityIDs: communityIDs,
+	}
+	for idxC, c := range communities {
+		state.communities[idxC] = make(map[int]bool, len(c))
+		for u, _ := range c {
+			state.communities[idxC][u] = true
+		}
+		if len(c) == 0 {
+			state.empties = append(state.empties, idxC)
+		}
+	}
+	neighbors := make([][]int, n)
+	for u := 0; u < n; u++ {
+		for v, _ := range qm.GetNeighbors(u) {
+			if v != u {
+				neighbors[u] = append(neighbors[u], v)
+			}
+		}
+		sort.Ints(neighbors[u])
+	}
+	quality := qm.Quality(state.communities)
+	bestQuality := quality
+	bestCommunityIDs := append([]int{}, state.communityIDs...)
+
+	// -------------------------------------------------------------------------
+	// step 3: propose moves, cooling down geometrically
+	isAccepted := func(delta, temperature float64) bool {
+		return delta >= 0.0 || rng.Float64() < math.Exp(delta/temperature)
+	}
+	temperature := schedule.InitialTemperature
+	for temperature >= schedule.StopTemperature {
+		for i := 0; i < schedule.MovesPerTemperature; i++ {
+			u := rng.Intn(n)
+			oldCu := state.communityIDs[u]
+			if rng.Float64() >= annealCommunityMoveProbability {
+				// (3.1) move u into the community of a random neighbor, or
+				// into an empty community
+				k := rng.Intn(len(neighbors[u]) + 1)
+				newCu := 0
+				if k < len(neighbors[u]) {
+					newCu = state.communityIDs[neighbors[u][k]]
+				} else {
+					newCu = state.getEmpty()
+				}
+				if newCu == oldCu {
+					continue
+				}
+				delta := qm.DeltaQuality(state.communities, u, oldCu, newCu)
+				if !isAccepted(delta, temperature) {
+					continue
+				}
+				state.move(u, newCu)
+				quality += delta
+			} else {
+				// (3.2) merge the community of u into that of a random
+				// neighbor, or split a random half out of it
+				nodes := []int{}
+				newCu := 0
+				if rng.Intn(2) == 0 {
+					if len(neighbors[u]) == 0 {
+						continue
+					}
+					newCu = state.communityIDs[neighbors[u][rng.Intn(len(neighbors[u]))]]
+					if newCu == oldCu {
+						continue
+					}
+					nodes = getSortedMembers(state.communities[oldCu])
+				} else {
+					if len(state.communities[oldCu]) < 2 {
+						continue
+					}
+					newCu = state.getEmpty()
+					for _, v := range getSortedMembers(state.communities[oldCu]) {
+						if v == u || rng.Intn(2) == 0 {
+							nodes = append(nodes, v)
+						}
+					}
+				}
+				delta, oldCus := state.moveAll(nodes, newCu)
+				if !isAccepted(delta, temperature) {
+					state.revertAll(nodes, oldCus)
+					continue
+				}
+				quality += delta
+			}
+
+			// (3.3) remember the best partition
+			if quality > bestQuality {
+				bestQuality = quality
+				copy(bestCommunityIDs, state.communityIDs)
+			}
+		}
+		temperature *= schedule.CoolingFactor
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return Partition(LabelsToCommunities(bestCommunityIDs)).Canonicalize(), nil
+}
@@ -0,0 +1,61 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAnnealMatchesOrBeatsLouvain(t *testing.T) {
+	schedule := AnnealSchedule{InitialTemperature: 0.01, CoolingFactor: 0.95,
+		MovesPerTemperature: 500, StopTemperature: 1e-5}
+	opts := ClusteringOptions{MaxIters: 100, Seed: 1}
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 10, 0.6, 0.05)
+	karate, _ := karateClub(t)
+	for name, qm := range map[string]QualityModel{"planted partition": NewModularity(1.0, cm),
+		"karate club": NewModularityStandard(1.0, karate)} {
+		levels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		louvainQuality := qm.Quality(levels[len(levels)-1])
+		bestQuality := math.Inf(-1)
+		for seed := int64(1); seed <= 3; seed++ {
+			communities, err := Anneal(qm, nil, schedule, rand.New(rand.NewSource(seed)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidatePartition(communities, qm.GetN()); err != nil {
+				t.Fatal(err)
+			}
+			quality := qm.Quality(communities)
+			if quality < louvainQuality-1e-9 {
+				t.Fatalf("%s, seed %d: Anneal reaches %v, Louvain %v", name, seed, quality,
+					louvainQuality)
+			}
+			bestQuality = math.Max(bestQuality, quality)
+		}
+		// the maximum modularity of the karate club is 0.4197896, found by
+		// integer programming (Brandes et al., 2008), which Louvain misses with
+		// many seeds
+		if name == "karate club" && bestQuality < 0.41978 {
+			t.Fatalf("the best modularity of the karate club is %v, want 0.4197896",
+				bestQuality)
+		}
+	}
+}
+
+func TestAnnealRejectsInvalidPartitions(t *testing.T) {
+	schedule := AnnealSchedule{InitialTemperature: 0.01, CoolingFactor: 0.5,
+		MovesPerTemperature: 10, StopTemperature: 1e-3}
+	qm := NewModularity(1.0, twoTriangles(t))
+	for _, invalid := range [][]map[int]bool{
+		{{0: true, 1: true, 2: true}, {2: true, 3: true, 4: true, 5: true}}, // 2 is in both
+		{{0: true, 1: true, 2: true}, {3: true, 4: true}},                   // 5 is missing
+	} {
+		communities, err := Anneal(qm, invalid, schedule, rand.New(rand.NewSource(1)))
+		if err == nil || communities != nil {
+			t.Fatalf("Anneal(%v) = %v, %v, want an error", invalid, communities, err)
+		}
+	}
+}
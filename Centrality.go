@@ -0,0 +1,152 @@
+package ConcurrenceBasedClustering
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+)
+
+// =============================================================================
+// func (cm ConcurrenceModel) getShortestPaths
+// brief description: find the shortest paths from a source to all nodes, as
+//	the first phase of Brandes' algorithm.
+// input:
+//	source: the source node
+//	weighted: if true, the length of an edge is 1/concurrence, otherwise 1.
+// output:
+//	output 1: the nodes reachable from source in the order of increasing
+//		distance, source first
+//	output 2: the number of shortest paths from source to each node
+//	output 3: the predecessors of each node on its shortest paths
+func (cm ConcurrenceModel) getShortestPaths(source int, weighted bool) ([]int, []float64, [][]int) {
+	order := []int{}
+	sigmas := make([]float64, cm.n)
+	predecessors := make([][]int, cm.n)
+	distances := make([]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		distances[u] = math.Inf(1)
+	}
+	distances[source] = 0.0
+	sigmas[source] = 1.0
+	visited := make([]bool, cm.n)
+	queue := &reachabilityHeap{{pt: source, reachability: 0.0}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(reachabilityItem)
+		u := item.pt
+		if visited[u] {
+			continue
+		}
+		visited[u] = true
+		order = append(order, u)
+		for v, weightUV := range cm.concurrences[u] {
+			if v == u || weightUV <= 0.0 {
+				continue
+			}
+			length := 1.0
+			if weighted {
+				length = 1.0 / weightUV
+			}
+			distance := distances[u] + length
+			if distance < distances[v] {
+				distances[v] = distance
+				sigmas[v] = sigmas[u]
+				predecessors[v] = []int{u}
+				heap.Push(queue, reachabilityItem{pt: v, reachability: distance})
+			} else if distance == distances[v] {
+				sigmas[v] += sigmas[u]
+				predecessors[v] = append(predecessors[v], u)
+			}
+		}
+	}
+	return order, sigmas, predecessors
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) BetweennessCentrality
+// brief description: compute the betweenness centrality of nodes and edges by
+//	Brandes' algorithm, e.g. to rank the nodes and concurrences connecting
+//	communities.
+// input:
+//	weighted: if true, the length of an edge is 1/concurrence, so that strong
+//		concurrences are short, otherwise every edge has length 1.
+//	normalized: if true, the node betweenness is divided by (n-1)(n-2)/2 and
+//		the edge betweenness by n(n-1)/2, the numbers of pairs of other nodes
+//		and of all nodes.
+//	numSources: the number of random sources to compute from, for large
+//		graphs. If it is <= 0 or >= n, all nodes are sources and the result is
+//		exact. Otherwise, the result is estimated by scaling by n/numSources.
+//	seed: the seed of the random sources. If it is 0, the global source of
+//		math/rand is used.
+// output:
+//	output 1: the betweenness of each node, i.e. the sum over the unordered
+//		pairs of other nodes of the fraction of their shortest paths passing
+//		through it
+//	output 2: the betweenness of each edge, keyed by its nodes U < V
+// note:
+//	The graph is undirected, and cardinalities are ignored. It takes O(nm)
+//	time with numSources = n for unweighted graphs, and O(nm log(n)) for
+//	weighted ones.
+func (cm ConcurrenceModel) BetweennessCentrality(weighted, normalized bool, numSources int,
+	seed int64) ([]float64, map[IntPair]float64) {
+	// -------------------------------------------------------------------------
+	// step 1: choose the sources
+	n := cm.n
+	sources := make([]int, n)
+	for u := 0; u < n; u++ {
+		sources[u] = u
+	}
+	if numSources > 0 && numSources < n {
+		if seed == 0 {
+			sources = rand.Perm(n)[:numSources]
+		} else {
+			sources = rand.New(rand.NewSource(seed)).Perm(n)[:numSources]
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: accumulate the dependencies of each source
+	nodeBetweenness := make([]float64, n)
+	edgeBetweenness := map[IntPair]float64{}
+	deltas := make([]float64, n)
+	for _, source := range sources {
+		order, sigmas, predecessors := cm.getShortestPaths(source, weighted)
+		for _, u := range order {
+			deltas[u] = 0.0
+		}
+		for idx := len(order) - 1; idx >= 0; idx-- {
+			v := order[idx]
+			for _, u := range predecessors[v] {
+				dependency := sigmas[u] / sigmas[v] * (1.0 + deltas[v])
+				deltas[u] += dependency
+				if u < v {
+					edgeBetweenness[IntPair{U: u, V: v}] += dependency
+				} else {
+					edgeBetweenness[IntPair{U: v, V: u}] += dependency
+				}
+			}
+			if v != source {
+				nodeBetweenness[v] += deltas[v]
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: scale the results. Each pair is counted from both of its ends.
+	nodeScale := 0.5 * float64(n) / float64(len(sources))
+	edgeScale := nodeScale
+	if normalized {
+		if n > 2 {
+			nodeScale /= 0.5 * float64((n-1)*(n-2))
+		}
+		if n > 1 {
+			edgeScale /= 0.5 * float64(n*(n-1))
+		}
+	}
+	for u := 0; u < n; u++ {
+		nodeBetweenness[u] *= nodeScale
+	}
+	for pair, _ := range edgeBetweenness {
+		edgeBetweenness[pair] *= edgeScale
+	}
+	return nodeBetweenness, edgeBetweenness
+}
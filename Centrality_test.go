@@ -0,0 +1,87 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"testing"
+)
+
+// =============================================================================
+// func assertCloseSlices
+// brief description: fail unless two slices are equal within 1e-9
+func assertCloseSlices(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", name, got, want)
+	}
+	for i, _ := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBetweennessOfPathAndStar(t *testing.T) {
+	// on a path of 5 nodes, node i separates i nodes from 4-i, and the edge
+	// (i, i+1) separates i+1 nodes from 4-i. The weights do not matter on a
+	// tree. On a star with 4 leaves, the center is on the paths of all 6 pairs
+	// of leaves, and each edge on the paths of its leaf to the 4 other nodes.
+	path := newTestModel(t, []Edge{{0, 1, 1}, {1, 2, 0.5}, {2, 3, 2}, {3, 4, 1}})
+	star := newTestModel(t, []Edge{{0, 1, 1}, {0, 2, 1}, {0, 3, 0.2}, {0, 4, 1}})
+	for _, tc := range []struct {
+		name      string
+		cm        ConcurrenceModel
+		nodes     []float64
+		edges     map[IntPair]float64
+		nodeScale float64
+		edgeScale float64
+	}{
+		{"path", path, []float64{0, 3, 4, 3, 0},
+			map[IntPair]float64{{0, 1}: 4, {1, 2}: 6, {2, 3}: 6, {3, 4}: 4}, 6, 10},
+		{"star", star, []float64{6, 0, 0, 0, 0},
+			map[IntPair]float64{{0, 1}: 4, {0, 2}: 4, {0, 3}: 4, {0, 4}: 4}, 6, 10},
+	} {
+		for _, weighted := range []bool{false, true} {
+			for _, normalized := range []bool{false, true} {
+				// numSources = n also computes from all sources
+				for _, numSources := range []int{0, tc.cm.n} {
+					nodes, edges := tc.cm.BetweennessCentrality(weighted, normalized, numSources, 1)
+					nodeScale, edgeScale := 1.0, 1.0
+					if normalized {
+						nodeScale, edgeScale = tc.nodeScale, tc.edgeScale
+					}
+					wantNodes := make([]float64, len(tc.nodes))
+					for u, _ := range tc.nodes {
+						wantNodes[u] = tc.nodes[u] / nodeScale
+					}
+					assertCloseSlices(t, tc.name+" nodes", nodes, wantNodes)
+					if len(edges) != len(tc.edges) {
+						t.Fatalf("%s: edges = %v, want %v", tc.name, edges, tc.edges)
+					}
+					for pair, want := range tc.edges {
+						if math.Abs(edges[pair]-want/edgeScale) > 1e-9 {
+							t.Fatalf("%s: edges = %v, want %v / %v", tc.name, edges, tc.edges,
+								edgeScale)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWeightedBetweennessFollowsStrongConcurrences(t *testing.T) {
+	// the weak concurrence (0, 1) has the length 10, longer than the path of
+	// length 2 through 2, so the shortest path from 0 to 1 goes through 2 with
+	// weights, and is the direct edge without them
+	cm := newTestModel(t, []Edge{{0, 1, 0.1}, {0, 2, 1}, {1, 2, 1}})
+	nodes, edges := cm.BetweennessCentrality(true, false, 0, 0)
+	assertCloseSlices(t, "weighted nodes", nodes, []float64{0, 0, 1})
+	if edges[IntPair{0, 1}] != 0 || edges[IntPair{0, 2}] != 2 || edges[IntPair{1, 2}] != 2 {
+		t.Fatalf("weighted edges = %v", edges)
+	}
+	nodes, edges = cm.BetweennessCentrality(false, false, 0, 0)
+	assertCloseSlices(t, "unweighted nodes", nodes, []float64{0, 0, 0})
+	if edges[IntPair{0, 1}] != 1 || edges[IntPair{0, 2}] != 1 || edges[IntPair{1, 2}] != 1 {
+		t.Fatalf("unweighted edges = %v", edges)
+	}
+}
@@ -0,0 +1,263 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// struct CommunityStat
+// brief description: This is a struct for the diagnostics of one community.
+//	Weights are concurrences weighted by the cardinalities of both ends, the
+//	same as in sumConcurrencesOf, and each pair of nodes is counted once.
+type CommunityStat struct {
+	Size           int
+	Volume         float64
+	InternalWeight float64
+	CutWeight      float64
+	Conductance    float64
+	Density        float64
+}
+
+// =============================================================================
+// struct PartitionStat
+// brief description: This is a struct for the diagnostics of a whole
+//	partition, aggregated from its CommunityStats.
+type PartitionStat struct {
+	NumCommunities  int
+	InternalWeight  float64
+	ExternalWeight  float64
+	Coverage        float64
+	MeanConductance float64
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) CommunityStats
+// brief description: compute the diagnostics of each community
+// input:
+//	communities: a list of clusters.
+// output:
+//	the CommunityStat of each community, where:
+//	Size is the number of nodes,
+//	Volume is the sum of sumConcurrencesOf over the nodes,
+//	InternalWeight is the weight between nodes inside the community,
+//	CutWeight is the weight between the community and the other nodes,
+//	Conductance is CutWeight / min(Volume, the volume of the complement), or
+//		0 if that minimum is 0,
+//	Density is InternalWeight / the number of pairs of nodes, or 0 for
+//		communities with less than 2 nodes.
+// note:
+//	The cut weights are derived from the volumes, which are maintained by the
+//	model, so only the edges inside communities are scanned. This assumes the
+//	model is not aggregated, since an aggregated model keeps the internal
+//	weights of its nodes in their volumes only.
+func (cm ConcurrenceModel) CommunityStats(communities []map[int]bool) []CommunityStat {
+	result := make([]CommunityStat, len(communities))
+	for idxC, c := range communities {
+		// ---------------------------------------------------------------------
+		// step 1: sum the volume and the internal weight of c
+		stat := CommunityStat{Size: len(c)}
+		for u, _ := range c {
+			stat.Volume += cm.sumConcurrencesOf[u]
+			for v, weightUV := range cm.concurrences[u] {
+				if v <= u || !c[v] {
+					continue
+				}
+				stat.InternalWeight += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+			}
+		}
+
+		// ---------------------------------------------------------------------
+		// step 2: derive the other fields
+		stat.CutWeight = math.Max(0.0, stat.Volume-2.0*stat.InternalWeight)
+		minVolume := math.Min(stat.Volume, cm.sumConcurrences-stat.Volume)
+		if minVolume > 0.0 {
+			stat.Conductance = stat.CutWeight / minVolume
+		}
+		if stat.Size > 1 {
+			stat.Density = stat.InternalWeight / (float64(stat.Size*(stat.Size-1)) / 2.0)
+		}
+		result[idxC] = stat
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) PartitionStats
+// brief description: compute the diagnostics of a partition
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the PartitionStat of the partition, where:
+//	NumCommunities is the number of non-empty communities,
+//	InternalWeight is the total weight inside communities,
+//	ExternalWeight is the total weight of the other pairs,
+//	Coverage is the fraction of the total weight inside communities,
+//	MeanConductance is the mean Conductance of the non-empty communities.
+func (cm ConcurrenceModel) PartitionStats(communities []map[int]bool) PartitionStat {
+	result := PartitionStat{}
+	for _, stat := range cm.CommunityStats(communities) {
+		if stat.Size == 0 {
+			continue
+		}
+		result.NumCommunities++
+		result.InternalWeight += stat.InternalWeight
+		result.MeanConductance += stat.Conductance
+	}
+	totalWeight := cm.sumConcurrences / 2.0
+	result.ExternalWeight = math.Max(0.0, totalWeight-result.InternalWeight)
+	if totalWeight > 0.0 {
+		result.Coverage = result.InternalWeight / totalWeight
+	}
+	if result.NumCommunities > 0 {
+		result.MeanConductance /= float64(result.NumCommunities)
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) Conductance
+// brief description: compute the conductance of each community, i.e. its cut
+//	weight / min(its volume, the volume of the complement). See CommunityStats.
+// input:
+//	communities: a list of clusters.
+// output:
+//	the conductance of each community. It is 0 for a community with no volume
+//	or containing the whole volume, e.g. the community of all nodes.
+func (cm ConcurrenceModel) Conductance(communities []map[int]bool) []float64 {
+	result := make([]float64, len(communities))
+	for idxC, stat := range cm.CommunityStats(communities) {
+		result[idxC] = stat.Conductance
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) TotalCut
+// brief description: compute the total weight between different communities,
+//	i.e. the ExternalWeight of PartitionStats.
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the total cut weight, each pair of nodes counted once.
+func (cm ConcurrenceModel) TotalCut(communities []map[int]bool) float64 {
+	return cm.PartitionStats(communities).ExternalWeight
+}
+
+// =============================================================================
+// func SilhouetteScore
+// brief description: compute the silhouettes of clustered nodes, using
+//	1 - similarity as the distance between two nodes.
+// input:
+//	simMat: a similarity matrix. Missing entries are similarity 0, i.e.
+//		distance 1. Diagonal entries are ignored.
+//	communities: a list of clusters over the nodes of simMat. They must not
+//		overlap.
+// output:
+//	output 1: the mean silhouette of the clustered nodes, within [-1, 1].
+//	output 2: the silhouette of each node of simMat. Nodes not in any
+//		community, nodes in singleton communities, and nodes of a partition
+//		with only one community have silhouette 0 by convention.
+func SilhouetteScore(simMat []map[int]float64, communities []map[int]bool) (float64, []float64) {
+	// -------------------------------------------------------------------------
+	// step 1: find the community of each node
+	n := len(simMat)
+	communityIDs := make([]int, n)
+	for u := 0; u < n; u++ {
+		communityIDs[u] = -1
+	}
+	numClustered := 0
+	for idxC, c := range communities {
+		for u, _ := range c {
+			if u < 0 || u >= n {
+				log.Fatalln(fmt.Sprintf("node %d of community %d is out of range [0, %d)", u, idxC, n))
+			}
+			communityIDs[u] = idxC
+			numClustered++
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the silhouette of each node from its mean distances to
+	// communities, which are 1 - the mean similarities
+	silhouettes := make([]float64, n)
+	sumSilhouettes := 0.0
+	for u := 0; u < n; u++ {
+		cu := communityIDs[u]
+		if cu < 0 || len(communities[cu]) < 2 {
+			continue
+		}
+		sumSims := map[int]float64{}
+		for v, simUV := range simMat[u] {
+			if v == u || v < 0 || v >= n || communityIDs[v] < 0 {
+				continue
+			}
+			sumSims[communityIDs[v]] += simUV
+		}
+		a := 1.0 - sumSims[cu]/float64(len(communities[cu])-1)
+		b := math.Inf(1)
+		for idxC, c := range communities {
+			if idxC == cu || len(c) == 0 {
+				continue
+			}
+			b = math.Min(b, 1.0-sumSims[idxC]/float64(len(c)))
+		}
+		if math.IsInf(b, 1) {
+			continue
+		}
+		maxAB := math.Max(a, b)
+		if maxAB > 0.0 {
+			silhouettes[u] = (b - a) / maxAB
+		}
+		sumSilhouettes += silhouettes[u]
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	if numClustered == 0 {
+		return 0.0, silhouettes
+	}
+	return sumSilhouettes / float64(numClustered), silhouettes
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) Silhouette
+// brief description: compute the silhouettes of clustered nodes, using the
+//	concurrences as similarities like DBScan does. See SilhouetteScore.
+func (cm ConcurrenceModel) Silhouette(communities []map[int]bool) (float64, []float64) {
+	return SilhouetteScore(cm.concurrences, communities)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DegreeDistribution
+// brief description: count the nodes of each degree, i.e. number of neighbors
+// output:
+//	a map from each degree to the number of nodes with that degree
+func (cm ConcurrenceModel) DegreeDistribution() map[int]int {
+	result := map[int]int{}
+	for u := 0; u < cm.n; u++ {
+		degree := len(cm.concurrences[u])
+		if _, hasSelfLoop := cm.concurrences[u][u]; hasSelfLoop {
+			degree--
+		}
+		result[degree]++
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) GraphDensity
+// brief description: compute the fraction of pairs of nodes with concurrences
+// output:
+//	the number of edges / (n (n-1) / 2), or 0 if n < 2
+func (cm ConcurrenceModel) GraphDensity() float64 {
+	if cm.n < 2 {
+		return 0.0
+	}
+	sumDegrees := 0
+	for degree, count := range cm.DegreeDistribution() {
+		sumDegrees += degree * count
+	}
+	return float64(sumDegrees) / float64(cm.n*(cm.n-1))
+}
@@ -0,0 +1,87 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCommunityStatsOnTwoTriangles(t *testing.T) {
+	cm := twoTriangles(t)
+	for _, tc := range []struct {
+		communities []map[int]bool
+		stats       []CommunityStat
+		partition   PartitionStat
+	}{
+		{
+			[]map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}},
+			[]CommunityStat{{3, 7, 3, 1, 1.0 / 7.0, 1}, {3, 7, 3, 1, 1.0 / 7.0, 1}},
+			PartitionStat{2, 6, 1, 6.0 / 7.0, 1.0 / 7.0},
+		},
+		{
+			[]map[int]bool{{0: true, 1: true}, {2: true, 3: true, 4: true, 5: true}},
+			[]CommunityStat{{2, 4, 1, 2, 0.5, 1}, {4, 10, 4, 2, 0.5, 4.0 / 6.0}},
+			PartitionStat{2, 5, 2, 5.0 / 7.0, 0.5},
+		},
+	} {
+		stats := cm.CommunityStats(tc.communities)
+		for idxC, stat := range stats {
+			if !sameCommunityStat(stat, tc.stats[idxC]) {
+				t.Fatalf("stats of %v = %+v, want %+v", tc.communities[idxC], stat, tc.stats[idxC])
+			}
+		}
+		partition := cm.PartitionStats(tc.communities)
+		want := tc.partition
+		if partition.NumCommunities != want.NumCommunities ||
+			math.Abs(partition.InternalWeight-want.InternalWeight) > 1e-12 ||
+			math.Abs(partition.ExternalWeight-want.ExternalWeight) > 1e-12 ||
+			math.Abs(partition.Coverage-want.Coverage) > 1e-12 ||
+			math.Abs(partition.MeanConductance-want.MeanConductance) > 1e-12 {
+			t.Fatalf("partition stats of %v = %+v, want %+v", tc.communities, partition, want)
+		}
+		if cm.TotalCut(tc.communities) != want.ExternalWeight {
+			t.Fatalf("TotalCut = %v, want %v", cm.TotalCut(tc.communities), want.ExternalWeight)
+		}
+	}
+}
+
+func TestSilhouetteSeparatedAndRandom(t *testing.T) {
+	// -------------------------------------------------------------------------
+	// step 1: two cliques without edges between them are perfectly separated,
+	// and the extra singleton gets 0
+	cm := newTestModel(t, append(cliqueEdges(0, 5, 1), cliqueEdges(5, 5, 1)...))
+	cm.AddNode(1)
+	cliques := []map[int]bool{{0: true, 1: true, 2: true, 3: true, 4: true},
+		{5: true, 6: true, 7: true, 8: true, 9: true}, {10: true}}
+	mean, silhouettes := cm.Silhouette(cliques)
+	for u := 0; u < 10; u++ {
+		if math.Abs(silhouettes[u]-1.0) > 1e-12 {
+			t.Fatalf("silhouette of %d = %v, want 1", u, silhouettes[u])
+		}
+	}
+	if silhouettes[10] != 0.0 || math.Abs(mean-10.0/11.0) > 1e-12 {
+		t.Fatalf("singleton silhouette = %v, mean = %v, want 0, 10/11", silhouettes[10], mean)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: a random assignment of a clustered graph scores near 0
+	rng := rand.New(rand.NewSource(1))
+	planted, truth := plantedPartition(t, rng, 4, 25, 0.5, 0.05)
+	plantedMean, _ := planted.Silhouette(truth)
+	randomMean, _ := planted.Silhouette(randomCommunities(rng, planted.GetN(), 4))
+	if math.Abs(randomMean) > 0.1 || plantedMean < 0.3 {
+		t.Fatalf("mean silhouette = %v for a random assignment, %v for the planted one",
+			randomMean, plantedMean)
+	}
+}
+
+// =============================================================================
+// func sameCommunityStat
+// brief description: compare two CommunityStats up to rounding
+func sameCommunityStat(a, b CommunityStat) bool {
+	return a.Size == b.Size && math.Abs(a.Volume-b.Volume) < 1e-12 &&
+		math.Abs(a.InternalWeight-b.InternalWeight) < 1e-12 &&
+		math.Abs(a.CutWeight-b.CutWeight) < 1e-12 &&
+		math.Abs(a.Conductance-b.Conductance) < 1e-12 &&
+		math.Abs(a.Density-b.Density) < 1e-12
+}
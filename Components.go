@@ -0,0 +1,483 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// =============================================================================
+// struct unionFind
+// brief description: This is a disjoint-set forest with path halving and union
+//	by size.
+type unionFind struct {
+	parents []int
+	sizes   []int
+}
+
+// =============================================================================
+// func newUnionFind
+// brief description: create a unionFind of n singleton sets
+func newUnionFind(n int) unionFind {
+	uf := unionFind{parents: make([]int, n), sizes: make([]int, n)}
+	for u := 0; u < n; u++ {
+		uf.parents[u] = u
+		uf.sizes[u] = 1
+	}
+	return uf
+}
+
+// =============================================================================
+// func (uf unionFind) find
+// brief description: find the representative of the set containing u
+func (uf unionFind) find(u int) int {
+	for uf.parents[u] != u {
+		uf.parents[u] = uf.parents[uf.parents[u]]
+		u = uf.parents[u]
+	}
+	return u
+}
+
+// =============================================================================
+// func (uf unionFind) union
+// brief description: merge the sets containing u and v
+// output:
+//	true if they were different sets, false otherwise
+func (uf unionFind) union(u, v int) bool {
+	rootU := uf.find(u)
+	rootV := uf.find(v)
+	if rootU == rootV {
+		return false
+	}
+	if uf.sizes[rootU] < uf.sizes[rootV] {
+		rootU, rootV = rootV, rootU
+	}
+	uf.parents[rootV] = rootU
+	uf.sizes[rootU] += uf.sizes[rootV]
+	return true
+}
+
+// =============================================================================
+// func (uf *unionFind) add
+// brief description: add a new singleton set
+// output:
+//	the new element, i.e. the number of elements before the call
+func (uf *unionFind) add() int {
+	u := len(uf.parents)
+	uf.parents = append(uf.parents, u)
+	uf.sizes = append(uf.sizes, 1)
+	return u
+}
+
+// =============================================================================
+// func (uf unionFind) sets
+// brief description: list the sets
+// output:
+//	output 1: the sets, ordered by their smallest members
+//	output 2: the ID of the set containing each element
+func (uf unionFind) sets() ([]map[int]bool, []int) {
+	n := len(uf.parents)
+	setIDs := make([]int, n)
+	idOfRoot := map[int]int{}
+	result := []map[int]bool{}
+	for u := 0; u < n; u++ {
+		root := uf.find(u)
+		id, exists := idOfRoot[root]
+		if !exists {
+			id = len(result)
+			idOfRoot[root] = id
+			result = append(result, map[int]bool{})
+		}
+		result[id][u] = true
+		setIDs[u] = id
+	}
+	return result, setIDs
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) components
+// brief description: find the connected components of the thresholded
+//	concurrence graph
+// input:
+//	eps: the same as in ConnectedComponents
+// output:
+//	output 1: the components, ordered by their smallest members
+//	output 2: the component ID of each node
+func (cm ConcurrenceModel) components(eps float64) ([]map[int]bool, []int) {
+	uf := newUnionFind(cm.n)
+	for u := 0; u < cm.n; u++ {
+		for v, similarity := range cm.concurrences[u] {
+			if v <= u {
+				continue
+			}
+			if similarity+eps >= 1.0 {
+				uf.union(u, v)
+			}
+		}
+	}
+	return uf.sets()
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) ConnectedComponents
+// brief description: find the connected components of the graph where two
+//	nodes are connected if their similarity sim satisfies sim+eps >= 1, the
+//	same neighborhood as in DBScan.
+// input:
+//	eps: the radius of neighborhood. With eps >= 1, every concurrence is an
+//		edge.
+// output:
+//	the components, ordered by their smallest members. Isolated nodes are
+//	singleton components.
+// note:
+//	This is the same as DBScan with minPts = 1, but runs in near linear time.
+func (cm ConcurrenceModel) ConnectedComponents(eps float64) []map[int]bool {
+	result, _ := cm.components(eps)
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) subgraph
+// brief description: create the submodel induced by some nodes
+// input:
+//	nodes: distinct node IDs in the order of their new IDs
+// output:
+//	the submodel, whose node i is nodes[i], with the concurrences between the
+//	given nodes and their cardinalities. Its statistical fields are recomputed
+//	from the kept concurrences.
+func (cm ConcurrenceModel) subgraph(nodes []int) ConcurrenceModel {
+	newIDs := make(map[int]int, len(nodes))
+	for newID, u := range nodes {
+		newIDs[u] = newID
+	}
+	newConcurrences := make([]map[int]float64, len(nodes))
+	newCardinalities := make([]int, len(nodes))
+	for newU, u := range nodes {
+		newConcurrences[newU] = map[int]float64{}
+		newCardinalities[newU] = cm.cardinalities[u]
+		for v, weightUV := range cm.concurrences[u] {
+			newV, exists := newIDs[v]
+			if exists && newV != newU {
+				newConcurrences[newU][newV] = weightUV
+			}
+		}
+	}
+	return newConcurrenceModel(newConcurrences, newCardinalities)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) LargestComponent
+// brief description: extract the largest connected component of the
+//	concurrence graph, where every concurrence is an edge.
+// output:
+//	output 1: the submodel of the largest component, with nodes relabeled
+//		densely from 0. Among components of the same size, the one with the
+//		smallest node ID is chosen.
+//	output 2: the original ID of each node of the submodel, in ascending order
+func (cm ConcurrenceModel) LargestComponent() (ConcurrenceModel, []int) {
+	components, _ := cm.components(1.0)
+	largest := map[int]bool{}
+	for _, c := range components {
+		if len(c) > len(largest) {
+			largest = c
+		}
+	}
+	nodes := make([]int, 0, len(largest))
+	for u, _ := range largest {
+		nodes = append(nodes, u)
+	}
+	sort.Ints(nodes)
+	return cm.subgraph(nodes), nodes
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) Subgraph
+// brief description: extract the submodel induced by a set of nodes, e.g. to
+//	cluster a single community again.
+// input:
+//	nodes: a set of node IDs
+// output:
+//	output 1: the submodel with the concurrences between the given nodes only,
+//		with nodes relabeled densely from 0.
+//	output 2: the original ID of each node of the submodel, in ascending order
+func (cm ConcurrenceModel) Subgraph(nodes map[int]bool) (ConcurrenceModel, []int) {
+	sortedNodes := make([]int, 0, len(nodes))
+	for u, _ := range nodes {
+		if u < 0 || u >= cm.n {
+			log.Fatalln(fmt.Sprintf("node %d is out of range [0, %d)", u, cm.n))
+		}
+		sortedNodes = append(sortedNodes, u)
+	}
+	sort.Ints(sortedNodes)
+	return cm.subgraph(sortedNodes), sortedNodes
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) CoreNumbers
+// brief description: compute the k-core decomposition of the concurrence
+//	graph with the algorithm of Batagelj and Zaversnik, ignoring weights.
+// output:
+//	the core number of each node, i.e. the largest k such that the node is in
+//	a subgraph where every node has at least k neighbors.
+func (cm ConcurrenceModel) CoreNumbers() []int {
+	// -------------------------------------------------------------------------
+	// step 1: sort the nodes by degree with a bucket sort
+	n := cm.n
+	degrees := make([]int, n)
+	maxDegree := 0
+	for u := 0; u < n; u++ {
+		for v, _ := range cm.concurrences[u] {
+			if v != u {
+				degrees[u]++
+			}
+		}
+		if degrees[u] > maxDegree {
+			maxDegree = degrees[u]
+		}
+	}
+	bucketStarts := make([]int, maxDegree+2)
+	for u := 0; u < n; u++ {
+		bucketStarts[degrees[u]+1]++
+	}
+	for d := 1; d <= maxDegree+1; d++ {
+		bucketStarts[d] += bucketStarts[d-1]
+	}
+	order := make([]int, n)
+	positions := make([]int, n)
+	nextPositions := append([]int(nil), bucketStarts...)
+	for u := 0; u < n; u++ {
+		positions[u] = nextPositions[degrees[u]]
+		order[positions[u]] = u
+		nextPositions[degrees[u]]++
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: peel the nodes in the order of their current degrees. When a
+	// node is peeled, each neighbor with a larger degree moves to the front
+	// of its bucket and then into the bucket below.
+	for i := 0; i < n; i++ {
+		u := order[i]
+		for v, _ := range cm.concurrences[u] {
+			if v == u || degrees[v] <= degrees[u] {
+				continue
+			}
+			degreeV := degrees[v]
+			w := order[bucketStarts[degreeV]]
+			if w != v {
+				order[positions[v]], order[bucketStarts[degreeV]] = w, v
+				positions[w], positions[v] = positions[v], bucketStarts[degreeV]
+			}
+			bucketStarts[degreeV]++
+			degrees[v]--
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return degrees
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) KCoreSubmodel
+// brief description: extract the submodel of the k-core, e.g. to strip the
+//	low-core periphery before clustering.
+// input:
+//	k: the minimum core number
+// output:
+//	output 1: the submodel of the nodes with core numbers >= k, with nodes
+//		relabeled densely from 0 and statistics recomputed.
+//	output 2: the original ID of each node of the submodel, in ascending order
+func (cm ConcurrenceModel) KCoreSubmodel(k int) (ConcurrenceModel, []int) {
+	nodes := []int{}
+	for u, coreNumber := range cm.CoreNumbers() {
+		if coreNumber >= k {
+			nodes = append(nodes, u)
+		}
+	}
+	return cm.subgraph(nodes), nodes
+}
+
+// =============================================================================
+// func splitDisconnectedCommunities
+// brief description: split the communities that are not internally connected
+//	into their connected components
+// input:
+//	n: the number of nodes
+//	neighborsOf: the neighbors of each node
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the communities, where each community is replaced in place by its
+//	components ordered by their smallest members
+func splitDisconnectedCommunities(n int, neighborsOf func(u int) map[int]float64,
+	communities []map[int]bool) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: link the neighbors inside the same community
+	communityIDs := make([]int, n)
+	for u := 0; u < n; u++ {
+		communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			communityIDs[u] = idxC
+		}
+	}
+	uf := newUnionFind(n)
+	for u := 0; u < n; u++ {
+		if communityIDs[u] < 0 {
+			continue
+		}
+		for v, _ := range neighborsOf(u) {
+			if v != u && communityIDs[v] == communityIDs[u] {
+				uf.union(u, v)
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: split each community by the components of its members
+	result := make([]map[int]bool, 0, len(communities))
+	for _, c := range communities {
+		componentOf := map[int]map[int]bool{}
+		for u, _ := range c {
+			root := uf.find(u)
+			component, exists := componentOf[root]
+			if !exists {
+				component = map[int]bool{}
+				componentOf[root] = component
+			}
+			component[u] = true
+		}
+		if len(componentOf) <= 1 {
+			result = append(result, c)
+			continue
+		}
+		components := make([]map[int]bool, 0, len(componentOf))
+		for _, component := range componentOf {
+			components = append(components, component)
+		}
+		result = append(result, canonicalizeCommunities(components, nil)...)
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) SplitDisconnectedCommunities
+// brief description: split the communities that are not internally connected
+//	by concurrences into their connected components, e.g. the badly connected
+//	communities Louvain may produce.
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the communities in the same order, where each disconnected community is
+//	replaced by its components ordered by their smallest members
+func (cm ConcurrenceModel) SplitDisconnectedCommunities(communities []map[int]bool) []map[int]bool {
+	return splitDisconnectedCommunities(cm.n, cm.GetConcurrencesOf, communities)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) MergeSmallCommunities
+// brief description: merge each community with less than minSize nodes into
+//	the neighboring community it has the largest total concurrence with.
+// input:
+//	communities: a list of clusters. They must not overlap.
+//	minSize: the minimum number of nodes of communities.
+//	qm: a quality model over the same nodes, or nil. If it is not nil, merges
+//		that would decrease its quality are skipped unless force is true.
+//	force: whether to merge regardless of quality.
+// output:
+//	output 1: the communities after merging, without empty ones, in the input
+//		order. A small community without concurrences to other communities,
+//		or whose merge would decrease quality, is kept as it is.
+//	output 2: the number of merges
+// note:
+//	The input communities are not modified.
+func (cm ConcurrenceModel) MergeSmallCommunities(communities []map[int]bool, minSize int,
+	qm QualityModel, force bool) ([]map[int]bool, int) {
+	// -------------------------------------------------------------------------
+	// step 1: copy the communities and find the community of each node
+	communities = copyCommunities(communities)
+	communityIDs := make([]int, cm.n)
+	for u := 0; u < cm.n; u++ {
+		communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			communityIDs[u] = idxC
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: merge small communities until no merge happens in a pass
+	numMerges := 0
+	rejected := make([]bool, len(communities))
+	for {
+		merged := false
+		for idxC, c := range communities {
+			if len(c) == 0 || len(c) >= minSize || rejected[idxC] {
+				continue
+			}
+
+			// (2.1) find the neighboring community with the largest weight
+			weights := map[int]float64{}
+			for u, _ := range c {
+				for v, weightUV := range cm.concurrences[u] {
+					idxD := communityIDs[v]
+					if idxD >= 0 && idxD != idxC {
+						weights[idxD] += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+					}
+				}
+			}
+			best := -1
+			for idxD, weight := range weights {
+				if best < 0 || weight > weights[best] || (weight == weights[best] && idxD < best) {
+					best = idxD
+				}
+			}
+			if best < 0 {
+				rejected[idxC] = true
+				continue
+			}
+
+			// (2.2) move the nodes one by one, accumulating the quality change
+			members := make([]int, 0, len(c))
+			for u, _ := range c {
+				members = append(members, u)
+			}
+			deltaQ := 0.0
+			for _, u := range members {
+				if qm != nil && !force {
+					deltaQ += qm.DeltaQuality(communities, u, idxC, best)
+				}
+				delete(c, u)
+				communities[best][u] = true
+				communityIDs[u] = best
+			}
+
+			// (2.3) undo the merge if it decreases quality
+			if deltaQ < 0.0 {
+				for _, u := range members {
+					delete(communities[best], u)
+					c[u] = true
+					communityIDs[u] = idxC
+				}
+				rejected[idxC] = true
+				continue
+			}
+			numMerges++
+			merged = true
+		}
+		if !merged {
+			break
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the non-empty communities
+	result := make([]map[int]bool, 0, len(communities))
+	for _, c := range communities {
+		if len(c) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result, numMerges
+}
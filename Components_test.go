@@ -0,0 +1,72 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCoreNumbersOfCliqueWithPendants(t *testing.T) {
+	// a clique of 6 nodes, a pendant on each of its first 3 nodes, a path of 2
+	// nodes hanging from a pendant, and an isolated node 11
+	const size = 6
+	edges := append(cliqueEdges(0, size, 0.5),
+		Edge{0, 6, 1}, Edge{1, 7, 1}, Edge{2, 8, 1}, Edge{8, 9, 1}, Edge{9, 10, 1})
+	cm, err := newConcurrenceModelFromEdges(12, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{size - 1, size - 1, size - 1, size - 1, size - 1, size - 1, 1, 1, 1, 1, 1, 0}
+	if got := cm.CoreNumbers(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("core numbers = %v, want %v", got, want)
+	}
+
+	core, ids := cm.KCoreSubmodel(2)
+	if !reflect.DeepEqual(ids, []int{0, 1, 2, 3, 4, 5}) {
+		t.Fatalf("IDs of the 2-core = %v, want the clique", ids)
+	}
+	assertSameModel(t, core, newTestModel(t, cliqueEdges(0, size, 0.5)))
+	if _, ids := cm.KCoreSubmodel(size); len(ids) != 0 {
+		t.Fatalf("IDs of the %d-core = %v, want none", size, ids)
+	}
+}
+
+func TestSplitDisconnectedCommunitiesOfBarbell(t *testing.T) {
+	// the bells are 0..4 and 5..9, so without the end 4 of the bridge, the
+	// second community is disconnected
+	cm := barbell(t, 5)
+	communities := []map[int]bool{
+		{4: true},
+		{0: true, 1: true, 2: true, 3: true, 5: true, 6: true, 7: true, 8: true, 9: true},
+	}
+	want := []map[int]bool{
+		{4: true},
+		{0: true, 1: true, 2: true, 3: true},
+		{5: true, 6: true, 7: true, 8: true, 9: true},
+	}
+	if got := cm.SplitDisconnectedCommunities(communities); !reflect.DeepEqual(got, want) {
+		t.Fatalf("split = %v, want %v", got, want)
+	}
+
+	// connected communities are kept as they are
+	if got := cm.SplitDisconnectedCommunities(want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("split = %v, want %v", got, want)
+	}
+}
+
+func TestLouvainSplitDisconnectedOption(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 20, 0.3, 0.05)
+	communities, communityIDs, err := LouvainWithOptions(NewModularity(1.0, cm), nil, nil,
+		ClusteringOptions{MaxIters: 100, Seed: 1, SplitDisconnected: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.SplitDisconnectedCommunities(communities); !reflect.DeepEqual(got, communities) {
+		t.Fatalf("communities %v are not internally connected", communities)
+	}
+	for u, c := range communityIDs {
+		if !communities[c][u] {
+			t.Fatalf("community ID of %d is %d, but %v does not contain it", u, c, communities[c])
+		}
+	}
+}
@@ -54,12 +54,13 @@ package ConcurrenceBasedClustering
 // =============================================================================
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	//"math"
 	"math/rand"
-	"runtime"
 	"sort"
 	"sync"
 	"time"
@@ -75,6 +76,10 @@ func init() {
 // =============================================================================
 // struct ConcurrenceModel
 // brief description: This is a struct for concurrence models
+// note:
+//	Self-loops are not part of a concurrence model: a node's concurrence with
+//	itself is ignored by the constructors, the statistics, the quality models
+//	and DBScan, which counts a node's own cardinality separately.
 type ConcurrenceModel struct {
 	// ------------------------------------------------------------------------
 	// basic fields:
@@ -90,7 +95,8 @@ type ConcurrenceModel struct {
 
 // =============================================================================
 // func NewConcurrenceModel
-// brief description: create a new ConcurrenceModel object
+// brief description: create a new ConcurrenceModel object. Neighbors equal to
+//	the node itself are ignored.
 func NewConcurrenceModel(neighbors [][]int, sims [][]float64, cardinalities []int) ConcurrenceModel {
 	n := len(neighbors)
 	if n != len(sims) || n != len(cardinalities) {
@@ -111,6 +117,21 @@ func NewConcurrenceModel(neighbors [][]int, sims [][]float64, cardinalities []in
 			concurrences[i][neighbor] = sims[i][j]
 		}
 	}
+	return newConcurrenceModel(concurrences, cardinalities)
+}
+
+// =============================================================================
+// func newConcurrenceModel
+// brief description: create a new ConcurrenceModel object from a concurrence
+//	matrix, computing its statistical fields.
+// input:
+//	concurrences: a matrix that its element (i,j) is the frequency of the
+//		concurrence between node i and node j.
+//	cardinalities: the cardinality of each node.
+// output:
+//	the new ConcurrenceModel
+func newConcurrenceModel(concurrences []map[int]float64, cardinalities []int) ConcurrenceModel {
+	n := len(concurrences)
 	sumConcurrencesOf := GetSumConcurrencesOf(concurrences, cardinalities)
 	sumConcurrences := 0.0
 	for i := 0; i < n; i++ {
@@ -148,6 +169,9 @@ func GetSumConcurrencesOf(concurrences []map[int]float64, cardinalities []int) [
 		mySum := 0.0
 		weightsOfU := concurrences[u]
 		for v, weightUV := range weightsOfU {
+			if v == u {
+				continue
+			}
 			mySum += weightUV * float64(cardinalities[u]*cardinalities[v])
 		}
 		sumConcurrencesOf[u] = mySum
@@ -164,6 +188,14 @@ func (cm ConcurrenceModel) GetN() int {
 	return cm.n
 }
 
+// =============================================================================
+// func (cm ConcurrenceModel) GetCardinality
+// brief description: get the cardinality of a node, i.e. the number of original
+//	points it stands for after aggregations.
+func (cm ConcurrenceModel) GetCardinality(u int) int {
+	return cm.cardinalities[u]
+}
+
 // =============================================================================
 // func (cm ConcurrenceModel) GetConcurrencesOf
 // brief description: get the concurrences related to a node
@@ -196,56 +228,71 @@ func (cm ConcurrenceModel) GetConcurrence(i, j int) float64 {
 // func (cm ConcurrenceModel) Aggregate
 // brief description: aggregates concurrences according to communities
 // input:
-//	communities: a list of clusters.
+//	communities: a list of clusters. They must not overlap. They are only
+//		read.
 // output:
 //	the aggregated ConcurrenceModel
+// note:
+//	The cardinality of an aggregated node is the sum of the cardinalities of
+//	its members, and the concurrence between two aggregated nodes is chosen so
+//	that, weighted by their cardinalities, it equals the total weight between
+//	the two communities. The weights inside a community cannot be stored
+//	without self-loops, so the sum of concurrences of each aggregated node and
+//	the total sum of concurrences are inherited from the members instead of
+//	being recomputed. This keeps the quality differences between partitions of
+//	the aggregated model the same as between their flattened partitions.
 func (cm ConcurrenceModel) Aggregate(communities []map[int]bool) ConcurrenceModel {
 	// -------------------------------------------------------------------------
-	// step 1: set newN and create an empty newConcurrences
+	// step 1: set newN, create an empty newConcurrences, and find the
+	// community of each point
 	newN := len(communities)
 	newConcurrences := make([]map[int]float64, newN)
 	newCardinalities := make([]int, newN)
+	newSumConcurrencesOf := make([]float64, newN)
+	communityIDs := make([]int, cm.n)
+	for pt := 0; pt < cm.n; pt++ {
+		communityIDs[pt] = -1
+	}
 	for i := 0; i < newN; i++ {
 		newConcurrences[i] = map[int]float64{}
-		newCardinalities[i] = 1
+		for pt, _ := range communities[i] {
+			communityIDs[pt] = i
+			newCardinalities[i] += cm.cardinalities[pt]
+			newSumConcurrencesOf[i] += cm.sumConcurrencesOf[pt]
+		}
 	}
 
 	// -------------------------------------------------------------------------
-	// step 2: scans through the communities to fill newConcurrences
-	for i1 := 0; i1+1 < newN; i1++ {
-		c1 := communities[i1]
-		for i2 := i1 + 1; i2 < newN; i2++ {
-			c2 := communities[i2]
-			weightI1I2 := 0.0
-			for pt1, _ := range c1 {
-				weightsOfPt1 := cm.concurrences[pt1]
-				for pt2, _ := range c2 {
-					weightPt1Pt2, exists := weightsOfPt1[pt2]
-					if exists {
-						weightI1I2 += weightPt1Pt2 *
-							float64(cm.cardinalities[pt1]*cm.cardinalities[pt2])
-					}
-				}
-			}
-			if weightI1I2 > 0.0 {
-				newConcurrences[i1][i2] = weightI1I2
-				newConcurrences[i2][i1] = weightI1I2
+	// step 2: scans through the concurrences to sum the weights between
+	// communities
+	for pt1 := 0; pt1 < cm.n; pt1++ {
+		i1 := communityIDs[pt1]
+		if i1 < 0 {
+			continue
+		}
+		for pt2, weightPt1Pt2 := range cm.concurrences[pt1] {
+			i2 := communityIDs[pt2]
+			if i2 < 0 || i2 == i1 {
+				continue
 			}
+			newConcurrences[i1][i2] += weightPt1Pt2 *
+				float64(cm.cardinalities[pt1]*cm.cardinalities[pt2])
+		}
+	}
+	for i1 := 0; i1 < newN; i1++ {
+		for i2, weightI1I2 := range newConcurrences[i1] {
+			newConcurrences[i1][i2] = weightI1I2 /
+				float64(newCardinalities[i1]*newCardinalities[i2])
 		}
 	}
 
 	// -------------------------------------------------------------------------
 	// step 3: create a new ConcurrenceModel using these data
-	newSumConcurrencesOf := GetSumConcurrencesOf(newConcurrences, newCardinalities)
-	newSumConcurrences := 0.0
-	for i := 0; i < cm.n; i++ {
-		newSumConcurrences += newSumConcurrencesOf[i]
-	}
 	newCM := ConcurrenceModel{
 		n:                 newN,
 		concurrences:      newConcurrences,
 		cardinalities:     newCardinalities,
-		sumConcurrences:   newSumConcurrences,
+		sumConcurrences:   cm.sumConcurrences,
 		sumConcurrencesOf: newSumConcurrencesOf,
 	}
 
@@ -341,8 +388,17 @@ type QualityModel interface {
 // struct Modularity
 // brief introduction: this is an implementation of the famous Modularity
 // 	quality model for network clustering
+// note:
+//	sumConcurrences counts every concurrence in both directions, so it is the
+//	2m of the textbook definition Q = 1/(2m) sum_{i,j} (A_ij - r k_i k_j/(2m))
+//	delta(c_i, c_j). By default, Quality leaves out the terms with i == j, so
+//	it is larger than the textbook value by r sum_i k_i^2/(2m)^2, a constant
+//	for a given model. A Modularity created by NewModularityStandard reports
+//	the textbook value instead. Both variants have the same DeltaQuality, so
+//	Louvain finds the same communities with either of them.
 type Modularity struct {
-	r float64
+	r        float64
+	standard bool
 	ConcurrenceModel
 }
 
@@ -358,10 +414,24 @@ func NewModularity(r float64, cm ConcurrenceModel) Modularity {
 	}
 }
 
+// =============================================================================
+// func NewModularityStandard
+// brief description: create a new Modularity whose Quality is the textbook
+//	definition of Blondel et al., the same as igraph and NetworkX report.
+// input:
+//	r: the resolution of modularity
+func NewModularityStandard(r float64, cm ConcurrenceModel) Modularity {
+	return Modularity{
+		r:                r,
+		standard:         true,
+		ConcurrenceModel: cm,
+	}
+}
+
 // =============================================================================
 // func (qm *Modularity) Aggregate
 func (qm Modularity) Aggregate(communities []map[int]bool) QualityModel {
-	return QualityModel(Modularity{qm.r, qm.ConcurrenceModel.Aggregate(communities)})
+	return QualityModel(Modularity{qm.r, qm.standard, qm.ConcurrenceModel.Aggregate(communities)})
 }
 
 // =============================================================================
@@ -371,7 +441,17 @@ func (qm Modularity) Aggregate(communities []map[int]bool) QualityModel {
 //	communities: a list of clusters.
 // output:
 //	the value of Modularity
+// note:
+//	On a model made by Aggregate, the default variant leaves out the terms
+//	inside each aggregated node, so its Quality differs from the Quality of
+//	the flattened communities on the original model by a constant offset that
+//	only depends on the aggregation. Differences of Quality and DeltaQuality
+//	are the same on both models, and the standard variant has no offset.
 func (qm Modularity) Quality(communities []map[int]bool) float64 {
+	if qm.standard {
+		return qm.standardQuality(communities)
+	}
+
 	// -------------------------------------------------------------------------
 	// step 1: compute 1/m and r/m
 	oneOverM := 1.0 / float64(qm.sumConcurrences)
@@ -407,6 +487,40 @@ func (qm Modularity) Quality(communities []map[int]bool) float64 {
 	return result
 }
 
+// =============================================================================
+// func (qm Modularity) standardQuality
+// brief description: compute the textbook value of Modularity
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the value of Modularity
+func (qm Modularity) standardQuality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute 1/(2m)
+	oneOverTwoM := 1.0 / qm.sumConcurrences
+
+	// -------------------------------------------------------------------------
+	// step 2: compute modularity using the following equation:
+	// modularity = sum_c (2 w_c / (2m) - r (k_c / (2m))^2),
+	// where:
+	//	w_c is the weight inside c, including the weights inside the nodes of
+	//		an aggregated model,
+	//	k_c is the sum of nodewiseSumWeights over c.
+	result := 0.0
+	for _, c := range communities {
+		weightC, _ := qm.getCommunityWeightAndSize(c)
+		kc := 0.0
+		for u, _ := range c {
+			kc += qm.sumConcurrencesOf[u]
+		}
+		result += 2.0*weightC*oneOverTwoM - qm.r*kc*kc*oneOverTwoM*oneOverTwoM
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return result
+}
+
 // =============================================================================
 // func (qm *Modularity) DeltaQuality
 // brief description: this implements DeltaQuality for interface QualityModel
@@ -443,8 +557,9 @@ func (qm Modularity) DeltaQuality(communities []map[int]bool, u, oldCu, newCu in
 	//	c_u = the community ID of u, i.e., communities[c][u] == true
 	// therfore:
 	// delta modularity =
-	//	1/m sum_{j in community newCu} (w_{u,j} - k_u * k_j * r/m)
-	//	- 1/m sum_{j in community oldCu, j != i} (w_{u,j} - k_u * k_j * r/m)
+	//	2/m sum_{j in community newCu} (w_{u,j} - k_u * k_j * r/m)
+	//	- 2/m sum_{j in community oldCu, j != i} (w_{u,j} - k_u * k_j * r/m),
+	// where the factor 2 is because the sum has both (u,j) and (j,u).
 	// (3.1) fetch weights of u and k_u
 	weightsOfU := qm.GetConcurrencesOf(u)
 	ku := qm.sumConcurrencesOf[u]
@@ -474,7 +589,7 @@ func (qm Modularity) DeltaQuality(communities []map[int]bool, u, oldCu, newCu in
 		kj := qm.sumConcurrencesOf[j]
 		result -= weightUJ*float64(qm.cardinalities[u]*qm.cardinalities[j]) - rOverM*ku*kj
 	}
-	result *= oneOverM
+	result *= 2.0 * oneOverM
 
 	// -------------------------------------------------------------------------
 	// step 4: return the result
@@ -523,6 +638,12 @@ func (qm CPM) Aggregate(communities []map[int]bool) QualityModel {
 //	communities: a list of clusters.
 // output:
 //	the value of Modularity
+// note:
+//	On a model made by Aggregate, the weights inside each aggregated node
+//	cannot be stored, so Quality differs from the Quality of the flattened
+//	communities on the original model by a constant offset, twice the total
+//	weight inside the aggregated nodes. Differences of Quality and
+//	DeltaQuality are the same on both models.
 func (qm CPM) Quality(communities []map[int]bool) float64 {
 	// -------------------------------------------------------------------------
 	// step 1: compute CPM using the following equation:
@@ -542,6 +663,9 @@ func (qm CPM) Quality(communities []map[int]bool) float64 {
 		for i, _ := range c {
 			weightsOfI := qm.GetConcurrencesOf(i)
 			for j, _ := range c {
+				if i == j {
+					continue
+				}
 				weightIJ, exists := weightsOfI[j]
 				if exists {
 					sumWeightsOfC += weightIJ * float64(qm.cardinalities[i]*qm.cardinalities[j])
@@ -590,7 +714,9 @@ func (qm CPM) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) floa
 	//	- r ((size_newCu+card)^2 - size_newCu^2)
 	//	= delta w_oldCu + delta w_newCu - r (-2 size_oldCu * card + card^2)
 	//	- r (2 size_newCu * card + card^2)
-	//	= delta w_oldCu + delta w_newCu - 2 r * card * (size_newCu - size_oldCu + card)
+	//	= delta w_oldCu + delta w_newCu - 2 r * card * (size_newCu - size_oldCu + card),
+	// where delta w_c is twice the sum of weight(u,j) for j in c, because w_c
+	// has both weight(u,j) and weight(j,u).
 
 	// (2.1) fetch weights and card of u
 	weightsOfU := qm.GetConcurrencesOf(u)
@@ -624,7 +750,7 @@ func (qm CPM) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) floa
 	}
 
 	// (2.4) compute the result
-	result := deltaWOldCu + deltaWNewCu - 2.0*qm.r*float64(cardU*(sizeNewCu-sizeOldCu+cardU))
+	result := 2.0*(deltaWOldCu+deltaWNewCu) - 2.0*qm.r*float64(cardU*(sizeNewCu-sizeOldCu+cardU))
 
 	// -------------------------------------------------------------------------
 	// step 3: return the result
@@ -636,35 +762,88 @@ func (qm CPM) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) floa
 // brief description: This is part of an implementation to the famous DBScan
 //	algorithm: looking for all core points.
 // input:
-//	simMat: the similarity matrix. It must be symmetric, all elements 0~1, and
-//		the diagonal elements are all 1.
 //	eps: the radius of neighborhood.
 //	minPts: Only if the neighborhood of a point contains at least minPt points
 //		(the center point of the neighborhood included), the neighborhood is
 //		called dense. Only dense neighborhoods are connected to communities.
+//	numWorkers: the number of goroutines, <= 0 for runtime.NumCPU().
 // output:
 //	A map of core points to their neighborhood densities.
-func (cm ConcurrenceModel) getCorePoints(eps float64, minPts int) map[int]int {
+func (cm ConcurrenceModel) getCorePoints(eps float64, minPts int, numWorkers int) map[int]float64 {
 	// -------------------------------------------------------------------------
 	// step 1: compute the density of all points' neighborhoods
 	n := cm.n
 	densities := make([]int, n)
-	for pt := 0; pt < n; pt++ {
+	parallelFor(n, numWorkers, func(pt int) {
 		rowPt := cm.concurrences[pt]
 		density := cm.cardinalities[pt]
 		for neighbor, similarity := range rowPt {
+			if neighbor == pt {
+				continue
+			}
 			if similarity+eps >= 1.0 {
 				density += cm.cardinalities[neighbor]
 			}
 		}
 		densities[pt] = density
-	}
+	})
 
 	// -------------------------------------------------------------------------
 	// step 2: generate a list of points with dense neighborhoods
-	corePts := map[int]int{}
+	corePts := map[int]float64{}
 	for pt, density := range densities {
 		if density >= minPts {
+			corePts[pt] = float64(density)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return corePts
+}
+
+// =============================================================================
+// func getWeightedCorePoints
+// brief description: This is part of an implementation to the weighted DBScan
+//	algorithm: looking for all core points by weighted densities.
+// input:
+//	eps: the radius of neighborhood.
+//	minWeight: Only if the weighted density of the neighborhood of a point is
+//		at least minWeight, the neighborhood is called dense.
+//	numWorkers: the number of goroutines, <= 0 for runtime.NumCPU().
+// output:
+//	A map of core points to their weighted neighborhood densities.
+// note:
+//	The weighted density of the neighborhood of a point is the sum of the
+//	similarities of its neighbors, each multiplied by the cardinality of the
+//	neighbor, plus the cardinality of the point itself, whose similarity to
+//	itself is 1. So it equals the density of getCorePoints when all
+//	similarities are 1.
+func (cm ConcurrenceModel) getWeightedCorePoints(eps float64, minWeight float64, numWorkers int,
+) map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute the weighted density of all points' neighborhoods
+	n := cm.n
+	densities := make([]float64, n)
+	parallelFor(n, numWorkers, func(pt int) {
+		rowPt := cm.concurrences[pt]
+		density := float64(cm.cardinalities[pt])
+		for neighbor, similarity := range rowPt {
+			if neighbor == pt {
+				continue
+			}
+			if similarity+eps >= 1.0 {
+				density += similarity * float64(cm.cardinalities[neighbor])
+			}
+		}
+		densities[pt] = density
+	})
+
+	// -------------------------------------------------------------------------
+	// step 2: generate a list of points with dense neighborhoods
+	corePts := map[int]float64{}
+	for pt, density := range densities {
+		if density >= minWeight {
 			corePts[pt] = density
 		}
 	}
@@ -681,23 +860,28 @@ func (cm ConcurrenceModel) getCorePoints(eps float64, minPts int) map[int]int {
 //	neighbors for each core points.
 // input:
 //	eps: the radius of neighborhood.
-//	minPts: Only if the neighborhood of a point contains at least minPt points
-//		(the center point of the neighborhood included), the neighborhood is
-//		called dense. Only dense neighborhoods are connected to communities.
 //	corePts: a map of core points to their neighborhood densities.
+//	numWorkers: the number of goroutines, <= 0 for runtime.NumCPU().
 // output:
 //	output 1: a list of the core neighbors for each core point.
 //	output 2: a list of the noncore neighbors for each core point.
-func (cm ConcurrenceModel) getNeighbors(eps float64, minPts int, corePts map[int]int) (
-	coreNeighbors map[int]map[int]bool, noncoreNeighbors map[int]map[int]bool) {
-	coreNeighbors = map[int]map[int]bool{}
-	noncoreNeighbors = map[int]map[int]bool{}
+func (cm ConcurrenceModel) getNeighbors(eps float64, corePts map[int]float64,
+	numWorkers int) (coreNeighbors map[int]map[int]bool, noncoreNeighbors map[int]map[int]bool) {
+	// create the rows of the results, so that the goroutines below only read
+	// the maps of the results
+	corePtList := make([]int, 0, len(corePts))
+	coreNeighbors = make(map[int]map[int]bool, len(corePts))
+	noncoreNeighbors = make(map[int]map[int]bool, len(corePts))
 	for pt, _ := range corePts {
-		// create the rows of the results
-		coreRow := map[int]bool{}
-		coreNeighbors[pt] = coreRow
-		noncoreRow := map[int]bool{}
-		noncoreNeighbors[pt] = noncoreRow
+		corePtList = append(corePtList, pt)
+		coreNeighbors[pt] = map[int]bool{}
+		noncoreNeighbors[pt] = map[int]bool{}
+	}
+
+	parallelFor(len(corePtList), numWorkers, func(idxPt int) {
+		pt := corePtList[idxPt]
+		coreRow := coreNeighbors[pt]
+		noncoreRow := noncoreNeighbors[pt]
 
 		// read the row of similarity matrix
 		simRow := cm.concurrences[pt]
@@ -718,7 +902,7 @@ func (cm ConcurrenceModel) getNeighbors(eps float64, minPts int, corePts map[int
 				}
 			}
 		}
-	}
+	})
 	return
 }
 
@@ -730,12 +914,146 @@ func (cm ConcurrenceModel) getNeighbors(eps float64, minPts int, corePts map[int
 //	minPts: Only if the neighborhood of a point contains at least minPt points
 //		(the center point of the neighborhood included), the neighborhood is
 //		called dense. Only dense neighborhoods are connected to communities.
-//	simType: the type of similarity, 0 for simple induced similarity, 1 for normalized
-//		similarity, 2 for jaccard similarity, 4 for weighted jaccard similarity, 4 for
-//		normalized jaccard similarity
 // output:
-//	A list of clusters.
+//	output 1: A list of clusters, ordered by their smallest members.
+//	output 2: the community ID of each point.
+// note:
+//	The concurrences are used directly as the similarity matrix. They must be
+//	symmetric and all elements 0~1.
 func (cm ConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, []int) {
+	communities, communityIDs, _ := cm.dbscan(context.Background(), eps,
+		cm.getCorePoints(eps, minPts, 0), ClusteringOptions{})
+	return communities, communityIDs
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DBScanWithOptions
+// brief description: DBScan with options. Only opts.Progress and
+//	opts.NumWorkers are used. See DBScan.
+func (cm ConcurrenceModel) DBScanWithOptions(eps float64, minPts int, opts ClusteringOptions,
+) ([]map[int]bool, []int) {
+	communities, communityIDs, _ := cm.dbscan(context.Background(), eps,
+		cm.getCorePoints(eps, minPts, opts.NumWorkers), opts)
+	return communities, communityIDs
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DBScanCtx
+// brief description: DBScan that can be cancelled. See DBScan.
+// input:
+//	ctx: the context. It is checked before each cluster is expanded.
+//	eps: the radius of neighborhood.
+//	minPts: the minimum density of core points.
+// output:
+//	output 1: A list of clusters. When ctx is done, they are the clusters
+//		expanded so far, without the isolated points.
+//	output 2: the community ID of each point, -1 for the points not in the
+//		clusters expanded so far when ctx is done.
+//	output 3: ctx.Err() if ctx is done, nil otherwise
+func (cm ConcurrenceModel) DBScanCtx(ctx context.Context, eps float64, minPts int,
+) ([]map[int]bool, []int, error) {
+	return cm.dbscan(ctx, eps, cm.getCorePoints(eps, minPts, 0), ClusteringOptions{})
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) WeightedDBScan
+// brief description: DBScan with weighted densities, as in generalized DBSCAN:
+//	a point is a core point if the sum of the similarities of its neighbors,
+//	rather than their number, reaches a threshold, so that a point with
+//	strong neighbors is denser than a point with as many weak ones. The
+//	expansion of clusters and the border points are the same as in DBScan.
+// input:
+//	eps: the radius of neighborhood.
+//	minWeight: the minimum weighted density of core points. See
+//		getWeightedCorePoints.
+// output:
+//	output 1: A list of clusters, ordered by their smallest members.
+//	output 2: the community ID of each point.
+// note:
+//	The concurrences are used directly as the similarity matrix, as in DBScan.
+//	Each point counts itself with similarity 1, and each neighbor is weighted
+//	by its cardinality, so with all similarities 1 this is DBScan with
+//	minPts = minWeight.
+func (cm ConcurrenceModel) WeightedDBScan(eps float64, minWeight float64) ([]map[int]bool, []int) {
+	communities, communityIDs, _ := cm.dbscan(context.Background(), eps,
+		cm.getWeightedCorePoints(eps, minWeight, 0), ClusteringOptions{})
+	return communities, communityIDs
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DensityReachable
+// brief description: find the points density-reachable from a point, i.e. the
+//	DBScan cluster grown from it, without running DBScan on all points.
+// input:
+//	start: the start point, 0 <= start < n.
+//	eps: the radius of neighborhood, as in DBScan.
+//	minPts: the minimum density of core points, as in DBScan.
+// output:
+//	the points reachable from start through chains of neighboring core
+//	points, start included. If start is a core point, this is its cluster in
+//	DBScan, including all border points within eps of the cluster, so a
+//	border point shared with another cluster is included here even if DBScan
+//	assigns it to the other one. If start is not a core point, only start is
+//	returned.
+// note:
+//	Only the neighborhoods of the points reached are scanned, so it costs
+//	O(sum of their degrees) instead of O(number of concurrences).
+func (cm ConcurrenceModel) DensityReachable(start int, eps float64, minPts int) map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: check the input
+	if start < 0 || start >= cm.n {
+		log.Fatalln(fmt.Sprintf("start = %d is out of range [0, %d)", start, cm.n))
+	}
+	getNeighborsOf := func(pt int) ([]int, bool) {
+		neighbors := []int{}
+		density := cm.cardinalities[pt]
+		for neighbor, similarity := range cm.concurrences[pt] {
+			if neighbor != pt && similarity+eps >= 1.0 {
+				neighbors = append(neighbors, neighbor)
+				density += cm.cardinalities[neighbor]
+			}
+		}
+		return neighbors, density >= minPts
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: expand from start through core points
+	result := map[int]bool{start: true}
+	boundary := []int{start}
+	for len(boundary) > 0 {
+		pt := boundary[len(boundary)-1]
+		boundary = boundary[:len(boundary)-1]
+		neighbors, isCore := getNeighborsOf(pt)
+		if !isCore {
+			continue
+		}
+		for _, neighbor := range neighbors {
+			if !result[neighbor] {
+				result[neighbor] = true
+				boundary = append(boundary, neighbor)
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) dbscan
+// brief description: the implementation of DBScan, DBScanWithOptions,
+//	DBScanCtx and WeightedDBScan.
+// input:
+//	ctx: the context. See DBScanCtx.
+//	eps: the radius of neighborhood.
+//	corePts: a map of core points to their neighborhood densities, e.g. the
+//		output of getCorePoints.
+//	opts: the options. See DBScanWithOptions.
+// output:
+//	the same as DBScanCtx
+func (cm ConcurrenceModel) dbscan(ctx context.Context, eps float64, corePts map[int]float64,
+	opts ClusteringOptions) ([]map[int]bool, []int, error) {
 	// -------------------------------------------------------------------------
 	// step 1: initialize auxiliary data structures
 	communityIDs := make([]int, cm.n)
@@ -744,25 +1062,28 @@ func (cm ConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, []in
 		communityIDs[i] = -1
 	}
 
-	// -------------------------------------------------------------------------
-	// step 3: find all core points and their neighborhood densities
-	corePts := cm.getCorePoints(eps, minPts)
-
 	// -------------------------------------------------------------------------
 	// step 4: find neighbors for each core point
-	coreNeighbors, noncoreNeighbors := cm.getNeighbors(eps, minPts, corePts)
+	coreNeighbors, noncoreNeighbors := cm.getNeighbors(eps, corePts, opts.NumWorkers)
 
 	// -------------------------------------------------------------------------
 	// step 5: loop until all core points are in communities
 	n := cm.n
 	for {
+		// (5.0) stop if the context is done
+		err := ctx.Err()
+		if err != nil {
+			communities = canonicalizeCommunities(communities, communityIDs)
+			return communities, communityIDs, err
+		}
+
 		// (5.1) prepare an ID for the new community
 		c := len(communities)
 
 		// (5.2) find the densist unassigned core point as the center point of
 		// the new cluster
 		centerPt := n
-		centerDensity := 0
+		centerDensity := 0.0
 		for pt, density := range corePts {
 			// skip those points that have already been assigned into community
 			if communityIDs[pt] >= 0 {
@@ -818,6 +1139,11 @@ func (cm ConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, []in
 			}
 			boundary = newBoundary
 		}
+
+		// (5.6) report the new community
+		if opts.Progress != nil {
+			opts.Progress("dbscan", c, 0.0, len(newCommunity))
+		}
 	}
 
 	// -------------------------------------------------------------------------
@@ -831,8 +1157,54 @@ func (cm ConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, []in
 	}
 
 	// -------------------------------------------------------------------------
-	// step 7: return the result
-	return communities, communityIDs
+	// step 7: return the result in the canonical order
+	communities = canonicalizeCommunities(communities, communityIDs)
+	return communities, communityIDs, nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DBScanWithSim
+// brief description: DBScan over an externally computed similarity matrix
+//	instead of the concurrences. The cardinalities of the model are still
+//	used to compute the neighborhood densities. Computing the matrix once and
+//	passing it here avoids recomputing it for several runs.
+// input:
+//	eps: the radius of neighborhood.
+//	minPts: Only if the neighborhood of a point contains at least minPt points
+//		(the center point of the neighborhood included), the neighborhood is
+//		called dense. Only dense neighborhoods are connected to communities.
+//	simMat: the similarity matrix, e.g. cm.InduceCosineSimilarities(). It must
+//		have n rows, be symmetric and all elements 0~1.
+// output:
+//	output 1: A list of clusters.
+//	output 2: the community ID of each point.
+func (cm ConcurrenceModel) DBScanWithSim(eps float64, minPts int, simMat []map[int]float64,
+) ([]map[int]bool, []int) {
+	if len(simMat) != cm.n {
+		log.Fatalln(fmt.Sprintf("len(simMat) = %d != n = %d in DBScanWithSim",
+			len(simMat), cm.n))
+	}
+	return newConcurrenceModel(simMat, cm.cardinalities).DBScan(eps, minPts)
+}
+
+// =============================================================================
+// func copyCommunities
+// brief description: make a deep copy of communities, which is the defensive
+//	copy made by the algorithms that modify communities in place.
+// input:
+//	communities: a list of clusters.
+// output:
+//	a new list of new clusters with the same members
+func copyCommunities(communities []map[int]bool) []map[int]bool {
+	result := make([]map[int]bool, len(communities))
+	for idxC, c := range communities {
+		newC := make(map[int]bool, len(c))
+		for u, isMember := range c {
+			newC[u] = isMember
+		}
+		result[idxC] = newC
+	}
+	return result
 }
 
 // =============================================================================
@@ -843,7 +1215,7 @@ func (cm ConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, []in
 //	aggCommunities: the aggregated concurrence graph's communities
 //	communities: the original concurrence graph's communities
 // output:
-//	the flatten communities
+//	the flatten communities. They are new maps, and the inputs are only read.
 func flattenCommunities(aggCommunities, communities []map[int]bool,
 ) []map[int]bool {
 	result := []map[int]bool{}
@@ -860,6 +1232,138 @@ func flattenCommunities(aggCommunities, communities []map[int]bool,
 	return result
 }
 
+// =============================================================================
+// struct ClusteringOptions
+// brief description: This is a struct for the options of the clustering
+//	optimizers.
+type ClusteringOptions struct {
+	// MaxIters is the maximum number of iterations of the local moves.
+	MaxIters int
+
+	// MaxLevels, if > 0, is the maximum number of levels of the multi-level
+	// Louvain: it stops aggregating after that many levels, so its coarsest
+	// level may be finer than the converged one. The default 0 means no
+	// limit. It is ignored by the single-level optimizers.
+	MaxLevels int
+
+	// Epsilon is the tolerance of quality gains: a move is accepted only if
+	// its quality gain is > Epsilon. It avoids sweeps that only chase
+	// floating-point noise. The default 0 accepts any positive gain. The
+	// gains are in the units of Quality, i.e. the exact change of Quality by
+	// the move. Earlier versions of Modularity.DeltaQuality returned half of
+	// that change, and CPM.DeltaQuality counted its weight term once, so a
+	// threshold tuned against them must be about doubled.
+	Epsilon float64
+
+	// Timeout is the wall-clock limit of an optimizer. When it is exceeded,
+	// the optimizer stops after the current iteration and returns its current
+	// partition with ErrTimeout. The default 0 means no limit.
+	Timeout time.Duration
+
+	// Progress, if not nil, is called synchronously after each step of an
+	// optimizer, with the name of the optimizer as stage. Louvain calls it
+	// after each iteration with the iteration number, the quality after the
+	// iteration and the number of moved points. DBScan calls it after each
+	// cluster is expanded with the cluster number, 0 and the size of the
+	// cluster. The multi-level Louvain also calls it after each level with
+	// stage "louvain-level", the level number, the quality of the level over
+	// the original nodes and the number of communities of the level. AHC calls
+	// it every 1000 merges and after the last one with stage "ahc", the number
+	// of merges, the distance of the latest merge and the size of the cluster
+	// it made, see AHCDendrogramWithOptions. Computing the quality for it
+	// costs a full evaluation of the quality model, which is skipped when it
+	// is nil.
+	Progress func(stage string, sweep int, quality float64, moved int)
+
+	// NumWorkers is the number of goroutines of the parallel steps, i.e. the
+	// local moves of Louvain and the neighborhood scans of DBScan. The default
+	// 0 uses runtime.NumCPU(). The results do not depend on it.
+	NumWorkers int
+
+	// SplitDisconnected makes Louvain split its communities that are not
+	// internally connected into their connected components before returning
+	// them. See ConcurrenceModel.SplitDisconnectedCommunities.
+	SplitDisconnected bool
+
+	// MaxCommunitySize, if > 0, is the maximum size of communities: Louvain
+	// rejects the moves that would make a community larger. Sizes are sums of
+	// node cardinalities, so on the aggregated levels of the multi-level
+	// Louvain they count the original points, and two communities whose
+	// combined size exceeds the maximum are never merged. Input communities
+	// that are already larger are kept, but never grow.
+	MaxCommunitySize int
+
+	// MinCommunitySize, if > 0, is the minimum size of communities: before
+	// returning, Louvain merges each smaller community into the neighboring
+	// community it has the largest total concurrence with, as long as the
+	// merge respects MaxCommunitySize. Communities that cannot be merged this
+	// way, e.g. isolated ones, are returned as they are, and can be found by
+	// their sizes. The multi-level Louvain applies it to the partition of
+	// each level it returns, not to the nodes aggregated into the next level,
+	// so with it, a level is not always a coarsening of the previous one.
+	MinCommunitySize int
+
+	// FrozenCommunities, if not nil, are seed communities that Louvain may
+	// grow but never break: their members start in them and never move,
+	// while other nodes may join them, and no two of them are ever merged,
+	// neither by the local moves nor by the aggregation of the multi-level
+	// Louvain. They must not overlap. Their members are taken out of the
+	// input communities. Use SeedIndices to find which output community
+	// grows from which seed.
+	FrozenCommunities []map[int]bool
+
+	// Seed, if not 0, makes the random choices of Louvain reproducible: each
+	// point draws its random numbers from the seed, the iteration and its ID,
+	// so the results do not depend on NumWorkers or the scheduling of the
+	// goroutines either. The default 0 uses the shared math/rand source.
+	Seed int64
+
+	// exhaustiveMoves makes Louvain evaluate the moves of each point to every
+	// community instead of the communities of its neighbors only, as it did
+	// before. It is only used to test that the results are the same.
+	exhaustiveMoves bool
+}
+
+// =============================================================================
+// func mixSeed
+// brief description: mix integers into a well-distributed 64-bit value with the
+//	finalizer of SplitMix64.
+func mixSeed(seed int64, values ...int) uint64 {
+	x := uint64(seed)
+	for _, value := range values {
+		x ^= (uint64(value) + 1) * 0x9E3779B97F4A7C15
+		x ^= x >> 30
+		x *= 0xBF58476D1CE4E5B9
+		x ^= x >> 27
+		x *= 0x94D049BB133111EB
+		x ^= x >> 31
+	}
+	return x
+}
+
+// =============================================================================
+// func getRandomFloat64
+// brief description: get a random number within [0, 1) for a point in an
+//	iteration. See ClusteringOptions.Seed.
+// input:
+//	seed: the seed, 0 for the shared math/rand source.
+//	iter: the iteration
+//	u: the point ID
+// output:
+//	the random number, a function of seed, iter and u if seed is not 0.
+func getRandomFloat64(seed int64, iter, u int) float64 {
+	if seed == 0 {
+		return rand.Float64()
+	}
+	return float64(mixSeed(seed, iter, u)>>11) / (1 << 53)
+}
+
+// =============================================================================
+// var ErrTimeout
+// brief description: the error returned by optimizers stopped by the Timeout
+//	of their options. Their results are still valid partitions.
+var ErrTimeout = errors.New("the optimizer stopped early because of timeout")
+
 // =============================================================================
 // func Louvain
 // brief description: Louvain algorithm for partition optimization of
@@ -867,17 +1371,383 @@ func flattenCommunities(aggCommunities, communities []map[int]bool,
 // input:
 //	qm: a quality model.
 //	communities: a list of clusters.
-//	opts: an optional list of options
+//	communityIDs: the community ID of each point.
+//	maxIters: the maximum number of iterations.
 // output:
-//	the optimized communities that maximizes quality
+//	the optimized communities that maximizes quality, nil if the input
+//	communities are invalid. LouvainWithOptions reports why.
 // note:
 //	If the input communities is empty, this function will act as the classical
 //	Louvain algorithm that uses single point communities as the initial
 //	communities.
+//	Louvain drops the error of LouvainWithOptions, so a nil result is the only
+//	sign of invalid input. Use LouvainWithOptions to get the error.
 func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, maxIters int,
 ) ([]map[int]bool, []int) {
+	communities, communityIDs, _ = LouvainWithOptions(qm, communities, communityIDs,
+		ClusteringOptions{MaxIters: maxIters})
+	return communities, communityIDs
+}
+
+// =============================================================================
+// func LouvainWithOptions
+// brief description: Louvain algorithm with options. See Louvain.
+// input:
+//	qm: a quality model.
+//	communities: a list of clusters.
+//	communityIDs: the community ID of each point.
+//	opts: the options.
+// output:
+//	output 1: the optimized communities that maximizes quality, ordered by
+//		their smallest members
+//	output 2: the community ID of each point
+//	output 3: an error if the input communities are invalid, see LouvainCtx,
+//		ErrTimeout if opts.Timeout is exceeded, nil otherwise
+// note:
+//	Besides the existing communities, a point may also move into a new empty
+//	community, so the result may have more communities than the input.
+func LouvainWithOptions(qm QualityModel, communities []map[int]bool, communityIDs []int,
+	opts ClusteringOptions) ([]map[int]bool, []int, error) {
+	return LouvainCtx(context.Background(), qm, communities, communityIDs, opts)
+}
+
+// =============================================================================
+// func LouvainWithQuality
+// brief description: Louvain algorithm with options that also reports the
+//	quality of its result. See LouvainWithOptions.
+// output:
+//	output 1: the optimized communities that maximizes quality
+//	output 2: the community ID of each point
+//	output 3: the quality of output 1, i.e. qm.Quality(output 1)
+//	output 4: see LouvainWithOptions
+// note:
+//	The quality is evaluated once on the result, rather than accumulated from
+//	the gains of the moves, because the gains of simultaneous moves only add
+//	up for quality models that are sums over communities.
+func LouvainWithQuality(qm QualityModel, communities []map[int]bool, communityIDs []int,
+	opts ClusteringOptions) ([]map[int]bool, []int, float64, error) {
+	communities, communityIDs, err := LouvainWithOptions(qm, communities, communityIDs, opts)
+	if communities == nil {
+		return nil, nil, 0.0, err
+	}
+	return communities, communityIDs, qm.Quality(communities), err
+}
+
+// =============================================================================
+// func getNodeSizes
+// brief description: get the size of each node of a quality model, i.e. its
+//	cardinality if the model has cardinalities, as all models built on
+//	ConcurrenceModel do, or 1 otherwise.
+func getNodeSizes(qm QualityModel) []int {
+	n := qm.GetN()
+	sizes := make([]int, n)
+	cardinalityModel, hasCardinalities := qm.(interface{ GetCardinality(u int) int })
+	for u := 0; u < n; u++ {
+		if hasCardinalities {
+			sizes[u] = cardinalityModel.GetCardinality(u)
+		} else {
+			sizes[u] = 1
+		}
+	}
+	return sizes
+}
+
+// =============================================================================
+// func getCommunitySizes
+// brief description: get the size of each community, i.e. the sum of the sizes
+//	of its nodes.
+func getCommunitySizes(communities []map[int]bool, nodeSizes []int) []int {
+	sizes := make([]int, len(communities))
+	for idxC, c := range communities {
+		for u, _ := range c {
+			sizes[idxC] += nodeSizes[u]
+		}
+	}
+	return sizes
+}
+
+// =============================================================================
+// func mergeUndersizedCommunities
+// brief description: merge each community smaller than minSize into the
+//	neighboring community it has the largest total concurrence with.
+// input:
+//	qm: a quality model.
+//	communities: a list of clusters. It is modified in place.
+//	nodeSizes: the size of each node. See getNodeSizes.
+//	seedOf: the frozen community of each node, see getSeedOf. It may be nil.
+//		Two communities containing frozen communities are never merged.
+//	minSize: the minimum size of communities.
+//	maxSize: the maximum size of communities, <= 0 for no limit. Merges that
+//		would exceed it are not done.
+// output:
+//	the communities without empty ones. Small communities that cannot be
+//	merged are kept.
+func mergeUndersizedCommunities(qm QualityModel, communities []map[int]bool, nodeSizes []int,
+	seedOf []int, minSize, maxSize int) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: find the community of each node, and the size and the seed of
+	// each community
+	communityIDs := make([]int, qm.GetN())
+	for u := 0; u < len(communityIDs); u++ {
+		communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			communityIDs[u] = idxC
+		}
+	}
+	sizes := getCommunitySizes(communities, nodeSizes)
+	seeds := make([]int, len(communities))
+	for idxC, c := range communities {
+		seeds[idxC] = -1
+		if seedOf != nil {
+			seeds[idxC] = getSeedOfCommunity(c, seedOf)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: merge small communities until no merge happens in a pass
+	for {
+		merged := false
+		for idxC, c := range communities {
+			if len(c) == 0 || sizes[idxC] >= minSize {
+				continue
+			}
+
+			// (2.1) find the allowed neighboring community with the largest weight
+			weights := map[int]float64{}
+			for u, _ := range c {
+				for v, weightUV := range qm.GetNeighbors(u) {
+					idxD := communityIDs[v]
+					if idxD >= 0 && idxD != idxC {
+						weights[idxD] += weightUV
+					}
+				}
+			}
+			best := -1
+			for idxD, weight := range weights {
+				if maxSize > 0 && sizes[idxC]+sizes[idxD] > maxSize {
+					continue
+				}
+				if seeds[idxC] >= 0 && seeds[idxD] >= 0 {
+					continue
+				}
+				if best < 0 || weight > weights[best] || (weight == weights[best] && idxD < best) {
+					best = idxD
+				}
+			}
+			if best < 0 {
+				continue
+			}
+
+			// (2.2) merge it
+			for u, _ := range c {
+				communities[best][u] = true
+				communityIDs[u] = best
+			}
+			communities[idxC] = map[int]bool{}
+			sizes[best] += sizes[idxC]
+			sizes[idxC] = 0
+			if seeds[idxC] >= 0 {
+				seeds[best] = seeds[idxC]
+			}
+			merged = true
+		}
+		if !merged {
+			break
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the non-empty communities
+	result := make([]map[int]bool, 0, len(communities))
+	for _, c := range communities {
+		if len(c) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func getSeedOf
+// brief description: get the index of the frozen community of each node
+// input:
+//	n: the number of nodes
+//	frozen: the frozen communities. They must not overlap or be empty.
+// output:
+//	output 1: the index of the frozen community of each node, -1 for nodes
+//		not in any of them. It is nil if there are no frozen communities.
+//	output 2: an error if a frozen community is empty, has a node out of
+//		range or overlaps another one, in which case output 1 is nil
+func getSeedOf(n int, frozen []map[int]bool) ([]int, error) {
+	if len(frozen) == 0 {
+		return nil, nil
+	}
+	seedOf := make([]int, n)
+	for u := 0; u < n; u++ {
+		seedOf[u] = -1
+	}
+	for idxSeed, seed := range frozen {
+		if len(seed) == 0 {
+			return nil, fmt.Errorf("frozen community %d is empty", idxSeed)
+		}
+		for u, _ := range seed {
+			if u < 0 || u >= n {
+				return nil, fmt.Errorf("node %d of frozen community %d is out of range [0, %d)",
+					u, idxSeed, n)
+			}
+			if seedOf[u] >= 0 {
+				return nil, fmt.Errorf("node %d is in both frozen community %d and %d",
+					u, seedOf[u], idxSeed)
+			}
+			seedOf[u] = idxSeed
+		}
+	}
+	return seedOf, nil
+}
+
+// =============================================================================
+// func getSeedOfCommunity
+// brief description: get the index of the frozen community in a community
+// input:
+//	c: a community that does not split frozen communities
+//	seedOf: the output of getSeedOf
+// output:
+//	the index of the frozen community in c, -1 if there is none.
+func getSeedOfCommunity(c map[int]bool, seedOf []int) int {
+	for u, _ := range c {
+		if seedOf[u] >= 0 {
+			return seedOf[u]
+		}
+	}
+	return -1
+}
+
+// =============================================================================
+// func applyFrozenCommunities
+// brief description: move the members of frozen communities out of their
+//	communities and into communities of their own.
+// input:
+//	communities: a list of clusters. It is not modified.
+//	frozen: the frozen communities
+//	seedOf: the output of getSeedOf for frozen
+// output:
+//	a new list of clusters with the frozen communities first, in their order,
+//	followed by the non-empty rest of the input communities.
+func applyFrozenCommunities(communities, frozen []map[int]bool, seedOf []int) []map[int]bool {
+	result := make([]map[int]bool, 0, len(frozen)+len(communities))
+	for _, seed := range frozen {
+		newC := make(map[int]bool, len(seed))
+		for u, _ := range seed {
+			newC[u] = true
+		}
+		result = append(result, newC)
+	}
+	for _, c := range communities {
+		newC := map[int]bool{}
+		for u, _ := range c {
+			if seedOf[u] < 0 {
+				newC[u] = true
+			}
+		}
+		if len(newC) > 0 {
+			result = append(result, newC)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func SeedIndices
+// brief description: find which frozen community each community grows from,
+//	e.g. for the output of Louvain with ClusteringOptions.FrozenCommunities.
+// input:
+//	communities: a list of clusters.
+//	frozen: the frozen communities, i.e. the seeds.
+// output:
+//	for each community, the index of the seed it contains, or -1 if it is a
+//	new community containing no seed.
+func SeedIndices(communities, frozen []map[int]bool) []int {
+	result := make([]int, len(communities))
+	for idxC, c := range communities {
+		result[idxC] = -1
+		for idxSeed, seed := range frozen {
+			for u, _ := range seed {
+				if c[u] {
+					result[idxC] = idxSeed
+				}
+				break
+			}
+			if result[idxC] >= 0 {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func validateCommunityIDs
+// brief description: check that communities partition the nodes 0..n-1 and
+//	that communityIDs agree with them.
+// input:
+//	communities: a list of clusters. Empty clusters are allowed.
+//	communityIDs: the community ID of each node
+//	n: the number of nodes
+// output:
+//	an error if a node is out of range, in several communities or in none, or
+//	if communityIDs has a wrong length or a wrong community ID, nil otherwise
+func validateCommunityIDs(communities []map[int]bool, communityIDs []int, n int) error {
+	assignment, err := getAssignment(communities, n)
+	if err != nil {
+		return err
+	}
+	if len(communityIDs) != n {
+		return fmt.Errorf("len(communityIDs) = %d != n = %d", len(communityIDs), n)
+	}
+	for u := 0; u < n; u++ {
+		if communityIDs[u] != assignment[u] {
+			return fmt.Errorf("communityIDs[%d] = %d, but node %d is in community %d",
+				u, communityIDs[u], u, assignment[u])
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// func LouvainCtx
+// brief description: Louvain algorithm with options that can be cancelled. See
+//	LouvainWithOptions.
+// input:
+//	ctx: the context. It is checked before each iteration.
+//	qm: a quality model.
+//	communities: a list of clusters.
+//	communityIDs: the community ID of each point.
+//	opts: the options.
+// output:
+//	output 1: the optimized communities that maximizes quality. When ctx is
+//		done, they are the valid partition reached so far.
+//	output 2: the community ID of each point
+//	output 3: an error if communities and communityIDs are not nil and do not
+//		form a valid partition, see validateCommunityIDs, or if
+//		opts.FrozenCommunities are invalid, see getSeedOf, in which case
+//		outputs 1 and 2 are nil. Otherwise, ctx.Err() if ctx is done,
+//		ErrTimeout if opts.Timeout is exceeded, nil otherwise.
+// note:
+//	The input communities and communityIDs are copied and never modified, so
+//	the same input can be passed to several algorithms. The outputs are owned
+//	by the caller.
+//	Each iteration computes the best move of every point in parallel, and
+//	applies all of them in the order of descending gains, ties broken by
+//	smaller node IDs, skipping the moves out of or into a community already
+//	changed in the iteration. So an iteration costs O(m + n log(n)) for the
+//	quality models caching DeltaQuality, rather than one move per scan.
+func LouvainCtx(ctx context.Context, qm QualityModel, communities []map[int]bool,
+	communityIDs []int, opts ClusteringOptions) ([]map[int]bool, []int, error) {
 	// -------------------------------------------------------------------------
-	// step 1: initialize communities and communityIDs if they are nil
+	// step 1: initialize communities and communityIDs if they are nil, or check
+	// and copy them otherwise, since they are modified in place below
 	n := qm.GetN()
 	if communities == nil || communityIDs == nil {
 		communities = make([]map[int]bool, n)
@@ -886,13 +1756,33 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 			communities[i] = map[int]bool{i: true}
 			communityIDs[i] = i
 		}
+	} else {
+		err := validateCommunityIDs(communities, communityIDs, n)
+		if err != nil {
+			return nil, nil, err
+		}
+		communities = copyCommunities(communities)
+		communityIDs = append([]int(nil), communityIDs...)
+	}
+	seedOf, err := getSeedOf(n, opts.FrozenCommunities)
+	if err != nil {
+		return nil, nil, err
+	}
+	if seedOf != nil {
+		communities = applyFrozenCommunities(communities, opts.FrozenCommunities, seedOf)
+		for c, community := range communities {
+			for u, _ := range community {
+				communityIDs[u] = c
+			}
+		}
 	}
 
 	// -------------------------------------------------------------------------
 	// step 2: iteratively scan through the points to find out what is the best
 	// community for a point. If all points are in their best communities, stop
 	// the iteration.
-	numCPUs := runtime.NumCPU()
+	numCPUs := getNumWorkers(opts.NumWorkers)
+	nodeSizes := getNodeSizes(qm)
 	var wg sync.WaitGroup
 	type MergeRequest struct {
 		dst  int
@@ -905,87 +1795,104 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 	mergeRequests := make([]MergeRequest, n)
 	mergeOrders := make([]int, n)
 	numIters := 0
-	for iter := 0; iter < maxIters; iter++ {
-		// (2.1) compute merge requests
+	startTime := time.Now()
+	for iter := 0; iter < opts.MaxIters; iter++ {
+		// (2.0) stop if the context is done or the time is up. The goroutines of
+		// each iteration have all finished at this point.
+		err = ctx.Err()
+		if err != nil {
+			break
+		}
+		if opts.Timeout > 0 && time.Since(startTime) >= opts.Timeout {
+			err = ErrTimeout
+			break
+		}
+
+		// (2.1) compute merge requests. An empty community is appended as a
+		// move target, so that a point can split off from its community. It is
+		// removed again with the other empty communities after the moves.
+		communities = append(communities, map[int]bool{})
+		emptyC := len(communities) - 1
 		m := len(communities)
+		var communitySizes []int
+		if opts.MaxCommunitySize > 0 {
+			communitySizes = getCommunitySizes(communities, nodeSizes)
+		}
+		deltaQuality := getDeltaQualityFunc(qm, communities)
 		wg.Add(numCPUs)
 		for idxCPU := 0; idxCPU < numCPUs; idxCPU++ {
 			go func(idxCPU int) {
 				u0 := n * idxCPU / numCPUs
 				u1 := n * (idxCPU + 1) / numCPUs
-				gains := make([]float64, m)
 				for u := u0; u < u1; u++ {
 					mergeRequests[u] = MergeRequest{dst: -1, gain: 0.0}
 					mergeOrders[u] = u
+					if seedOf != nil && seedOf[u] >= 0 {
+						continue
+					}
 					oldCu := communityIDs[u]
 					neighbors := qm.GetNeighbors(u)
 					sumGains := 0.0
-					if len(neighbors) < m {
-						visitedCommunities := map[int]float64{}
-						for neighbor, _ := range neighbors {
-							newCu := communityIDs[neighbor]
-							if newCu == oldCu {
-								continue
-							}
-							_, visited := visitedCommunities[newCu]
-							if visited {
-								continue
-							}
-
-							deltaQ := qm.DeltaQuality(communities, u, oldCu, newCu)
-							if deltaQ > 0.0 {
-								visitedCommunities[newCu] = deltaQ
-								sumGains += deltaQ
-							} else {
-								visitedCommunities[newCu] = 0.0
-							}
+					// only the communities of u's neighbors and the empty community
+					// are candidates: moving to any other community only adds the
+					// null-model penalty on top of leaving the old community,
+					// which moving to the empty community avoids.
+					visitedCommunities := map[int]float64{}
+					visit := func(newCu int) {
+						if newCu == oldCu {
+							return
 						}
-
-						if sumGains > 0.0 {
-							x := rand.Float64() * sumGains
-							sum := 0.0
-							for c, gain := range visitedCommunities {
-								sum += gain
-								if sum >= x {
-									//fmt.Printf("u = %d, c = %d, sum = %v, x = %v\n", u, c, sum, x)
-
-									mergeRequests[u].dst = c
-									mergeRequests[u].gain = gain
-									break
-								}
-							}
+						_, visited := visitedCommunities[newCu]
+						if visited {
+							return
+						}
+						if communitySizes != nil &&
+							communitySizes[newCu]+nodeSizes[u] > opts.MaxCommunitySize {
+							visitedCommunities[newCu] = 0.0
+							return
 						}
-					} else {
-						for newCu := 0; newCu < m; newCu++ {
-							if newCu == oldCu {
-								gains[newCu] = 0.0
-								continue
-							}
-							deltaQ := qm.DeltaQuality(communities, u, oldCu, newCu)
-							if deltaQ > 0.0 {
-								gains[newCu] = deltaQ
-								sumGains += deltaQ
-							} else {
-								gains[newCu] = 0.0
-							}
 
-							// if deltaQ > mergeRequests[u].gain {
-							// 	mergeRequests[u].dst = newCu
-							// 	mergeRequests[u].gain = deltaQ
-							// }
+						deltaQ := deltaQuality(u, oldCu, newCu)
+						if deltaQ > opts.Epsilon {
+							visitedCommunities[newCu] = deltaQ
+							sumGains += deltaQ
+						} else {
+							visitedCommunities[newCu] = 0.0
+						}
+					}
+					for neighbor, _ := range neighbors {
+						visit(communityIDs[neighbor])
+					}
+					if opts.exhaustiveMoves {
+						for newCu := 0; newCu < emptyC; newCu++ {
+							visit(newCu)
+						}
+					}
+					if len(communities[oldCu]) > 1 {
+						deltaQ := deltaQuality(u, oldCu, emptyC)
+						if deltaQ > opts.Epsilon {
+							visitedCommunities[emptyC] = deltaQ
+							sumGains += deltaQ
 						}
+					}
 
-						if sumGains > 0.0 {
-							x := rand.Float64() * sumGains
-							sum := 0.0
-							for c := 0; c < m; c++ {
-								sum += gains[c]
-								//fmt.Printf("u = %d, sum = %v, x = %v\n", u, sum, x)
-								if sum >= x {
-									mergeRequests[u].dst = c
-									mergeRequests[u].gain = gains[c]
-									break
-								}
+					if sumGains > 0.0 {
+						// visit the candidates in a fixed order, so that a
+						// seeded choice is reproducible
+						candidates := make([]int, 0, len(visitedCommunities))
+						for c, _ := range visitedCommunities {
+							candidates = append(candidates, c)
+						}
+						sort.Ints(candidates)
+						x := getRandomFloat64(opts.Seed, iter, u) * sumGains
+						sum := 0.0
+						for _, c := range candidates {
+							gain := visitedCommunities[c]
+							sum += gain
+							if sum >= x {
+								mergeRequests[u].dst = c
+								mergeRequests[u].gain = gain
+								break
 							}
 						}
 					}
@@ -995,14 +1902,21 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 		}
 		wg.Wait()
 
-		// (2.2) sort merge requests
+		// (2.2) sort merge requests by descending gains. Ties are broken by
+		// smaller node IDs, so the moves do not depend on the sort algorithm.
 		sort.Slice(mergeOrders, func(i, j int) bool {
-			return mergeRequests[mergeOrders[i]].gain > mergeRequests[mergeOrders[j]].gain
+			gainI := mergeRequests[mergeOrders[i]].gain
+			gainJ := mergeRequests[mergeOrders[j]].gain
+			if gainI != gainJ {
+				return gainI > gainJ
+			}
+			return mergeOrders[i] < mergeOrders[j]
 		})
 
 		// (2.3) exit the loop if no merge is required
 		bestMerge := mergeRequests[mergeOrders[0]]
-		if bestMerge.dst < 0 || bestMerge.gain <= 0.0 {
+		if bestMerge.dst < 0 || bestMerge.gain <= opts.Epsilon {
+			communities = communities[:emptyC]
 			break
 		}
 
@@ -1014,14 +1928,11 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 		mergeDecisions[bestMerge.dst].src = mergeOrders[0]
 		mergeDecisions[bestMerge.dst].gain = bestMerge.gain
 		mergeDecisions[communityIDs[mergeOrders[0]]].src = -2
-		//fmt.Printf("move %d to cluster %d for gain %v\n", mergeOrders[0], bestMerge.dst, bestMerge.gain)
-		totalGain := bestMerge.gain
 		for i := 1; i < n; i++ {
 			// skip those in communities that have already changed
 			uI := mergeOrders[i]
 			oldCuI := communityIDs[uI]
 			if mergeDecisions[oldCuI].src >= 0 || mergeDecisions[oldCuI].src < -1 {
-				//fmt.Printf("cannot move %d out of cluster %d\n", i, oldCuI)
 				continue
 			}
 
@@ -1034,7 +1945,6 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 
 			// skip those want to enter communities that have already changed
 			if mergeDecisions[newCuI].src >= 0 || mergeDecisions[newCuI].src < -1 {
-				//fmt.Printf("cannot move %d of cluster %d into cluster %d\n", i, oldCuI, newCuI)
 				continue
 			}
 
@@ -1042,8 +1952,6 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 			mergeDecisions[newCuI].src = uI
 			mergeDecisions[newCuI].gain = mergeI.gain
 			mergeDecisions[oldCuI].src = -2
-			//fmt.Printf("move %d of cluster %d into cluster %d for gain %v\n", uI, oldCuI, newCuI, mergeI.gain)
-			totalGain += mergeI.gain
 		}
 
 		// (4.3) move points
@@ -1064,17 +1972,14 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 		for len(communities[lastC]) == 0 {
 			lastC--
 		}
-		//fmt.Printf("lastC = %d\n", lastC)
 		for c := 0; c <= lastC; c++ {
 			community := communities[c]
 			if len(community) == 0 {
 				communities[c] = communities[lastC]
 				communities[lastC] = community
-				//oldLastC := lastC
 				for len(communities[lastC]) == 0 && lastC > c {
 					lastC--
 				}
-				//fmt.Printf("%d is empty, switch with %d, lastC = %d\n", c, oldLastC, lastC)
 			}
 		}
 		communities = communities[:lastC+1]
@@ -1085,13 +1990,200 @@ func Louvain(qm QualityModel, communities []map[int]bool, communityIDs []int, ma
 		}
 
 		// (4.5) report statistics
-		fmt.Printf("iter %d: move %d points, gain %v\n", numIters, numMoves, totalGain)
+		if opts.Progress != nil {
+			opts.Progress("louvain", numIters, qm.Quality(communities), numMoves)
+		}
 		numIters++
 	}
 
 	// -------------------------------------------------------------------------
-	// step 7: return the result
-	return communities, communityIDs
+	// step 7: return the result in the canonical order
+	if opts.SplitDisconnected {
+		// communities grown from seeds are never split
+		seeded := []map[int]bool{}
+		unseeded := []map[int]bool{}
+		for _, c := range communities {
+			if seedOf != nil && getSeedOfCommunity(c, seedOf) >= 0 {
+				seeded = append(seeded, c)
+			} else {
+				unseeded = append(unseeded, c)
+			}
+		}
+		communities = append(seeded, splitDisconnectedCommunities(n, qm.GetNeighbors, unseeded)...)
+	}
+	if opts.MinCommunitySize > 0 {
+		communities = mergeUndersizedCommunities(qm, communities, nodeSizes, seedOf,
+			opts.MinCommunitySize, opts.MaxCommunitySize)
+	}
+	communities = canonicalizeCommunities(communities, communityIDs)
+	return communities, communityIDs, err
+}
+
+// =============================================================================
+// func LouvainHierarchy
+// brief description: multi-level Louvain algorithm. After the local moves on a
+//	level converge, the communities are aggregated into the nodes of the next
+//	level, and the local moves start again on the aggregated model, until no
+//	further coarsening happens.
+// input:
+//	qm: a quality model.
+//	communities: a list of clusters. If it is nil, single point communities
+//		are used.
+//	communityIDs: the community ID of each point. It is nil iff communities is
+//		nil.
+//	maxIters: the maximum number of iterations of Louvain on each level.
+// output:
+//	the partition at each level over the original nodes, finest first and
+//	coarsest last. Level i+1 is a coarsening of level i.
+func LouvainHierarchy(qm QualityModel, communities []map[int]bool, communityIDs []int,
+	maxIters int) [][]map[int]bool {
+	levels, _ := LouvainHierarchyWithOptions(qm, communities, communityIDs,
+		ClusteringOptions{MaxIters: maxIters})
+	return levels
+}
+
+// =============================================================================
+// func LouvainHierarchyWithOptions
+// brief description: multi-level Louvain algorithm with options. See
+//	LouvainHierarchy.
+// input:
+//	qm: a quality model.
+//	communities: a list of clusters. If it is nil, single point communities
+//		are used.
+//	communityIDs: the community ID of each point. It is nil iff communities is
+//		nil.
+//	opts: the options of Louvain on each level. opts.Timeout limits all the
+//		levels together, and opts.MaxLevels limits their number.
+// output:
+//	output 1: the partition at each level over the original nodes. See
+//		LouvainHierarchy.
+//	output 2: ErrTimeout if opts.Timeout is exceeded, nil otherwise. The levels
+//		computed before the timeout are still returned, including the level
+//		being optimized when the time was up.
+func LouvainHierarchyWithOptions(qm QualityModel, communities []map[int]bool, communityIDs []int,
+	opts ClusteringOptions) ([][]map[int]bool, error) {
+	return LouvainHierarchyCtx(context.Background(), qm, communities, communityIDs, opts)
+}
+
+// =============================================================================
+// func LouvainHierarchyCtx
+// brief description: multi-level Louvain algorithm with options that can be
+//	cancelled. See LouvainHierarchyWithOptions.
+// input:
+//	ctx: the context. It is checked before each level and each iteration of
+//		the local moves.
+//	qm: a quality model.
+//	communities: a list of clusters. If it is nil, single point communities
+//		are used.
+//	communityIDs: the community ID of each point. It is nil iff communities is
+//		nil.
+//	opts: the options of Louvain on each level.
+// output:
+//	output 1: the partition at each level over the original nodes. When ctx
+//		is done, the levels computed so far are returned, including the level
+//		being optimized.
+//	output 2: an error if the input communities or opts.FrozenCommunities are
+//		invalid, see LouvainCtx, in which case no level is returned. Otherwise, ctx.Err() if ctx is
+//		done, ErrTimeout if opts.Timeout is exceeded, nil otherwise.
+func LouvainHierarchyCtx(ctx context.Context, qm QualityModel, communities []map[int]bool,
+	communityIDs []int, opts ClusteringOptions) ([][]map[int]bool, error) {
+	levels := [][]map[int]bool{}
+	var flatCommunities []map[int]bool
+	startTime := time.Now()
+	originalQM := qm
+	originalSizes := getNodeSizes(qm)
+	originalSeedOf, err := getSeedOf(qm.GetN(), opts.FrozenCommunities)
+	if err != nil {
+		return levels, err
+	}
+	frozen := opts.FrozenCommunities
+	for {
+		// ---------------------------------------------------------------------
+		// (1) run the local moves on the current level within the time left
+		if ctx.Err() != nil {
+			return levels, ctx.Err()
+		}
+		levelOpts := opts
+		levelOpts.MinCommunitySize = 0
+		levelOpts.FrozenCommunities = frozen
+		if opts.Timeout > 0 {
+			levelOpts.Timeout = opts.Timeout - time.Since(startTime)
+			if levelOpts.Timeout <= 0 {
+				return levels, ErrTimeout
+			}
+		}
+		levelCommunities, levelCommunityIDs, err := LouvainCtx(ctx, qm, communities, communityIDs,
+			levelOpts)
+		if levelCommunities == nil {
+			return levels, err
+		}
+
+		// ---------------------------------------------------------------------
+		// (2) stop if this level does not coarsen the previous one
+		if len(levels) > 0 && len(levelCommunities) == qm.GetN() {
+			return levels, err
+		}
+
+		// ---------------------------------------------------------------------
+		// (3) record the level over the original nodes
+		if flatCommunities == nil {
+			flatCommunities = levelCommunities
+		} else {
+			flatCommunities = canonicalizeCommunities(
+				flattenCommunities(levelCommunities, flatCommunities), nil)
+		}
+		levelResult := flatCommunities
+		if opts.MinCommunitySize > 0 {
+			levelResult = canonicalizeCommunities(mergeUndersizedCommunities(originalQM,
+				copyCommunities(flatCommunities), originalSizes, originalSeedOf, opts.MinCommunitySize,
+				opts.MaxCommunitySize), nil)
+		}
+		levels = append(levels, levelResult)
+		if opts.Progress != nil {
+			opts.Progress("louvain-level", len(levels)-1, originalQM.Quality(levelResult),
+				len(levelResult))
+		}
+		if err != nil || len(levelCommunities) <= 1 {
+			return levels, err
+		}
+		if opts.MaxLevels > 0 && len(levels) >= opts.MaxLevels {
+			return levels, nil
+		}
+
+		// ---------------------------------------------------------------------
+		// (4) aggregate the communities into the nodes of the next level. The
+		// nodes grown from frozen communities are frozen on the next level.
+		qm = qm.Aggregate(levelCommunities)
+		if frozen != nil {
+			nextFrozen := make([]map[int]bool, len(frozen))
+			for idxSeed, seed := range frozen {
+				for u, _ := range seed {
+					nextFrozen[idxSeed] = map[int]bool{levelCommunityIDs[u]: true}
+					break
+				}
+			}
+			frozen = nextFrozen
+		}
+		communities = nil
+		communityIDs = nil
+	}
+}
+
+// =============================================================================
+// func HierarchyQualities
+// brief description: evaluate the quality of each level of a hierarchy, e.g.
+//	the output of LouvainHierarchy, to choose a level.
+// input:
+//	qm: the quality model of the original nodes.
+//	levels: the partition at each level over the original nodes.
+// output:
+//	the quality of each level
+func HierarchyQualities(qm QualityModel, levels [][]map[int]bool) []float64 {
+	qualities := make([]float64, len(levels))
+	for idxLevel, level := range levels {
+		qualities[idxLevel] = qm.Quality(level)
+	}
+	return qualities
 }
 
 // // =============================================================================
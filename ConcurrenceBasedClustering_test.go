@@ -0,0 +1,690 @@
+package ConcurrenceBasedClustering
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLouvainHierarchyMaxLevels(t *testing.T) {
+	// the resolution limit of modularity makes the later levels merge the
+	// cliques of a large ring
+	qm := NewModularity(1.0, ringOfCliques(t, 30, 3))
+	full, err := LouvainHierarchyWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capped, err := LouvainHierarchyWithOptions(qm, nil, nil,
+		ClusteringOptions{MaxIters: 100, Seed: 1, MaxLevels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) < 2 || len(capped) != 1 {
+		t.Fatalf("%d levels uncapped and %d levels capped, want >= 2 and 1", len(full), len(capped))
+	}
+	assertSamePartition(t, capped[0], full[0])
+	coarsest := full[len(full)-1]
+	if len(capped[0]) <= len(coarsest) {
+		t.Fatalf("capped output has %d communities, uncapped %d", len(capped[0]), len(coarsest))
+	}
+	if largestSize(capped[0]) >= largestSize(coarsest) {
+		t.Fatalf("largest capped community has %d nodes, uncapped %d",
+			largestSize(capped[0]), largestSize(coarsest))
+	}
+}
+
+func TestAggregatedDeltasEqualFlattenedDeltas(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cm, _ := plantedPartition(t, rng, 4, 10, 0.5, 0.1)
+	groups := randomCommunities(rng, cm.GetN(), 12)
+	models := map[string]QualityModel{
+		"modularity":          NewModularity(0.8, cm),
+		"standard modularity": NewModularityStandard(0.8, cm),
+		"cpm":                 NewCPM(0.1, cm),
+	}
+	for name, qm := range models {
+		aggQM := qm.Aggregate(groups)
+		offset := math.NaN()
+		for trial := 0; trial < 50; trial++ {
+			// -----------------------------------------------------------------
+			// step 1: the quality of the aggregated model is the flattened
+			// quality up to a constant offset
+			communities := randomCommunities(rng, len(groups), 4)
+			flat := flattenCommunities(communities, groups)
+			diff := aggQM.Quality(communities) - qm.Quality(flat)
+			if math.IsNaN(offset) {
+				offset = diff
+			} else if math.Abs(diff-offset) > 1e-9 {
+				t.Fatalf("%s: offset %v, then %v", name, offset, diff)
+			}
+
+			// -----------------------------------------------------------------
+			// step 2: a move of an aggregated node changes both qualities by
+			// DeltaQuality
+			u := rng.Intn(len(groups))
+			oldCu, newCu := -1, rng.Intn(len(communities))
+			for idxC, c := range communities {
+				if c[u] {
+					oldCu = idxC
+				}
+			}
+			delta := aggQM.DeltaQuality(communities, u, oldCu, newCu)
+			delete(communities[oldCu], u)
+			communities[newCu][u] = true
+			flatDelta := qm.Quality(flattenCommunities(communities, groups)) - qm.Quality(flat)
+			if math.Abs(delta-flatDelta) > 1e-9 {
+				t.Fatalf("%s: aggregated delta %v, flattened delta %v", name, delta, flatDelta)
+			}
+		}
+		if name == "standard modularity" && math.Abs(offset) > 1e-9 {
+			t.Fatalf("%s: offset %v, want 0", name, offset)
+		}
+		if name == "cpm" {
+			internal := 0.0
+			for _, group := range groups {
+				weight, _ := cm.getCommunityWeightAndSize(group)
+				internal += weight
+			}
+			if math.Abs(offset+2.0*internal) > 1e-9 {
+				t.Fatalf("%s: offset %v, want %v", name, offset, -2.0*internal)
+			}
+		}
+	}
+}
+
+func TestLouvainEpsilonSkipsNoProgressSweeps(t *testing.T) {
+	// cliques plus a long path of tiny weights, along which the unbounded loop
+	// keeps moving nodes for gains of about 1e-12
+	rng := rand.New(rand.NewSource(1))
+	edges := []Edge{}
+	for c := 0; c < 20; c++ {
+		edges = append(edges, cliqueEdges(c*5, 5, 1)...)
+	}
+	for u := 100; u < 400; u++ {
+		edges = append(edges, Edge{u, u + 1, 1e-9 * (1 + rng.Float64())})
+	}
+	qm := NewModularity(1.0, newTestModel(t, edges))
+	sweeps := map[float64]int{}
+	qualities := map[float64]float64{}
+	for _, epsilon := range []float64{0.0, 1e-6} {
+		communities, _, err := LouvainWithOptions(qm, nil, nil, ClusteringOptions{
+			MaxIters: 1000,
+			Epsilon:  epsilon,
+			Seed:     1,
+			Progress: func(stage string, sweep int, quality float64, moved int) {
+				sweeps[epsilon]++
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		qualities[epsilon] = qm.Quality(communities)
+	}
+	if sweeps[1e-6] >= sweeps[0.0] {
+		t.Fatalf("%d sweeps with epsilon, %d without", sweeps[1e-6], sweeps[0.0])
+	}
+	if math.Abs(qualities[1e-6]-qualities[0.0]) > 1e-6 {
+		t.Fatalf("quality %v with epsilon, %v without", qualities[1e-6], qualities[0.0])
+	}
+}
+
+func TestSelfLoopsAreIgnored(t *testing.T) {
+	// -------------------------------------------------------------------------
+	// step 1: the same graph with and without self-loops in its matrix
+	plain := twoTriangles(t)
+	withLoops := make([]map[int]float64, plain.n)
+	for u := 0; u < plain.n; u++ {
+		withLoops[u] = map[int]float64{u: 3.0}
+		for v, weightUV := range plain.concurrences[u] {
+			withLoops[u][v] = weightUV
+		}
+	}
+	looped := newConcurrenceModel(withLoops, plain.cardinalities)
+	if math.Abs(looped.sumConcurrences-plain.sumConcurrences) > 1e-12 {
+		t.Fatalf("sumConcurrences = %v, want %v", looped.sumConcurrences, plain.sumConcurrences)
+	}
+	_, err := NewConcurrenceModelFromEdges([]Edge{{0, 1, 1}, {1, 1, 1}})
+	if err == nil {
+		t.Fatal("a self-loop edge is accepted")
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: every quality model gives the same values on both
+	communities := []map[int]bool{{0: true, 1: true, 2: true, 3: true}, {4: true, 5: true}}
+	pairs := map[string][2]QualityModel{
+		"modularity": {NewModularity(1.0, plain), NewModularity(1.0, looped)},
+		"standard modularity": {NewModularityStandard(1.0, plain),
+			NewModularityStandard(1.0, looped)},
+		"cpm":          {NewCPM(0.5, plain), NewCPM(0.5, looped)},
+		"surprise":     {NewSurprise(plain), NewSurprise(looped)},
+		"significance": {NewSignificance(plain), NewSignificance(looped)},
+	}
+	for name, pair := range pairs {
+		want, got := pair[0].Quality(communities), pair[1].Quality(communities)
+		if math.Abs(got-want) > 1e-12 {
+			t.Fatalf("%s: quality %v with self-loops, %v without", name, got, want)
+		}
+		want = pair[0].DeltaQuality(communities, 3, 0, 1)
+		got = pair[1].DeltaQuality(communities, 3, 0, 1)
+		if math.Abs(got-want) > 1e-12 {
+			t.Fatalf("%s: delta %v with self-loops, %v without", name, got, want)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: DBScan does not count a node as its own neighbor
+	got, _ := looped.DBScan(0.0, 2)
+	want, _ := plain.DBScan(0.0, 2)
+	assertSamePartition(t, got, want)
+}
+
+func TestStandardModularityMatchesNetworkX(t *testing.T) {
+	cm, factions := karateClub(t)
+	all := []map[int]bool{{}}
+	singletons := []map[int]bool{}
+	for u := 0; u < cm.GetN(); u++ {
+		all[0][u] = true
+		singletons = append(singletons, map[int]bool{u: true})
+	}
+
+	// -------------------------------------------------------------------------
+	// step 1: the values of networkx.community.modularity(G, communities,
+	// weight=None, resolution=r) on the unweighted karate club
+	for _, tc := range []struct {
+		r           float64
+		communities []map[int]bool
+		want        float64
+	}{
+		{1.0, factions, 0.3582347140039447},
+		{0.5, factions, 0.6086045364891519},
+		{1.0, all, 0.0},
+		{1.0, singletons, -0.04980276134122286},
+	} {
+		got := NewModularityStandard(tc.r, cm).Quality(tc.communities)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("r = %v: modularity of %d communities = %v, want %v",
+				tc.r, len(tc.communities), got, tc.want)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: the default variant differs by a constant, so Louvain finds the
+	// same communities with both
+	offset := NewModularity(1.0, cm).Quality(factions) - NewModularityStandard(1.0, cm).Quality(factions)
+	got := NewModularity(1.0, cm).Quality(singletons) - NewModularityStandard(1.0, cm).Quality(singletons)
+	if math.Abs(got-offset) > 1e-12 {
+		t.Fatalf("offsets %v and %v differ", offset, got)
+	}
+	opts := ClusteringOptions{MaxIters: 100, Seed: 1}
+	standard, _, _ := LouvainWithOptions(NewModularityStandard(1.0, cm), nil, nil, opts)
+	original, _, _ := LouvainWithOptions(NewModularity(1.0, cm), nil, nil, opts)
+	assertSamePartition(t, standard, original)
+}
+
+func TestLouvainSplitsIntoNewCommunities(t *testing.T) {
+	// starting from a single community, CPM with r = 0.5 prefers the two
+	// triangles, which needs moves into a community that did not exist
+	cm := twoTriangles(t)
+	all := []map[int]bool{{0: true, 1: true, 2: true, 3: true, 4: true, 5: true}}
+	communities, _, err := LouvainWithOptions(NewCPM(0.5, cm), all, make([]int, cm.GetN()),
+		ClusteringOptions{MaxIters: 100, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities,
+		[]map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}})
+	if len(all[0]) != 6 {
+		t.Fatalf("the input community is modified to %v", all[0])
+	}
+}
+
+func TestLouvainStopsEarlyWithValidPartition(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 20, 0.4, 0.05)
+	qm := NewModularity(1.0, cm)
+	for name, opts := range map[string]ClusteringOptions{
+		"one iteration": {MaxIters: 1, Seed: 1},
+		"large epsilon": {MaxIters: 100, Epsilon: 0.01, Seed: 1},
+		"timeout":       {MaxIters: 100, Timeout: time.Nanosecond, Seed: 1},
+	} {
+		sweeps := 0
+		opts.Progress = func(stage string, sweep int, quality float64, moved int) {
+			sweeps++
+		}
+		communities, _, err := LouvainWithOptions(qm, nil, nil, opts)
+		if (opts.Timeout > 0 && err != ErrTimeout) || (opts.Timeout == 0 && err != nil) {
+			t.Fatalf("%s: err = %v", name, err)
+		}
+		if err := ValidatePartition(communities, cm.GetN()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if sweeps > 2 {
+			t.Fatalf("%s: %d sweeps", name, sweeps)
+		}
+	}
+
+	// the time limit also bounds the multi-level Louvain
+	levels, err := LouvainHierarchyWithOptions(qm, nil, nil,
+		ClusteringOptions{MaxIters: 100, Timeout: time.Nanosecond, Seed: 1})
+	if err != ErrTimeout {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	for _, level := range levels {
+		if err := ValidatePartition(level, cm.GetN()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLouvainCtxDeadlineReturnsPromptly(t *testing.T) {
+	// a sparse random graph, on which Louvain needs many iterations
+	rng := rand.New(rand.NewSource(1))
+	n := 20000
+	edges := []Edge{}
+	for i := 0; i < 5*n; i++ {
+		u, v := rng.Intn(n), rng.Intn(n)
+		if u != v {
+			edges = append(edges, Edge{u, v, 1})
+		}
+	}
+	qm := NewModularity(1.0, newTestModel(t, edges))
+	startTime := time.Now()
+	LouvainWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 1, Seed: 1})
+	oneIteration := time.Since(startTime)
+
+	// the context is checked between iterations, so the call returns at most
+	// about one iteration after the deadline
+	const deadline = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	startTime = time.Now()
+	communities, _, err := LouvainCtx(ctx, qm, nil, nil, ClusteringOptions{MaxIters: 1000, Seed: 1})
+	elapsed := time.Since(startTime)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > deadline+3*oneIteration {
+		t.Fatalf("returned after %v, with a deadline of %v and %v per iteration",
+			elapsed, deadline, oneIteration)
+	}
+	if err := ValidatePartition(communities, n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProgressSweepsOfModularity is an example of Progress: it captures the
+// sequence of sweeps of Louvain, whose Modularity never decreases.
+func TestProgressSweepsOfModularity(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 20, 0.4, 0.05)
+	type sweepReport struct {
+		sweep   int
+		quality float64
+		moved   int
+	}
+	reports := []sweepReport{}
+	opts := ClusteringOptions{
+		MaxIters: 100,
+		Seed:     1,
+		Progress: func(stage string, sweep int, quality float64, moved int) {
+			if stage != "louvain" {
+				t.Fatalf("stage = %q, want louvain", stage)
+			}
+			reports = append(reports, sweepReport{sweep, quality, moved})
+		},
+	}
+	qm := NewModularity(1.0, cm)
+	communities, _, _ := LouvainWithOptions(qm, nil, nil, opts)
+	if len(reports) < 2 {
+		t.Fatalf("%d sweeps reported", len(reports))
+	}
+	for idxReport, report := range reports {
+		if report.sweep != idxReport || report.moved <= 0 {
+			t.Fatalf("report %d: %+v", idxReport, report)
+		}
+		if idxReport > 0 && report.quality < reports[idxReport-1].quality-1e-12 {
+			t.Fatalf("quality decreases from %v to %v at sweep %d",
+				reports[idxReport-1].quality, report.quality, report.sweep)
+		}
+	}
+	last := reports[len(reports)-1].quality
+	if math.Abs(last-qm.Quality(communities)) > 1e-12 {
+		t.Fatalf("last reported quality %v, final quality %v", last, qm.Quality(communities))
+	}
+}
+
+// =============================================================================
+// func randomGraph
+// brief description: a random graph of n nodes and about numEdges edges of
+//	weight 1, so that every edge is within the neighborhood of DBScan.
+func randomGraph(t testing.TB, n, numEdges int, rng *rand.Rand) ConcurrenceModel {
+	edges := []Edge{}
+	for i := 0; i < numEdges; i++ {
+		u := rng.Intn(n)
+		v := rng.Intn(n)
+		if u < v {
+			edges = append(edges, Edge{u, v, 1.0})
+		}
+	}
+	return newTestModel(t, edges)
+}
+
+func TestDBScanParallelEqualsSerial(t *testing.T) {
+	// more points than minParallelRows, so that the parallel path is taken
+	cm := randomGraph(t, 3000, 8000, rand.New(rand.NewSource(1)))
+	serial, serialIDs := cm.DBScanWithOptions(0.1, 4, ClusteringOptions{NumWorkers: 1})
+	if len(serial) < 10 {
+		t.Fatalf("%d communities, want a graph with several clusters", len(serial))
+	}
+	for _, numWorkers := range []int{2, 8} {
+		parallel, parallelIDs := cm.DBScanWithOptions(0.1, 4,
+			ClusteringOptions{NumWorkers: numWorkers})
+		if !reflect.DeepEqual(parallel, serial) || !reflect.DeepEqual(parallelIDs, serialIDs) {
+			t.Fatalf("%d workers: the result differs from the serial one", numWorkers)
+		}
+	}
+}
+
+func BenchmarkDBScan(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		cm := randomGraph(b, n, 10*n, rand.New(rand.NewSource(1)))
+		for _, numWorkers := range []int{1, 4} {
+			b.Run(fmt.Sprintf("n=%d/workers=%d", n, numWorkers), func(b *testing.B) {
+				opts := ClusteringOptions{NumWorkers: numWorkers}
+				for i := 0; i < b.N; i++ {
+					cm.DBScanWithOptions(0.1, 8, opts)
+				}
+			})
+		}
+	}
+}
+
+func TestWeightedDBScanClassifiesHubsByWeight(t *testing.T) {
+	// the hub 0 has four weak neighbors, and the node 5 has three strong ones
+	cm := newTestModel(t, []Edge{{0, 1, 0.6}, {0, 2, 0.6}, {0, 3, 0.6}, {0, 4, 0.6},
+		{5, 6, 0.95}, {5, 7, 0.95}, {5, 8, 0.95}})
+	singletons := func(points ...int) []map[int]bool {
+		result := []map[int]bool{}
+		for _, u := range points {
+			result = append(result, map[int]bool{u: true})
+		}
+		return result
+	}
+	// by count, the hub has the density 5 and is a core point, the node 5 has 4
+	communities, _ := cm.DBScan(0.5, 5)
+	assertSamePartition(t, communities, append(singletons(5, 6, 7, 8),
+		map[int]bool{0: true, 1: true, 2: true, 3: true, 4: true}))
+	// by weight, the hub has the density 3.4 and the node 5 has 3.85
+	communities, _ = cm.WeightedDBScan(0.5, 3.5)
+	assertSamePartition(t, communities, append(singletons(0, 1, 2, 3, 4),
+		map[int]bool{5: true, 6: true, 7: true, 8: true}))
+}
+
+func TestLouvainIsDeterministic(t *testing.T) {
+	// the ring of cliques is symmetric, so many moves have the same gain and
+	// only the tie-breaking by node IDs decides between them
+	planted, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 6, 30, 0.2, 0.02)
+	for name, cm := range map[string]ConcurrenceModel{"ring of cliques": ringOfCliques(t, 8, 5),
+		"planted partition": planted} {
+		qm := NewModularity(1.0, cm)
+		var first []map[int]bool
+		var firstIDs []int
+		var firstLevels [][]map[int]bool
+		for run := 0; run < 3; run++ {
+			for _, numWorkers := range []int{1, 2, 8} {
+				opts := ClusteringOptions{MaxIters: 100, Seed: 1, NumWorkers: numWorkers}
+				communities, communityIDs, err := LouvainWithOptions(qm, nil, nil, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				levels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if first == nil {
+					first, firstIDs, firstLevels = communities, communityIDs, levels
+				} else if !reflect.DeepEqual(communities, first) ||
+					!reflect.DeepEqual(communityIDs, firstIDs) ||
+					!reflect.DeepEqual(levels, firstLevels) {
+					t.Fatalf("%s: run %d with %d workers differs from the first one", name, run,
+						numWorkers)
+				}
+			}
+		}
+	}
+}
+
+func TestLouvainWithQualityMatchesQuality(t *testing.T) {
+	cm, _ := karateClub(t)
+	for name, qm := range map[string]QualityModel{
+		"modularity": NewModularity(1.0, cm),
+		"cpm":        NewCPM(0.1, cm),
+	} {
+		communities, _, quality, err := LouvainWithQuality(qm, nil, nil,
+			ClusteringOptions{MaxIters: 100, Seed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidatePartition(communities, cm.n); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Quality sums over maps, so two evaluations may differ in rounding
+		if math.Abs(quality-qm.Quality(communities)) > 1e-12 {
+			t.Fatalf("%s: quality = %v, want %v", name, quality, qm.Quality(communities))
+		}
+	}
+}
+
+func TestLouvainCommunitySizeConstraints(t *testing.T) {
+	// the natural communities are the 2 planted groups of 200 nodes
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 2, 200, 0.2, 0.005)
+	qm := NewModularity(1.0, cm)
+	levels, err := LouvainHierarchyWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, levels[len(levels)-1], truth)
+
+	// splitting each group into halves is the obvious partition within the cap
+	halves := []map[int]bool{}
+	for _, group := range truth {
+		even, odd := map[int]bool{}, map[int]bool{}
+		for u, _ := range group {
+			if u%2 == 0 {
+				even[u] = true
+			} else {
+				odd[u] = true
+			}
+		}
+		halves = append(halves, even, odd)
+	}
+	levels, err = LouvainHierarchyWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100,
+		Seed: 1, MaxCommunitySize: 100, MinCommunitySize: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for level, communities := range levels {
+		communityIDs := make([]int, cm.n)
+		for idxC, c := range communities {
+			for u, _ := range c {
+				communityIDs[u] = idxC
+			}
+		}
+		for idxC, c := range communities {
+			if len(c) > 100 {
+				t.Fatalf("level %d has a community of %d nodes", level, len(c))
+			}
+			if len(c) >= 20 {
+				continue
+			}
+			// a smaller community is only kept if no neighbor can absorb it
+			for u, _ := range c {
+				for v, _ := range cm.concurrences[u] {
+					idxD := communityIDs[v]
+					if idxD != idxC && len(c)+len(communities[idxD]) <= 100 {
+						t.Fatalf("level %d keeps a community of %d nodes next to one of %d",
+							level, len(c), len(communities[idxD]))
+					}
+				}
+			}
+		}
+	}
+	quality := qm.Quality(levels[len(levels)-1])
+	if quality < qm.Quality(halves) || quality >= qm.Quality(truth) {
+		t.Fatalf("quality = %v, want within [%v, %v)", quality, qm.Quality(halves),
+			qm.Quality(truth))
+	}
+}
+
+func TestFrozenCommunitiesKeepTheirMembers(t *testing.T) {
+	// the seeds split the first planted group in halves, which Louvain would
+	// merge if they were not frozen, and the third group is a seed of its own
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 4, 20, 0.5, 0.02)
+	frozen := []map[int]bool{{}, {}, truth[2]}
+	for u, _ := range truth[0] {
+		frozen[u%2][u] = true
+	}
+	qm := NewModularity(1.0, cm)
+	opts := ClusteringOptions{MaxIters: 100, Seed: 1, FrozenCommunities: frozen}
+	communities, _, err := LouvainWithOptions(qm, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := map[string][]map[int]bool{"LouvainWithOptions": communities}
+	for level, levelCommunities := range levels {
+		results[fmt.Sprintf("level %d", level)] = levelCommunities
+	}
+	for name, communities := range results {
+		seedIndices := SeedIndices(communities, frozen)
+		for idxSeed, seed := range frozen {
+			numCommunities := 0
+			for idxC, c := range communities {
+				if seedIndices[idxC] != idxSeed {
+					continue
+				}
+				numCommunities++
+				for u, _ := range seed {
+					if !c[u] {
+						t.Fatalf("%s: node %d has left seed %d", name, u, idxSeed)
+					}
+				}
+				for idxOther, other := range frozen {
+					for u, _ := range other {
+						if idxOther != idxSeed && c[u] {
+							t.Fatalf("%s: seeds %d and %d are merged", name, idxSeed,
+								idxOther)
+						}
+					}
+				}
+			}
+			if numCommunities != 1 {
+				t.Fatalf("%s: seed %d grows into %d communities", name, idxSeed,
+					numCommunities)
+			}
+		}
+	}
+}
+
+func TestInvalidFrozenCommunitiesAreRejected(t *testing.T) {
+	qm := NewModularity(1.0, twoTriangles(t))
+	for name, frozen := range map[string][]map[int]bool{
+		"empty":        {{0: true}, {}},
+		"out of range": {{0: true, 6: true}},
+		"negative":     {{-1: true}},
+		"overlapping":  {{0: true, 1: true}, {1: true, 2: true}},
+	} {
+		opts := ClusteringOptions{MaxIters: 10, FrozenCommunities: frozen}
+		communities, communityIDs, err := LouvainWithOptions(qm, nil, nil, opts)
+		if err == nil || communities != nil || communityIDs != nil {
+			t.Fatalf("%s: LouvainWithOptions returns %v, %v", name, communities, err)
+		}
+		levels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+		if err == nil || len(levels) != 0 {
+			t.Fatalf("%s: LouvainHierarchyWithOptions returns %v, %v", name, levels, err)
+		}
+	}
+}
+
+func TestLouvainHierarchyLevelsAreNested(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 8, 15, 0.3, 0.03)
+	for _, qm := range []QualityModel{NewModularity(1.0, cm),
+		NewModularity(1.0, ringOfCliques(t, 30, 3))} {
+		qualities := []float64{}
+		levels, err := LouvainHierarchyWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100,
+			Seed: 1, Progress: func(stage string, level int, quality float64, numCommunities int) {
+				if stage == "louvain-level" {
+					qualities = append(qualities, quality)
+				}
+			}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(levels) < 2 || len(qualities) != len(levels) {
+			t.Fatalf("%d levels, %d qualities reported, want several levels", len(levels),
+				len(qualities))
+		}
+		for level, communities := range levels {
+			if math.Abs(qualities[level]-qm.Quality(communities)) > 1e-12 {
+				t.Fatalf("quality of level %d = %v, want %v", level, qualities[level],
+					qm.Quality(communities))
+			}
+			if level == 0 {
+				continue
+			}
+			// every community of the previous level is within exactly one
+			// community of this level
+			communityIDs := make([]int, qm.GetN())
+			for idxC, c := range communities {
+				for u, _ := range c {
+					communityIDs[u] = idxC
+				}
+			}
+			for _, c := range levels[level-1] {
+				idxParent := -1
+				for u, _ := range c {
+					if idxParent >= 0 && communityIDs[u] != idxParent {
+						t.Fatalf("a community of level %d is split by level %d", level-1, level)
+					}
+					idxParent = communityIDs[u]
+				}
+			}
+			if len(communities) >= len(levels[level-1]) ||
+				qualities[level] < qualities[level-1]-1e-12 {
+				t.Fatalf("level %d with %d communities and quality %v does not improve level %d "+
+					"with %d and %v", level, len(communities), qualities[level], level-1,
+					len(levels[level-1]), qualities[level-1])
+			}
+		}
+	}
+}
+
+func TestNeighborMovesMatchExhaustiveMoves(t *testing.T) {
+	karate, _ := karateClub(t)
+	planted, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 20, 0.3, 0.05)
+	for _, cm := range []ConcurrenceModel{karate, planted} {
+		for _, qm := range []QualityModel{NewModularity(1.0, cm), NewCPM(0.1, cm)} {
+			for seed := int64(1); seed <= 3; seed++ {
+				opts := ClusteringOptions{MaxIters: 100, Seed: seed}
+				levels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.exhaustiveMoves = true
+				exhaustiveLevels, err := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(levels, exhaustiveLevels) {
+					t.Fatalf("seed %d: the levels %v differ from those of the exhaustive scan %v",
+						seed, levels, exhaustiveLevels)
+				}
+			}
+		}
+	}
+}
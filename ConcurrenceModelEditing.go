@@ -0,0 +1,196 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+)
+
+// =============================================================================
+// func (cm *ConcurrenceModel) grow
+// brief description: make sure that a node ID is valid in the model. If it is
+//	not, the model is extended with isolated nodes of cardinality 1 so that the
+//	node ID becomes the last node of the model.
+// input:
+//	u: a node ID
+func (cm *ConcurrenceModel) grow(u int) {
+	for cm.n <= u {
+		cm.concurrences = append(cm.concurrences, map[int]float64{})
+		cm.cardinalities = append(cm.cardinalities, 1)
+		cm.sumConcurrencesOf = append(cm.sumConcurrencesOf, 0.0)
+		cm.n++
+	}
+}
+
+// =============================================================================
+// func (cm *ConcurrenceModel) AddNode
+// brief description: add an isolated node to the model.
+// input:
+//	cardinality: the cardinality of the new node, must be > 0.
+// output:
+//	the ID of the new node, i.e. the old n
+func (cm *ConcurrenceModel) AddNode(cardinality int) int {
+	if cardinality <= 0 {
+		log.Fatalln(fmt.Sprintf("cardinality = %d must be > 0 in AddNode", cardinality))
+	}
+	u := cm.n
+	cm.grow(u)
+	cm.cardinalities[u] = cardinality
+	return u
+}
+
+// =============================================================================
+// func (cm *ConcurrenceModel) AddConcurrence
+// brief description: add an amount of concurrence between two nodes. Both
+//	(i,j) and (j,i) are updated, and the statistical fields are maintained
+//	incrementally in O(1), so that streaming data does not require rebuilding
+//	the whole model.
+// input:
+//	i, j: two node IDs. If any of them is not less than n, the model grows to
+//		contain it.
+//	delta: the amount of concurrence to be added, must be > 0.
+// note:
+//	The concurrence maps are shared between copies of a ConcurrenceModel, so
+//	copies made before this call see the new weights but not the new
+//	statistics.
+func (cm *ConcurrenceModel) AddConcurrence(i, j int, delta float64) {
+	// -------------------------------------------------------------------------
+	// step 1: check the input
+	if i < 0 || j < 0 {
+		log.Fatalln(fmt.Sprintf("invalid node IDs (%d, %d) in AddConcurrence", i, j))
+	}
+	if i == j {
+		log.Fatalln(fmt.Sprintf("cannot add a concurrence of node %d to itself", i))
+	}
+	if delta <= 0.0 {
+		log.Fatalln(fmt.Sprintf("delta = %v must be > 0 in AddConcurrence", delta))
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: grow the model if i or j is a new node
+	cm.grow(i)
+	cm.grow(j)
+
+	// -------------------------------------------------------------------------
+	// step 3: update the concurrences and the statistics
+	cm.concurrences[i][j] += delta
+	cm.concurrences[j][i] += delta
+	weightedDelta := delta * float64(cm.cardinalities[i]*cm.cardinalities[j])
+	cm.sumConcurrencesOf[i] += weightedDelta
+	cm.sumConcurrencesOf[j] += weightedDelta
+	cm.sumConcurrences += 2.0 * weightedDelta
+}
+
+// =============================================================================
+// func (cm *ConcurrenceModel) RemoveConcurrence
+// brief description: remove an amount of concurrence between two nodes. This
+//	is the reverse operation of AddConcurrence. When the concurrence between
+//	the two nodes drops to 0, the edge is deleted from the model.
+// input:
+//	i, j: two node IDs, 0 <= i, j < n.
+//	delta: the amount of concurrence to be removed, must be > 0 and not more
+//		than the current concurrence between i and j.
+func (cm *ConcurrenceModel) RemoveConcurrence(i, j int, delta float64) {
+	// -------------------------------------------------------------------------
+	// step 1: check the input
+	if i < 0 || j < 0 || i >= cm.n || j >= cm.n {
+		log.Fatalln(fmt.Sprintf("invalid node IDs (%d, %d) in RemoveConcurrence", i, j))
+	}
+	if delta <= 0.0 {
+		log.Fatalln(fmt.Sprintf("delta = %v must be > 0 in RemoveConcurrence", delta))
+	}
+	weightIJ := cm.GetConcurrence(i, j)
+	if delta > weightIJ {
+		log.Fatalln(fmt.Sprintf("cannot remove %v from concurrence(%d, %d) = %v",
+			delta, i, j, weightIJ))
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: update the concurrences
+	if delta == weightIJ {
+		delete(cm.concurrences[i], j)
+		delete(cm.concurrences[j], i)
+	} else {
+		cm.concurrences[i][j] = weightIJ - delta
+		cm.concurrences[j][i] = weightIJ - delta
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: update the statistics
+	weightedDelta := delta * float64(cm.cardinalities[i]*cm.cardinalities[j])
+	cm.sumConcurrencesOf[i] -= weightedDelta
+	cm.sumConcurrencesOf[j] -= weightedDelta
+	cm.sumConcurrences -= 2.0 * weightedDelta
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) mapWeights
+// brief description: create a new ConcurrenceModel by mapping each concurrence
+//	weight of this model. n, node IDs and cardinalities are preserved.
+// input:
+//	f: a function that maps a weight to a new weight. If f returns a weight
+//		<= 0, the edge is dropped from the new model.
+// output:
+//	the new ConcurrenceModel with recomputed statistics.
+func (cm ConcurrenceModel) mapWeights(f func(weight float64) float64) ConcurrenceModel {
+	newConcurrences := make([]map[int]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		newConcurrences[u] = map[int]float64{}
+		for v, weightUV := range cm.concurrences[u] {
+			newWeightUV := f(weightUV)
+			if newWeightUV > 0.0 {
+				newConcurrences[u][v] = newWeightUV
+			}
+		}
+	}
+	newCardinalities := make([]int, cm.n)
+	copy(newCardinalities, cm.cardinalities)
+	return newConcurrenceModel(newConcurrences, newCardinalities)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) PruneBelow
+// brief description: create a new ConcurrenceModel without the edges whose
+//	concurrences are below a threshold.
+// input:
+//	minWeight: the minimum concurrence of the edges kept.
+// output:
+//	the new ConcurrenceModel. Nodes left without edges remain as isolated
+//	nodes.
+func (cm ConcurrenceModel) PruneBelow(minWeight float64) ConcurrenceModel {
+	return cm.mapWeights(func(weight float64) float64 {
+		if weight < minWeight {
+			return 0.0
+		}
+		return weight
+	})
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) Binarize
+// brief description: create a new ConcurrenceModel whose concurrences are 1 for
+//	the edges at or above a threshold. Other edges are dropped.
+// input:
+//	threshold: the minimum concurrence of the edges kept.
+// output:
+//	the new ConcurrenceModel
+func (cm ConcurrenceModel) Binarize(threshold float64) ConcurrenceModel {
+	return cm.mapWeights(func(weight float64) float64 {
+		if weight < threshold {
+			return 0.0
+		}
+		return 1.0
+	})
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) TransformWeights
+// brief description: create a new ConcurrenceModel by applying a mapping to all
+//	concurrences, e.g. a log-scaling.
+// input:
+//	f: a monotone mapping of concurrences. Edges mapped to values <= 0 are
+//		dropped.
+// output:
+//	the new ConcurrenceModel
+func (cm ConcurrenceModel) TransformWeights(f func(weight float64) float64) ConcurrenceModel {
+	return cm.mapWeights(f)
+}
@@ -0,0 +1,133 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAddRemoveConcurrenceMatchesRebuild(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	incremental := ConcurrenceModel{}
+	weights := map[[2]int]float64{}
+	for step := 0; step < 500; step++ {
+		i := rng.Intn(20)
+		j := rng.Intn(20)
+		if i == j {
+			continue
+		}
+		if i > j {
+			i, j = j, i
+		}
+		weight := weights[[2]int{i, j}]
+		if weight > 0.0 && rng.Intn(3) == 0 {
+			// integer weights keep the sums exact in any order
+			delta := float64(1 + rng.Intn(int(weight)))
+			incremental.RemoveConcurrence(i, j, delta)
+			weights[[2]int{i, j}] = weight - delta
+		} else {
+			delta := float64(1 + rng.Intn(3))
+			incremental.AddConcurrence(i, j, delta)
+			weights[[2]int{i, j}] = weight + delta
+		}
+	}
+
+	edges := []Edge{}
+	for pair, weight := range weights {
+		if weight > 0.0 {
+			edges = append(edges, Edge{pair[0], pair[1], weight})
+		}
+	}
+	rebuilt, err := newConcurrenceModelFromEdges(incremental.n, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModel(t, incremental, rebuilt)
+}
+
+func TestAddNodeThenConcurrence(t *testing.T) {
+	cm := twoTriangles(t)
+	u := cm.AddNode(3)
+	if u != 6 || cm.n != 7 {
+		t.Fatalf("AddNode = %d with n = %d, want 6 and 7", u, cm.n)
+	}
+	cm.AddConcurrence(u, 0, 0.5)
+	want := twoTriangles(t)
+	want.concurrences = append(want.concurrences, map[int]float64{})
+	want.cardinalities = append(want.cardinalities, 3)
+	want.concurrences[0][6] = 0.5
+	want.concurrences[6][0] = 0.5
+	want = newConcurrenceModel(want.concurrences, want.cardinalities)
+	assertSameModel(t, cm, want)
+}
+
+func TestPruneBelowToEmptyGraph(t *testing.T) {
+	cm := twoTriangles(t)
+	pruned := cm.PruneBelow(2.0)
+	if pruned.n != cm.n || pruned.sumConcurrences != 0.0 {
+		t.Fatalf("n = %d, sumConcurrences = %v, want %d and 0",
+			pruned.n, pruned.sumConcurrences, cm.n)
+	}
+	communities, communityIDs := pruned.DBScan(0.5, 2)
+	if len(communities) != cm.n {
+		t.Fatalf("DBScan on the empty graph = %v, want %d singletons", communities, cm.n)
+	}
+	for u := 0; u < cm.n; u++ {
+		if !communities[communityIDs[u]][u] || len(communities[communityIDs[u]]) != 1 {
+			t.Fatalf("node %d is not a singleton in %v", u, communities)
+		}
+	}
+}
+
+func TestPruneBelowDropsRemovedWeights(t *testing.T) {
+	// integer weights keep the sums exact in any order
+	rng := rand.New(rand.NewSource(1))
+	edges := []Edge{}
+	for u := 0; u < 50; u++ {
+		for v := u + 1; v < 50; v++ {
+			if rng.Intn(4) == 0 {
+				edges = append(edges, Edge{u, v, float64(1 + rng.Intn(5))})
+			}
+		}
+	}
+	cm, err := newConcurrenceModelFromEdges(50, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumBefore := cm.sumConcurrences
+	pruned := cm.PruneBelow(3)
+	removed := 0.0
+	removedOf := make([]float64, cm.n)
+	for _, edge := range edges {
+		if edge.W < 3 {
+			removed += edge.W
+			removedOf[edge.U] += edge.W
+			removedOf[edge.V] += edge.W
+		}
+	}
+	if removed == 0.0 || pruned.sumConcurrences != sumBefore-2*removed {
+		t.Fatalf("sumConcurrences = %v, want %v - 2 * %v", pruned.sumConcurrences, sumBefore,
+			removed)
+	}
+	for u := 0; u < cm.n; u++ {
+		if pruned.sumConcurrencesOf[u] != cm.sumConcurrencesOf[u]-removedOf[u] {
+			t.Fatalf("sumConcurrencesOf[%d] = %v, want %v - %v", u, pruned.sumConcurrencesOf[u],
+				cm.sumConcurrencesOf[u], removedOf[u])
+		}
+	}
+	if cm.sumConcurrences != sumBefore {
+		t.Fatal("PruneBelow modifies the original model")
+	}
+}
+
+func TestBinarizeAndTransformWeights(t *testing.T) {
+	cm := newTestModel(t, []Edge{{0, 1, 1}, {1, 2, 4}, {2, 3, 9}})
+	binary := cm.Binarize(4)
+	want := newTestModel(t, []Edge{{1, 2, 1}, {2, 3, 1}})
+	assertSameModel(t, binary, want)
+
+	scaled := cm.TransformWeights(func(weight float64) float64 {
+		return weight - 1.0
+	})
+	want, _ = newConcurrenceModelFromEdges(4, []Edge{{1, 2, 3}, {2, 3, 8}})
+	assertSameModel(t, scaled, want)
+}
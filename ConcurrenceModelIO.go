@@ -0,0 +1,619 @@
+package ConcurrenceBasedClustering
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// struct Edge
+// brief description: an undirected edge of a concurrence graph with its
+//	concurrence.
+type Edge struct {
+	U, V int
+	W    float64
+}
+
+// =============================================================================
+// func NewConcurrenceModelFromEdges
+// brief description: create a new ConcurrenceModel from a list of edges. The
+//	edges are symmetrized, and duplicated edges, including (u,v) and (v,u),
+//	are summed.
+// input:
+//	edges: a list of edges. Node IDs must be >= 0, weights must be > 0 and
+//		self-loops are rejected.
+// output:
+//	output 1: the new ConcurrenceModel. n is the largest node ID plus 1, and
+//		all cardinalities are 1.
+//	output 2: an error if the input is invalid, nil otherwise
+func NewConcurrenceModelFromEdges(edges []Edge) (ConcurrenceModel, error) {
+	return newConcurrenceModelFromEdges(0, edges)
+}
+
+// =============================================================================
+// func newConcurrenceModelFromEdges
+// brief description: the implementation of NewConcurrenceModelFromEdges
+// input:
+//	n: the minimum number of nodes in the result.
+//	edges: a list of edges.
+// output:
+//	the same as NewConcurrenceModelFromEdges
+func newConcurrenceModelFromEdges(n int, edges []Edge) (ConcurrenceModel, error) {
+	// -------------------------------------------------------------------------
+	// step 1: check the edges and find the number of nodes
+	for _, edge := range edges {
+		if edge.U < 0 || edge.V < 0 {
+			return ConcurrenceModel{}, fmt.Errorf("invalid edge (%d, %d)", edge.U, edge.V)
+		}
+		if edge.U == edge.V {
+			return ConcurrenceModel{}, fmt.Errorf("self-loop at node %d", edge.U)
+		}
+		if edge.W <= 0.0 {
+			return ConcurrenceModel{}, fmt.Errorf("weight of edge (%d, %d) = %v must be > 0",
+				edge.U, edge.V, edge.W)
+		}
+		if edge.U >= n {
+			n = edge.U + 1
+		}
+		if edge.V >= n {
+			n = edge.V + 1
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: accumulate the edges symmetrically
+	concurrences := make([]map[int]float64, n)
+	cardinalities := make([]int, n)
+	for i := 0; i < n; i++ {
+		concurrences[i] = map[int]float64{}
+		cardinalities[i] = 1
+	}
+	for _, edge := range edges {
+		concurrences[edge.U][edge.V] += edge.W
+		concurrences[edge.V][edge.U] += edge.W
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return newConcurrenceModel(concurrences, cardinalities), nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) GetEdges
+// brief description: list the edges of the concurrence graph
+// output:
+//	the edges (u,v) with u < v, sorted by u and then v.
+func (cm ConcurrenceModel) GetEdges() []Edge {
+	edges := []Edge{}
+	for u := 0; u < cm.n; u++ {
+		for v, weightUV := range cm.concurrences[u] {
+			if u < v {
+				edges = append(edges, Edge{U: u, V: v, W: weightUV})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].U != edges[j].U {
+			return edges[i].U < edges[j].U
+		}
+		return edges[i].V < edges[j].V
+	})
+	return edges
+}
+
+// =============================================================================
+// func LoadConcurrencesTSV
+// brief description: read a ConcurrenceModel from a TSV stream.
+// input:
+//	r: the stream. Each line is either "u\tv\tweight" for an edge, or "u" for
+//		a node that may be isolated. Blank lines and lines starting with "#"
+//		are ignored. Edges are accumulated as in NewConcurrenceModelFromEdges.
+// output:
+//	output 1: the ConcurrenceModel read from r.
+//	output 2: an error if the stream is invalid, nil otherwise
+func LoadConcurrencesTSV(r io.Reader) (ConcurrenceModel, error) {
+	// -------------------------------------------------------------------------
+	// step 1: parse the lines
+	n := 0
+	edges := []Edge{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		switch len(fields) {
+		case 1:
+			u, err := strconv.Atoi(fields[0])
+			if err != nil || u < 0 {
+				return ConcurrenceModel{}, fmt.Errorf("line %d: invalid node %q", lineNo, fields[0])
+			}
+			if u >= n {
+				n = u + 1
+			}
+		case 3:
+			u, errU := strconv.Atoi(fields[0])
+			v, errV := strconv.Atoi(fields[1])
+			w, errW := strconv.ParseFloat(fields[2], 64)
+			if errU != nil || errV != nil || errW != nil {
+				return ConcurrenceModel{}, fmt.Errorf("line %d: invalid edge %q", lineNo, line)
+			}
+			edges = append(edges, Edge{U: u, V: v, W: w})
+		default:
+			return ConcurrenceModel{}, fmt.Errorf("line %d: expect 1 or 3 fields, got %d",
+				lineNo, len(fields))
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return ConcurrenceModel{}, err
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: create the model
+	cm, err := newConcurrenceModelFromEdges(n, edges)
+	if err != nil {
+		return ConcurrenceModel{}, fmt.Errorf("invalid TSV input: %v", err)
+	}
+	return cm, nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) WriteTSV
+// brief description: write the ConcurrenceModel as a TSV stream that can be
+//	read back by LoadConcurrencesTSV.
+// input:
+//	w: the stream.
+// output:
+//	an error if writing fails, nil otherwise
+// note:
+//	Isolated nodes are written as single-field lines so that n is preserved.
+//	Cardinalities are not written, so they are all 1 when read back.
+func (cm ConcurrenceModel) WriteTSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	_, err := fmt.Fprintf(bw, "# u\tv\tweight\n")
+	if err != nil {
+		return err
+	}
+	for u := 0; u < cm.n; u++ {
+		if len(cm.concurrences[u]) == 0 {
+			_, err = fmt.Fprintf(bw, "%d\n", u)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, edge := range cm.GetEdges() {
+		_, err = fmt.Fprintf(bw, "%d\t%d\t%s\n",
+			edge.U, edge.V, strconv.FormatFloat(edge.W, 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// =============================================================================
+// structs for GraphML
+// brief description: These structs map the subset of GraphML used by
+//	WriteGraphML and LoadGraphML onto encoding/xml.
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr,omitempty"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID      string `xml:"id,attr"`
+	For     string `xml:"for,attr"`
+	Name    string `xml:"attr.name,attr"`
+	Type    string `xml:"attr.type,attr"`
+	Default string `xml:"default,omitempty"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) WriteGraphML
+// brief description: write the concurrence graph as GraphML, e.g. for Gephi or
+//	igraph. Nodes are written with IDs "n0", "n1", ..., and edges with their
+//	concurrences as the "weight" attribute.
+// input:
+//	w: the stream.
+//	communities: a list of clusters. If it is not nil, each node in a cluster
+//		gets the index of the cluster as its "community" attribute.
+// output:
+//	an error if the communities overlap or writing fails, nil otherwise
+func (cm ConcurrenceModel) WriteGraphML(w io.Writer, communities []map[int]bool) error {
+	return cm.writeGraphML(w, communities, nil)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) writeGraphML
+// brief description: the implementation of WriteGraphML
+// input:
+//	w: the stream.
+//	communities: a list of clusters, may be nil.
+//	labels: the "label" attribute of each node, may be nil.
+// output:
+//	an error if the communities overlap or writing fails, nil otherwise
+func (cm ConcurrenceModel) writeGraphML(w io.Writer, communities []map[int]bool,
+	labels []string) error {
+	// -------------------------------------------------------------------------
+	// step 1: find the community of each node
+	communityIDs := make([]int, cm.n)
+	for u := 0; u < cm.n; u++ {
+		communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			if u < 0 || u >= cm.n {
+				return fmt.Errorf("invalid node %d in community %d", u, idxC)
+			}
+			if communityIDs[u] >= 0 {
+				return fmt.Errorf("node %d is in both community %d and %d",
+					u, communityIDs[u], idxC)
+			}
+			communityIDs[u] = idxC
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: build the document
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "weight", For: "edge", Name: "weight", Type: "double", Default: "1"},
+		},
+		Graph: graphMLGraph{ID: "G", EdgeDefault: "undirected"},
+	}
+	if communities != nil {
+		doc.Keys = append(doc.Keys,
+			graphMLKey{ID: "community", For: "node", Name: "community", Type: "int"})
+	}
+	if labels != nil {
+		doc.Keys = append(doc.Keys,
+			graphMLKey{ID: "label", For: "node", Name: "label", Type: "string"})
+	}
+	for u := 0; u < cm.n; u++ {
+		node := graphMLNode{ID: fmt.Sprintf("n%d", u)}
+		if communityIDs[u] >= 0 {
+			node.Data = append(node.Data,
+				graphMLData{Key: "community", Value: strconv.Itoa(communityIDs[u])})
+		}
+		if u < len(labels) {
+			node.Data = append(node.Data, graphMLData{Key: "label", Value: labels[u]})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, edge := range cm.GetEdges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: fmt.Sprintf("n%d", edge.U),
+			Target: fmt.Sprintf("n%d", edge.V),
+			Data: []graphMLData{
+				{Key: "weight", Value: strconv.FormatFloat(edge.W, 'g', -1, 64)},
+			},
+		})
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: write the document
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// =============================================================================
+// func LoadGraphML
+// brief description: read a ConcurrenceModel from GraphML.
+// input:
+//	r: the stream. Nodes get dense IDs in the order they appear. The edge
+//		attribute named "weight" is used as the concurrence, defaulting to the
+//		key's default value or 1 when missing. Duplicated edges are summed.
+// output:
+//	output 1: the ConcurrenceModel read from r.
+//	output 2: an error if the stream is invalid, nil otherwise
+func LoadGraphML(r io.Reader) (ConcurrenceModel, error) {
+	cm, _, _, err := readGraphML(r)
+	return cm, err
+}
+
+// =============================================================================
+// func LoadGraphMLWithCommunities
+// brief description: read a ConcurrenceModel and the communities of its nodes
+//	from GraphML, e.g. a file written by WriteGraphML.
+// input:
+//	r: the stream. See LoadGraphML.
+// output:
+//	output 1: the ConcurrenceModel read from r.
+//	output 2: the communities given by the "community" node attribute, ordered
+//		by the attribute values. Nodes without the attribute are left out.
+//	output 3: an error if the stream is invalid, nil otherwise
+func LoadGraphMLWithCommunities(r io.Reader) (ConcurrenceModel, []map[int]bool, error) {
+	cm, communities, _, err := readGraphML(r)
+	return cm, communities, err
+}
+
+// =============================================================================
+// func readGraphML
+// brief description: the implementation of the GraphML loaders
+// input:
+//	r: the stream.
+// output:
+//	output 1: the ConcurrenceModel read from r.
+//	output 2: the communities given by the "community" node attribute.
+//	output 3: the "label" attribute of each node, or its GraphML ID if it has
+//		no label.
+//	output 4: an error if the stream is invalid, nil otherwise
+func readGraphML(r io.Reader) (ConcurrenceModel, []map[int]bool, []string, error) {
+	// -------------------------------------------------------------------------
+	// step 1: decode the document
+	var doc graphMLDocument
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return ConcurrenceModel{}, nil, nil, err
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: find the keys of weight, community and label
+	weightKey, communityKey, labelKey := "", "", ""
+	defaultWeight := 1.0
+	for _, key := range doc.Keys {
+		switch {
+		case key.For == "edge" && key.Name == "weight":
+			weightKey = key.ID
+			if key.Default != "" {
+				defaultWeight, err = strconv.ParseFloat(strings.TrimSpace(key.Default), 64)
+				if err != nil {
+					return ConcurrenceModel{}, nil, nil,
+						fmt.Errorf("invalid default weight %q", key.Default)
+				}
+			}
+		case key.For == "node" && key.Name == "community":
+			communityKey = key.ID
+		case key.For == "node" && key.Name == "label":
+			labelKey = key.ID
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: read the nodes
+	n := len(doc.Graph.Nodes)
+	ids := make(map[string]int, n)
+	labels := make([]string, n)
+	communityOf := map[int]map[int]bool{}
+	for u, node := range doc.Graph.Nodes {
+		_, exists := ids[node.ID]
+		if exists {
+			return ConcurrenceModel{}, nil, nil, fmt.Errorf("duplicated node %q", node.ID)
+		}
+		ids[node.ID] = u
+		labels[u] = node.ID
+		for _, data := range node.Data {
+			switch data.Key {
+			case labelKey:
+				labels[u] = data.Value
+			case communityKey:
+				c, err := strconv.Atoi(strings.TrimSpace(data.Value))
+				if err != nil {
+					return ConcurrenceModel{}, nil, nil,
+						fmt.Errorf("invalid community %q of node %q", data.Value, node.ID)
+				}
+				_, exists := communityOf[c]
+				if !exists {
+					communityOf[c] = map[int]bool{}
+				}
+				communityOf[c][u] = true
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: read the edges
+	edges := make([]Edge, 0, len(doc.Graph.Edges))
+	for _, edge := range doc.Graph.Edges {
+		u, existsU := ids[edge.Source]
+		v, existsV := ids[edge.Target]
+		if !existsU || !existsV {
+			return ConcurrenceModel{}, nil, nil,
+				fmt.Errorf("edge (%q, %q) has unknown nodes", edge.Source, edge.Target)
+		}
+		weight := defaultWeight
+		for _, data := range edge.Data {
+			if data.Key == weightKey {
+				weight, err = strconv.ParseFloat(strings.TrimSpace(data.Value), 64)
+				if err != nil {
+					return ConcurrenceModel{}, nil, nil,
+						fmt.Errorf("invalid weight %q of edge (%q, %q)",
+							data.Value, edge.Source, edge.Target)
+				}
+			}
+		}
+		edges = append(edges, Edge{U: u, V: v, W: weight})
+	}
+	cm, err := newConcurrenceModelFromEdges(n, edges)
+	if err != nil {
+		return ConcurrenceModel{}, nil, nil, fmt.Errorf("invalid GraphML input: %v", err)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 5: order the communities by their attribute values
+	communityValues := make([]int, 0, len(communityOf))
+	for c, _ := range communityOf {
+		communityValues = append(communityValues, c)
+	}
+	sort.Ints(communityValues)
+	communities := make([]map[int]bool, len(communityValues))
+	for i, c := range communityValues {
+		communities[i] = communityOf[c]
+	}
+
+	// -------------------------------------------------------------------------
+	// step 6: return the result
+	return cm, communities, labels, nil
+}
+
+// =============================================================================
+// struct DotOptions
+// brief description: This is a struct for the options of ExportDOT.
+type DotOptions struct {
+	// MinWeight is the minimum concurrence of the edges drawn.
+	MinWeight float64
+
+	// ClusterSubgraphs collapses each community into a cluster subgraph.
+	ClusterSubgraphs bool
+
+	// Palette is the list of fill colors of communities. Colors are reused
+	// cyclically when there are more communities than colors. If it is
+	// empty, a default palette is used.
+	Palette []string
+}
+
+// =============================================================================
+// var defaultDotPalette
+// brief description: the default fill colors of communities in ExportDOT
+var defaultDotPalette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// =============================================================================
+// func ExportDOT
+// brief description: write the concurrence graph as a Graphviz graph for
+//	debugging small clusterings. The thickness of an edge is proportional to
+//	its concurrence, and nodes are filled with the color of their community.
+// input:
+//	w: the stream.
+//	cm: the concurrence model.
+//	communities: a list of clusters, may be nil. Nodes outside all clusters
+//		are filled white.
+//	opts: the options.
+// output:
+//	an error if the communities overlap or writing fails, nil otherwise
+func ExportDOT(w io.Writer, cm ConcurrenceModel, communities []map[int]bool, opts DotOptions) error {
+	// -------------------------------------------------------------------------
+	// step 1: find the community of each node and the color of each community
+	communityIDs := make([]int, cm.n)
+	for u := 0; u < cm.n; u++ {
+		communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			if u < 0 || u >= cm.n {
+				return fmt.Errorf("invalid node %d in community %d", u, idxC)
+			}
+			if communityIDs[u] >= 0 {
+				return fmt.Errorf("node %d is in both community %d and %d",
+					u, communityIDs[u], idxC)
+			}
+			communityIDs[u] = idxC
+		}
+	}
+	palette := opts.Palette
+	if len(palette) == 0 {
+		palette = defaultDotPalette
+	}
+	colorOf := func(u int) string {
+		if communityIDs[u] < 0 {
+			return "white"
+		}
+		return palette[communityIDs[u]%len(palette)]
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: write the nodes
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "graph G {\n")
+	fmt.Fprintf(bw, "  node [style=filled];\n")
+	writeNode := func(indent string, u int) {
+		fmt.Fprintf(bw, "%sn%d [label=\"%d\", fillcolor=\"%s\"];\n", indent, u, u, colorOf(u))
+	}
+	if opts.ClusterSubgraphs {
+		for idxC, c := range communities {
+			members := make([]int, 0, len(c))
+			for u, _ := range c {
+				members = append(members, u)
+			}
+			sort.Ints(members)
+			fmt.Fprintf(bw, "  subgraph cluster_%d {\n", idxC)
+			fmt.Fprintf(bw, "    label=\"community %d\";\n", idxC)
+			for _, u := range members {
+				writeNode("    ", u)
+			}
+			fmt.Fprintf(bw, "  }\n")
+		}
+		for u := 0; u < cm.n; u++ {
+			if communityIDs[u] < 0 {
+				writeNode("  ", u)
+			}
+		}
+	} else {
+		for u := 0; u < cm.n; u++ {
+			writeNode("  ", u)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: write the edges with thickness proportional to their weights
+	edges := []Edge{}
+	maxWeight := 0.0
+	for _, edge := range cm.GetEdges() {
+		if edge.W < opts.MinWeight {
+			continue
+		}
+		edges = append(edges, edge)
+		if edge.W > maxWeight {
+			maxWeight = edge.W
+		}
+	}
+	for _, edge := range edges {
+		penWidth := 1.0 + 4.0*edge.W/maxWeight
+		fmt.Fprintf(bw, "  n%d -- n%d [penwidth=%s, tooltip=\"%s\"];\n", edge.U, edge.V,
+			strconv.FormatFloat(penWidth, 'f', 2, 64),
+			strconv.FormatFloat(edge.W, 'g', -1, 64))
+	}
+	fmt.Fprintf(bw, "}\n")
+
+	// -------------------------------------------------------------------------
+	// step 4: flush the output, which also reports any error while writing
+	return bw.Flush()
+}
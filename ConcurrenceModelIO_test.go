@@ -0,0 +1,109 @@
+package ConcurrenceBasedClustering
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestTSVRoundTrip(t *testing.T) {
+	// node 7 is isolated, so it is only kept by a single-field line
+	cm, err := newConcurrenceModelFromEdges(8, []Edge{{0, 1, 1}, {1, 0, 2}, {1, 2, 0.1},
+		{4, 2, 1e-12}, {5, 6, 12345.678}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cm.WriteTSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadConcurrencesTSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModel(t, reloaded, cm)
+}
+
+func TestLoadConcurrencesTSVRejectsBadLines(t *testing.T) {
+	for _, input := range []string{"0\t1\n", "0\t1\tx\n", "0\t0\t1\n", "0\t1\t-1\n", "-3\n"} {
+		_, err := LoadConcurrencesTSV(strings.NewReader(input))
+		if err == nil {
+			t.Fatalf("%q is accepted", input)
+		}
+	}
+}
+
+func TestGraphMLRoundTripWithCommunities(t *testing.T) {
+	cm := ringOfCliques(t, 3, 4)
+	cm.AddConcurrence(0, 4, 0.25)
+	communities := []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true},
+		{4: true, 5: true, 6: true, 7: true},
+		{8: true, 9: true, 10: true, 11: true},
+	}
+	var buf bytes.Buffer
+	if err := cm.WriteGraphML(&buf, communities); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, reloadedCommunities, err := LoadGraphMLWithCommunities(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModel(t, reloaded, cm)
+	assertSamePartition(t, reloadedCommunities, communities)
+}
+
+func TestLoadGraphMLDefaultWeight(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="w" for="edge" attr.name="weight" attr.type="double"></key>
+  <graph id="G" edgedefault="undirected">
+    <node id="a"></node>
+    <node id="b"></node>
+    <node id="c"></node>
+    <edge source="a" target="b"><data key="w">2.5</data></edge>
+    <edge source="b" target="c"></edge>
+  </graph>
+</graphml>
+`
+	cm, err := LoadGraphML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.GetN() != 3 || cm.GetConcurrence(0, 1) != 2.5 || cm.GetConcurrence(1, 2) != 1.0 {
+		t.Fatalf("n = %d, concurrences = %v", cm.GetN(), cm.concurrences)
+	}
+}
+
+func TestExportDOTGolden(t *testing.T) {
+	cm := twoTriangles(t)
+	cm.AddConcurrence(0, 5, 0.05)
+	communities := []map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true}}
+	for name, opts := range map[string]DotOptions{
+		"two_triangles.dot":          {MinWeight: 0.1},
+		"two_triangles_clusters.dot": {ClusterSubgraphs: true, Palette: []string{"red"}},
+	} {
+		var buf bytes.Buffer
+		if err := ExportDOT(&buf, cm, communities, opts); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join("testdata", name)
+		if *updateGolden {
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(want) {
+			t.Fatalf("%s differs:\n%s\nwant\n%s", name, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+)
+
+// =============================================================================
+// func getLouvainRun
+// brief description: run multi-level Louvain once
+// input:
+//	qm: a quality model.
+//	opts: the options of the run.
+// output:
+//	output 1: the communities of the last level, nil if no level is computed,
+//		e.g. because of a timeout.
+//	output 2: the community ID of each node, nil if output 1 is nil.
+func getLouvainRun(qm QualityModel, opts ClusteringOptions) ([]map[int]bool, []int) {
+	levels, _ := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+	if len(levels) == 0 {
+		return nil, nil
+	}
+	communities := levels[len(levels)-1]
+	communityIDs := make([]int, qm.GetN())
+	for c, community := range communities {
+		for u, _ := range community {
+			communityIDs[u] = c
+		}
+	}
+	return communities, communityIDs
+}
+
+// =============================================================================
+// func getRunOptions
+// brief description: get the options of one of several independent runs.
+// input:
+//	opts: the options of the runs.
+//	run: the index of the run.
+// output:
+//	opts of the run. If opts.Seed is not 0, the run gets its own seed derived
+//	from it, so that the runs differ but are reproducible given opts.Seed.
+func getRunOptions(opts ClusteringOptions, run int) ClusteringOptions {
+	runOpts := opts
+	if opts.Seed != 0 {
+		runOpts.Seed = int64(mixSeed(opts.Seed, run) | 1)
+	}
+	return runOpts
+}
+
+// =============================================================================
+// func getCoassociations
+// brief description: run multi-level Louvain several times and count, for
+//	each pair of neighbors in the quality model, how often they are in the
+//	same community.
+// input:
+//	qm: a quality model.
+//	numRuns: the number of runs.
+//	opts: the options of each run. If opts.Seed is not 0, each run gets its
+//		own seed derived from it, see getRunOptions.
+// output:
+//	the fraction of runs putting each pair of neighbors together, symmetric
+//	and keyed like qm.GetNeighbors. Pairs never together are not stored.
+func getCoassociations(qm QualityModel, numRuns int, opts ClusteringOptions) []map[int]float64 {
+	n := qm.GetN()
+	coassociations := make([]map[int]float64, n)
+	for u := 0; u < n; u++ {
+		coassociations[u] = map[int]float64{}
+	}
+	for run := 0; run < numRuns; run++ {
+		_, communityIDs := getLouvainRun(qm, getRunOptions(opts, run))
+		if communityIDs == nil {
+			continue
+		}
+		for u := 0; u < n; u++ {
+			for v, _ := range qm.GetNeighbors(u) {
+				if v != u && communityIDs[u] == communityIDs[v] {
+					coassociations[u][v] += 1.0 / float64(numRuns)
+				}
+			}
+		}
+	}
+	return coassociations
+}
+
+// =============================================================================
+// func Coassignments
+// brief description: the co-assignment frequencies of the pairs of neighbors
+//	over several runs of the stochastic multi-level Louvain, for inspecting
+//	how stable a clustering is. See getCoassociations.
+// input:
+//	qm: a quality model.
+//	numRuns: the number of runs, must be > 0.
+//	opts: the options of each run. If opts.Seed is not 0, each run gets its
+//		own seed derived from it, see getRunOptions.
+// output:
+//	the fraction of runs putting each pair of neighbors together, symmetric
+//	and keyed like qm.GetNeighbors. Pairs never together are not stored.
+func Coassignments(qm QualityModel, numRuns int, opts ClusteringOptions) []map[int]float64 {
+	if numRuns <= 0 {
+		log.Fatalln(fmt.Sprintf("numRuns = %d must be > 0", numRuns))
+	}
+	return getCoassociations(qm, numRuns, opts)
+}
+
+// =============================================================================
+// func getConsensusModel
+// brief description: build the consensus concurrence model, whose concurrences
+//	are the co-associations at least a threshold.
+// input:
+//	coassociations: the co-association of each pair
+//	threshold: the threshold
+//	cardinalities: the cardinality of each node
+// output:
+//	output 1: the consensus model
+//	output 2: whether the consensus is stable, i.e. every pair kept is always
+//		together, so that the consensus graph is a union of cliques that any
+//		further run would reproduce.
+func getConsensusModel(coassociations []map[int]float64, threshold float64,
+	cardinalities []int) (ConcurrenceModel, bool) {
+	stable := true
+	concurrences := make([]map[int]float64, len(coassociations))
+	for u, coassociationsOfU := range coassociations {
+		concurrences[u] = map[int]float64{}
+		for v, coassociationUV := range coassociationsOfU {
+			if coassociationUV >= threshold {
+				concurrences[u][v] = coassociationUV
+				if coassociationUV < 1.0-consensusTolerance {
+					stable = false
+				}
+			}
+		}
+	}
+	return newConcurrenceModel(concurrences, append([]int(nil), cardinalities...)), stable
+}
+
+// =============================================================================
+// const consensusTolerance
+// brief description: the tolerance of the rounding errors of co-associations
+//	summed from fractions of runs.
+const consensusTolerance = 1e-9
+
+// =============================================================================
+// const qualityTieTolerance
+// brief description: the relative tolerance under which the qualities of two
+//	runs are a tie. Quality sums floats in the iteration order of maps, so the
+//	same partition gets slightly different values on different calls.
+const qualityTieTolerance = 1e-12
+
+// =============================================================================
+// func isBetterQuality
+// brief description: compare the qualities of two runs up to rounding errors
+// input:
+//	quality: the quality of a run
+//	bestQuality: the best quality so far
+// output:
+//	true if quality is larger than bestQuality beyond qualityTieTolerance
+func isBetterQuality(quality, bestQuality float64) bool {
+	scale := math.Max(math.Abs(quality), math.Abs(bestQuality))
+	return quality-bestQuality > qualityTieTolerance*scale
+}
+
+// =============================================================================
+// const maxConsensusRounds
+// brief description: the maximum number of rounds of ConsensusClustering. The
+//	consensus usually becomes stable within a few rounds.
+const maxConsensusRounds = 20
+
+// =============================================================================
+// func getThresholdComponents
+// brief description: find the connected components of the pairs whose
+//	co-association is at least a threshold
+// input:
+//	coassociations: the co-association of each pair
+//	threshold: the threshold
+// output:
+//	the components, ordered by their smallest members
+func getThresholdComponents(coassociations []map[int]float64, threshold float64) []map[int]bool {
+	uf := newUnionFind(len(coassociations))
+	for u, coassociationsOfU := range coassociations {
+		for v, coassociationUV := range coassociationsOfU {
+			if coassociationUV >= threshold {
+				uf.union(u, v)
+			}
+		}
+	}
+	communities, _ := uf.sets()
+	return communities
+}
+
+// =============================================================================
+// func ConsensusClustering
+// brief description: combine several runs of the stochastic multi-level
+//	Louvain into a robust consensus partition, by the recursive procedure of
+//	Lancichinetti and Fortunato: the co-associations of the runs at least a
+//	threshold form a consensus graph, which is clustered again by numRuns runs
+//	of Louvain with modularity, until every pair kept is always together.
+// input:
+//	qm: a quality model.
+//	numRuns: the number of runs, must be > 0.
+//	threshold: the minimum fraction of runs, within (0, 1], that must put two
+//		neighbors together for them to be linked in the consensus.
+//	opts: the options of each run. If opts.Seed is not 0, each run gets its
+//		own seed derived from it, see getRunOptions.
+// output:
+//	the consensus partition, i.e. the connected components of the neighbors
+//	linked in the last consensus graph, ordered by their smallest members.
+// note:
+//	Only pairs of neighbors in the quality model are counted, so the memory
+//	grows with the number of concurrences instead of n^2. The recursion stops
+//	after maxConsensusRounds rounds if the consensus is not yet stable. Use
+//	Coassignments to inspect the co-associations of the first round.
+func ConsensusClustering(qm QualityModel, numRuns int, threshold float64,
+	opts ClusteringOptions) []map[int]bool {
+	if numRuns <= 0 {
+		log.Fatalln(fmt.Sprintf("numRuns = %d must be > 0", numRuns))
+	}
+
+	// -------------------------------------------------------------------------
+	// step 1: count the co-associations on the original quality model
+	coassociations := getCoassociations(qm, numRuns, opts)
+
+	// -------------------------------------------------------------------------
+	// step 2: cluster the consensus graph again until it is stable
+	cardinalities := getNodeSizes(qm)
+	for round := 1; round < maxConsensusRounds; round++ {
+		consensus, stable := getConsensusModel(coassociations, threshold, cardinalities)
+		if stable {
+			break
+		}
+		coassociations = getCoassociations(NewModularity(1.0, consensus), numRuns, opts)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the components of the consensus
+	return getThresholdComponents(coassociations, threshold)
+}
+
+// =============================================================================
+// func MembershipStability
+// brief description: score how stable the community of each node is over
+//	several runs of the stochastic multi-level Louvain.
+// input:
+//	qm: a quality model.
+//	numRuns: the number of runs, must be > 0.
+//	opts: the options of each run. If opts.Seed is not 0, each run gets its
+//		own seed derived from it, see getRunOptions.
+// output:
+//	output 1: the stability of each node within [0, 1], 1 meaning perfectly
+//		stable. See the note.
+//	output 2: the reference partition, i.e. the run with the highest quality,
+//		ordered by the smallest members of communities. The earliest run wins
+//		ties, as in BestOfN.
+// note:
+//	For a node u with community R in the reference partition, a run matches R
+//	to its community holding the plurality of the members of R, the smallest
+//	community ID winning ties. u is stable in the run if it is in the matched
+//	community, and its stability is the fraction of runs where it is stable,
+//	the reference run included. Runs stopped before completing a level, e.g.
+//	by a timeout, are not counted.
+func MembershipStability(qm QualityModel, numRuns int, opts ClusteringOptions) ([]float64,
+	[]map[int]bool) {
+	if numRuns <= 0 {
+		log.Fatalln(fmt.Sprintf("numRuns = %d must be > 0", numRuns))
+	}
+
+	// -------------------------------------------------------------------------
+	// step 1: run Louvain and pick the run with the highest quality
+	n := qm.GetN()
+	runs := [][]int{}
+	var reference []map[int]bool
+	bestQuality := 0.0
+	idxReference := -1
+	for run := 0; run < numRuns; run++ {
+		communities, communityIDs := getLouvainRun(qm, getRunOptions(opts, run))
+		if communities == nil {
+			continue
+		}
+		quality := qm.Quality(communities)
+		if idxReference < 0 || isBetterQuality(quality, bestQuality) {
+			reference = communities
+			bestQuality = quality
+			idxReference = len(runs)
+		}
+		runs = append(runs, communityIDs)
+	}
+	if len(runs) == 0 {
+		return make([]float64, n), nil
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: score the nodes against the reference
+	return getMembershipStabilities(n, reference, runs), reference
+}
+
+// =============================================================================
+// func getMembershipStabilities
+// brief description: score how stable the community of each node is over
+//	several runs. See MembershipStability.
+// input:
+//	n: the number of nodes
+//	reference: the reference partition
+//	runs: the community ID of each node in each run, at least one run
+// output:
+//	the stability of each node within [0, 1]
+func getMembershipStabilities(n int, reference []map[int]bool, runs [][]int) []float64 {
+	// -------------------------------------------------------------------------
+	// step 1: count the runs where each node is in the matched community of
+	// its reference community
+	stabilities := make([]float64, n)
+	for _, communityIDs := range runs {
+		for _, c := range reference {
+			// (1.1) match c to the community of the run holding most of it
+			counts := map[int]int{}
+			for u, _ := range c {
+				counts[communityIDs[u]]++
+			}
+			matched := -1
+			for idxC, count := range counts {
+				if matched < 0 || count > counts[matched] || (count == counts[matched] && idxC < matched) {
+					matched = idxC
+				}
+			}
+
+			// (1.2) count the members of c in the matched community
+			for u, _ := range c {
+				if communityIDs[u] == matched {
+					stabilities[u] += 1.0
+				}
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: return the fractions
+	for u := 0; u < n; u++ {
+		stabilities[u] /= float64(len(runs))
+	}
+	return stabilities
+}
+
+// =============================================================================
+// func BestOfN
+// brief description: run the stochastic multi-level Louvain several times and
+//	keep the partition with the highest quality.
+// input:
+//	qm: a quality model.
+//	numRuns: the number of runs, must be > 0.
+//	opts: the options of each run. The runs are independent, so opts.NumWorkers
+//		runs are executed concurrently, each with a single goroutine. If
+//		opts.Seed is not 0, each run gets its own seed derived from it, so the
+//		results are reproducible given opts.Seed.
+// output:
+//	output 1: the partition of the best run, ordered by the smallest members of
+//		communities. The earliest run wins ties, i.e. qualities within a
+//		relative qualityTieTolerance.
+//	output 2: the quality of the best run
+//	output 3: the quality of each run, NaN for runs stopped before completing
+//		a level, e.g. by a timeout.
+func BestOfN(qm QualityModel, numRuns int, opts ClusteringOptions) ([]map[int]bool, float64,
+	[]float64) {
+	if numRuns <= 0 {
+		log.Fatalln(fmt.Sprintf("numRuns = %d must be > 0", numRuns))
+	}
+
+	// -------------------------------------------------------------------------
+	// step 1: run Louvain concurrently
+	results := make([][]map[int]bool, numRuns)
+	qualities := make([]float64, numRuns)
+	numWorkers := getNumWorkers(opts.NumWorkers)
+	runs := make(chan int, numRuns)
+	for run := 0; run < numRuns; run++ {
+		runs <- run
+	}
+	close(runs)
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for idxWorker := 0; idxWorker < numWorkers; idxWorker++ {
+		go func() {
+			for run := range runs {
+				runOpts := getRunOptions(opts, run)
+				runOpts.NumWorkers = 1
+				results[run], _ = getLouvainRun(qm, runOpts)
+				if results[run] == nil {
+					qualities[run] = math.NaN()
+				} else {
+					qualities[run] = qm.Quality(results[run])
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	// -------------------------------------------------------------------------
+	// step 2: pick the best run
+	best := -1
+	for run := 0; run < numRuns; run++ {
+		if results[run] != nil && (best < 0 || isBetterQuality(qualities[run], qualities[best])) {
+			best = run
+		}
+	}
+	if best < 0 {
+		return nil, math.NaN(), qualities
+	}
+	return results[best], qualities[best], qualities
+}
@@ -0,0 +1,119 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestConsensusRecoversPlantedPartitionMoreOften(t *testing.T) {
+	const numGraphs = 10
+	const numRuns = 20
+	numSingleRecoveries := 0
+	numConsensusRecoveries := 0
+	for g := int64(1); g <= numGraphs; g++ {
+		cm, truth := plantedPartition(t, rand.New(rand.NewSource(g)), 4, 25, 0.4, 0.05)
+		qm := NewModularity(1.0, cm)
+		want := Partition(truth).Canonicalize()
+		opts := ClusteringOptions{MaxIters: 100, Seed: g}
+		for run := 0; run < numRuns; run++ {
+			communities, _ := getLouvainRun(qm, getRunOptions(opts, run))
+			if reflect.DeepEqual(Partition(communities).Canonicalize(), want) {
+				numSingleRecoveries++
+			}
+		}
+		consensus := ConsensusClustering(qm, numRuns, 0.5, opts)
+		if reflect.DeepEqual(Partition(consensus).Canonicalize(), want) {
+			numConsensusRecoveries++
+		}
+	}
+	singleRate := float64(numSingleRecoveries) / (numGraphs * numRuns)
+	consensusRate := float64(numConsensusRecoveries) / numGraphs
+	if consensusRate <= singleRate {
+		t.Fatalf("the consensus recovers %v of the planted partitions, single runs %v",
+			consensusRate, singleRate)
+	}
+}
+
+func TestBestOfNIsReproducibleFromSeed(t *testing.T) {
+	// the runs tie on many equivalent partitions of the ring, so the winner
+	// must not depend on the rounding of their qualities
+	qm := NewModularity(1.0, ringOfCliques(t, 20, 4))
+	var wantBest []map[int]bool
+	var wantQualities []float64
+	for _, numWorkers := range []int{1, 4} {
+		opts := ClusteringOptions{MaxIters: 100, Seed: 11, NumWorkers: numWorkers}
+		for call := 0; call < 15; call++ {
+			best, _, qualities := BestOfN(qm, 20, opts)
+			if wantBest == nil {
+				wantBest, wantQualities = best, qualities
+				continue
+			}
+			if !reflect.DeepEqual(best, wantBest) {
+				t.Fatalf("workers = %d, call %d: best = %v, want %v", numWorkers, call,
+					best, wantBest)
+			}
+			for run, quality := range qualities {
+				if math.Abs(quality-wantQualities[run]) > 1e-12 {
+					t.Fatalf("workers = %d, call %d: quality of run %d = %v, want %v",
+						numWorkers, call, run, quality, wantQualities[run])
+				}
+			}
+		}
+	}
+}
+
+func TestMembershipStability(t *testing.T) {
+	// -------------------------------------------------------------------------
+	// step 1: the scores follow the documented matching on hand-built runs.
+	// In the third run, the members of {0, 1, 2} are in 3 communities, so the
+	// smallest community ID 0 is matched and only node 0 is stable.
+	reference := []map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true}}
+	runs := [][]int{{0, 0, 0, 1, 1}, {0, 0, 1, 1, 1}, {0, 1, 2, 3, 3}}
+	got := getMembershipStabilities(5, reference, runs)
+	want := []float64{1, 2.0 / 3.0, 1.0 / 3.0, 1, 1}
+	for u, stability := range got {
+		if math.Abs(stability-want[u]) > 1e-12 {
+			t.Fatalf("stabilities = %v, want %v", got, want)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: every run agrees on two disconnected cliques
+	edges := append(cliqueEdges(0, 5, 1), cliqueEdges(5, 5, 1)...)
+	qm := NewModularity(1.0, newTestModel(t, edges))
+	stabilities, reference := MembershipStability(qm, 10, ClusteringOptions{MaxIters: 100, Seed: 1})
+	assertSamePartition(t, reference, []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true, 4: true}, {5: true, 6: true, 7: true, 8: true, 9: true}})
+	for u, stability := range stabilities {
+		if stability != 1.0 {
+			t.Fatalf("stability of %d = %v, want 1", u, stability)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: on a ring of cliques, the runs disagree, but the scores stay
+	// within [0, 1] and are reproducible from the seed
+	qm = NewModularity(1.0, ringOfCliques(t, 20, 4))
+	opts := ClusteringOptions{MaxIters: 100, Seed: 11}
+	wantStabilities, wantReference := MembershipStability(qm, 20, opts)
+	isUnstable := false
+	for u, stability := range wantStabilities {
+		if stability < 0.0 || stability > 1.0 {
+			t.Fatalf("stability of %d = %v", u, stability)
+		}
+		isUnstable = isUnstable || stability < 1.0
+	}
+	if !isUnstable {
+		t.Fatal("all nodes are stable, although the runs pair the cliques differently")
+	}
+	for call := 0; call < 10; call++ {
+		stabilities, reference := MembershipStability(qm, 20, opts)
+		if !reflect.DeepEqual(stabilities, wantStabilities) ||
+			!reflect.DeepEqual(reference, wantReference) {
+			t.Fatalf("call %d: stabilities = %v, reference = %v, want %v, %v", call,
+				stabilities, reference, wantStabilities, wantReference)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package ConcurrenceBasedClustering
+
+// =============================================================================
+// interface deltaQualityCacher
+// brief description: This is an optional interface of quality models whose
+//	DeltaQuality can be sped up by bookkeeping per community. Louvain uses it
+//	for the quality models of this package, see getDeltaQualityFunc, and
+//	DeltaQuality otherwise.
+type deltaQualityCacher interface {
+	// getCachedDeltaQuality builds the bookkeeping for communities and returns
+	// a function equal to DeltaQuality(communities, u, oldCu, newCu), with u in
+	// communities[oldCu]. It is only valid while communities do not change,
+	// and safe to call concurrently.
+	getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64
+}
+
+// =============================================================================
+// func getDeltaQualityFunc
+// brief description: get the DeltaQuality function of a quality model for a
+//	sweep of moves over fixed communities.
+// input:
+//	qm: a quality model.
+//	communities: the communities of the sweep. They must not change while the
+//		output is used.
+// output:
+//	the cached DeltaQuality of qm if it is one of the quality models of this
+//	package implementing deltaQualityCacher, or a call to qm.DeltaQuality
+//	otherwise.
+// note:
+//	The concrete types are checked instead of asserting deltaQualityCacher,
+//	since a type embedding Modularity, for instance, also gets the promoted
+//	getCachedDeltaQuality, which would silently replace the DeltaQuality it
+//	overrides.
+func getDeltaQualityFunc(qm QualityModel, communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	switch qm.(type) {
+	case Modularity, CPM, WeightedCPM, InfomapQuality, DirectedModularity, Surprise, Significance:
+		return qm.(deltaQualityCacher).getCachedDeltaQuality(communities)
+	}
+	return func(u, oldCu, newCu int) float64 {
+		return qm.DeltaQuality(communities, u, oldCu, newCu)
+	}
+}
+
+// =============================================================================
+// struct communityTotals
+// brief description: This is the bookkeeping of a sweep of moves: the
+//	community of each node and the total of a per-node value in each
+//	community, e.g. degrees or cardinalities.
+type communityTotals struct {
+	communityIDs []int
+	totals       []float64
+}
+
+// =============================================================================
+// func newCommunityTotals
+// brief description: create the bookkeeping of communities
+// input:
+//	n: the number of nodes
+//	communities: a list of clusters. They must not overlap.
+//	valueOf: the value of each node
+// output:
+//	the bookkeeping. Nodes not in any community have community ID -1.
+func newCommunityTotals(n int, communities []map[int]bool, valueOf func(u int) float64,
+) communityTotals {
+	result := communityTotals{
+		communityIDs: make([]int, n),
+		totals:       make([]float64, len(communities)),
+	}
+	for u := 0; u < n; u++ {
+		result.communityIDs[u] = -1
+	}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			result.communityIDs[u] = idxC
+			result.totals[idxC] += valueOf(u)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getWeightsToCommunities
+// brief description: get the concurrences from a node to two communities,
+//	weighted by the cardinalities of both ends, in O(degree of u).
+// input:
+//	u: a node ID
+//	communityIDs: the community ID of each node
+//	oldCu, newCu: the two communities
+// output:
+//	output 1: the weight from u to the other nodes of oldCu
+//	output 2: the weight from u to newCu
+func (cm ConcurrenceModel) getWeightsToCommunities(u int, communityIDs []int, oldCu, newCu int,
+) (float64, float64) {
+	weightToOld := 0.0
+	weightToNew := 0.0
+	for j, weightUJ := range cm.concurrences[u] {
+		if j == u {
+			continue
+		}
+		switch communityIDs[j] {
+		case oldCu:
+			weightToOld += weightUJ * float64(cm.cardinalities[u]*cm.cardinalities[j])
+		case newCu:
+			weightToNew += weightUJ * float64(cm.cardinalities[u]*cm.cardinalities[j])
+		}
+	}
+	return weightToOld, weightToNew
+}
+
+// =============================================================================
+// func (qm Modularity) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The total degree of
+//	each community replaces the scans over the old and the new communities of
+//	DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm Modularity) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return qm.sumConcurrencesOf[u]
+	})
+	oneOverM := 1.0 / qm.sumConcurrences
+	rOverM := qm.r * oneOverM
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		// the same as DeltaQuality, with sum_{j in c} k_j = totals[c]
+		ku := qm.sumConcurrencesOf[u]
+		weightToOld, weightToNew := qm.getWeightsToCommunities(u, bookkeeping.communityIDs,
+			oldCu, newCu)
+		result := (weightToNew - rOverM*ku*bookkeeping.totals[newCu]) -
+			(weightToOld - rOverM*ku*(bookkeeping.totals[oldCu]-ku))
+		return result * 2.0 * oneOverM
+	}
+}
+
+// =============================================================================
+// func (qm CPM) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The total cardinality
+//	of each community replaces the scans over the old and the new communities
+//	of DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm CPM) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return float64(qm.cardinalities[u])
+	})
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		// the same as DeltaQuality, with size_c = totals[c]
+		cardU := qm.cardinalities[u]
+		weightToOld, weightToNew := qm.getWeightsToCommunities(u, bookkeeping.communityIDs,
+			oldCu, newCu)
+		sizeOldCu := int(bookkeeping.totals[oldCu])
+		sizeNewCu := int(bookkeeping.totals[newCu])
+		return 2.0*(weightToNew-weightToOld) -
+			2.0*qm.r*float64(cardU*(sizeNewCu-sizeOldCu+cardU))
+	}
+}
+
+// =============================================================================
+// func (qm WeightedCPM) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The total degree of
+//	each community replaces the scans over the old and the new communities of
+//	DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm WeightedCPM) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return qm.sumConcurrencesOf[u]
+	})
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		// the same as DeltaQuality, with vol_c = totals[c]
+		ku := qm.sumConcurrencesOf[u]
+		weightToOld, weightToNew := qm.getWeightsToCommunities(u, bookkeeping.communityIDs,
+			oldCu, newCu)
+		return 2.0*(weightToNew-weightToOld) -
+			2.0*qm.r*ku*(bookkeeping.totals[newCu]-bookkeeping.totals[oldCu]+ku)
+	}
+}
+
+// =============================================================================
+// func (qm InfomapQuality) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The cut and the
+//	volume of each community and the total cut replace the scan over all
+//	modules of DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm InfomapQuality) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return qm.sumConcurrencesOf[u]
+	})
+	cuts, _, totalCut := qm.getModuleFlows(communities)
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu || qm.sumConcurrences <= 0.0 {
+			return 0.0
+		}
+		// the same as DeltaQuality, with vol_c = totals[c]
+		weightToOld, weightToNew := qm.getWeightsToCommunities(u, bookkeeping.communityIDs,
+			oldCu, newCu)
+		return -qm.getDeltaMapEquation(qm.sumConcurrencesOf[u], qm.getExternalWeight(u),
+			weightToOld, weightToNew, cuts[oldCu], bookkeeping.totals[oldCu], cuts[newCu],
+			bookkeeping.totals[newCu], totalCut)
+	}
+}
+
+// =============================================================================
+// func (qm DirectedModularity) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The total out-degree
+//	and in-degree of each community replace the scans over the old and the new
+//	communities of DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm DirectedModularity) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	outTotals := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return qm.sumConcurrencesOf[u]
+	})
+	inTotals := newCommunityTotals(qm.n, communities, func(u int) float64 {
+		return qm.inDegrees[u]
+	})
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		// the same as DeltaQuality, with the neighbors in both directions
+		weightToOld := 0.0
+		weightToNew := 0.0
+		for j, weightUJ := range qm.neighbors[u] {
+			if j == u {
+				continue
+			}
+			switch outTotals.communityIDs[j] {
+			case oldCu:
+				weightToOld += weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+			case newCu:
+				weightToNew += weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+			}
+		}
+		return qm.getDeltaQuality(u, weightToOld, weightToNew, outTotals.totals[oldCu],
+			inTotals.totals[oldCu], outTotals.totals[newCu], inTotals.totals[newCu])
+	}
+}
+
+// =============================================================================
+// struct communityWeights
+// brief description: This is the bookkeeping of the quality models that depend
+//	on the weight inside each community: the community of each node, the size
+//	of each community as its totals, and the weight inside each community.
+type communityWeights struct {
+	communityTotals
+	weights []float64
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) newCommunityWeights
+// brief description: create the bookkeeping of communities, which costs a scan
+//	of the concurrences of their members
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the bookkeeping
+func (cm ConcurrenceModel) newCommunityWeights(communities []map[int]bool) communityWeights {
+	result := communityWeights{
+		communityTotals: newCommunityTotals(cm.n, communities, func(u int) float64 {
+			return float64(cm.cardinalities[u])
+		}),
+		weights: make([]float64, len(communities)),
+	}
+	for idxC, c := range communities {
+		result.weights[idxC], _ = cm.getCommunityWeightAndSize(c)
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getCachedMove
+// brief description: the same as getMove, with the weights and sizes of the
+//	communities taken from the bookkeeping, so a move is evaluated in
+//	O(degree of u).
+// input:
+//	bookkeeping: the bookkeeping of the communities.
+//	u: a node ID in communities[oldCu].
+//	oldCu, newCu: the IDs of the old and new communities of u.
+// output:
+//	the same as getMove
+func (cm ConcurrenceModel) getCachedMove(bookkeeping communityWeights, u, oldCu, newCu int) (
+	[2]float64, [2]int, [2]float64, [2]int) {
+	weightToOld, weightToNew := cm.getWeightsToCommunities(u, bookkeeping.communityIDs,
+		oldCu, newCu)
+	selfWeightU := cm.getSelfWeight(u)
+	cardU := cm.cardinalities[u]
+	oldWeight := bookkeeping.weights[oldCu]
+	newWeight := bookkeeping.weights[newCu]
+	oldSize := int(bookkeeping.totals[oldCu])
+	newSize := int(bookkeeping.totals[newCu])
+	return [2]float64{oldWeight, oldWeight - selfWeightU - weightToOld},
+		[2]int{oldSize, oldSize - cardU},
+		[2]float64{newWeight, newWeight + selfWeightU + weightToNew},
+		[2]int{newSize, newSize + cardU}
+}
+
+// =============================================================================
+// func (qm Surprise) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The totals of the
+//	partition and the weight and size of each community replace the scan of
+//	all the concurrences of DeltaQuality, so a move is evaluated in
+//	O(degree of u).
+func (qm Surprise) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := qm.newCommunityWeights(communities)
+	internalWeight := 0.0
+	internalPairs := 0.0
+	for idxC, _ := range communities {
+		internalWeight += bookkeeping.weights[idxC]
+		internalPairs += numPairs(int(bookkeeping.totals[idxC]))
+	}
+	totalPairs := numPairs(qm.getTotalCardinality())
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		oldWeights, oldSizes, newWeights, newSizes := qm.getCachedMove(bookkeeping, u, oldCu, newCu)
+		return qm.getDeltaSurprise(internalWeight, internalPairs, oldWeights, oldSizes,
+			newWeights, newSizes, totalPairs)
+	}
+}
+
+// =============================================================================
+// func (qm Significance) getCachedDeltaQuality
+// brief description: this implements deltaQualityCacher. The weight and size
+//	of each community replace the scans over the old and the new communities
+//	of DeltaQuality, so a move is evaluated in O(degree of u).
+func (qm Significance) getCachedDeltaQuality(communities []map[int]bool) func(u, oldCu, newCu int) float64 {
+	bookkeeping := qm.newCommunityWeights(communities)
+	totalPairs := numPairs(qm.getTotalCardinality())
+	return func(u, oldCu, newCu int) float64 {
+		if oldCu == newCu {
+			return 0.0
+		}
+		oldWeights, oldSizes, newWeights, newSizes := qm.getCachedMove(bookkeeping, u, oldCu, newCu)
+		return qm.getDeltaSignificance(oldWeights, oldSizes, newWeights, newSizes, totalPairs)
+	}
+}
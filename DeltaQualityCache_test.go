@@ -0,0 +1,86 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCachedDeltaQualityMatchesDeltaQuality(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	karate, _ := karateClub(t)
+	for _, cm := range []ConcurrenceModel{karate, randomWeights(t, karate, rng)} {
+		for name, qm := range map[string]QualityModel{
+			"modularity":          NewModularity(0.5, cm),
+			"standard modularity": NewModularityStandard(1.0, cm),
+			"cpm":                 NewCPM(0.1, cm),
+			"weighted cpm":        NewWeightedCPM(0.1, cm),
+			"infomap":             NewInfomapQuality(cm),
+		} {
+			t.Run(name, func(t *testing.T) {
+				checkCachedDeltaQuality(t, qm, 20, rng)
+			})
+		}
+	}
+}
+
+func BenchmarkDeltaQualityCache(b *testing.B) {
+	// a graph of 50k nodes in 500 communities, whose sweeps evaluate the moves
+	// of each node into the communities of its neighbors
+	const n = 50000
+	rng := rand.New(rand.NewSource(1))
+	cm := randomGraph(b, n, 5*n, rng)
+	communities := randomCommunities(rng, n, 500)
+	communityIDs, err := CommunitiesToLabels(communities, n)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for name, qm := range map[string]QualityModel{
+		"modularity": NewModularity(1.0, cm),
+		"cpm":        NewCPM(0.01, cm),
+	} {
+		for _, cached := range []bool{false, true} {
+			subName := name + "/uncached"
+			if cached {
+				subName = name + "/cached"
+			}
+			b.Run(subName, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					// the bookkeeping is built once per sweep
+					deltaQuality := func(u, oldCu, newCu int) float64 {
+						return qm.DeltaQuality(communities, u, oldCu, newCu)
+					}
+					if cached {
+						deltaQuality = getDeltaQualityFunc(qm, communities)
+					}
+					for u := 0; u < n; u += 100 {
+						for v, _ := range cm.concurrences[u] {
+							deltaQuality(u, communityIDs[u], communityIDs[v])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// =============================================================================
+// struct rejectingModularity
+// brief introduction: a quality model embedding Modularity whose DeltaQuality
+//	rejects every move, so Louvain must never move a node
+type rejectingModularity struct {
+	Modularity
+}
+
+func (qm rejectingModularity) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	return -1.0
+}
+
+func TestCachedDeltaQualityRespectsOverriddenDeltaQuality(t *testing.T) {
+	qm := rejectingModularity{NewModularity(1.0, twoTriangles(t))}
+	communities, _ := Louvain(qm, nil, nil, 100)
+	numCommunities := len(Partition(communities).Canonicalize())
+	if numCommunities != qm.GetN() {
+		t.Fatalf("%d communities, want the %d single nodes: %v", numCommunities, qm.GetN(),
+			communities)
+	}
+}
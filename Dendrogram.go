@@ -0,0 +1,517 @@
+package ConcurrenceBasedClustering
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// struct clusterPair
+// brief description: This is a candidate merge of two clusters at a distance.
+type clusterPair struct {
+	distance float64
+	a, b     int
+}
+
+// =============================================================================
+// type clusterPairHeap
+// brief description: This is a min-heap of clusterPairs that implements
+//	heap.Interface. Ties are broken by smaller cluster IDs. It is used lazily:
+//	pairs whose distances have changed are left in the heap and skipped when
+//	popped.
+type clusterPairHeap []clusterPair
+
+func (h clusterPairHeap) Len() int {
+	return len(h)
+}
+
+func (h clusterPairHeap) Less(i, j int) bool {
+	if h[i].distance != h[j].distance {
+		return h[i].distance < h[j].distance
+	}
+	if h[i].a != h[j].a {
+		return h[i].a < h[j].a
+	}
+	return h[i].b < h[j].b
+}
+
+func (h clusterPairHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *clusterPairHeap) Push(x interface{}) {
+	*h = append(*h, x.(clusterPair))
+}
+
+func (h *clusterPairHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// =============================================================================
+// struct DendrogramMerge
+// brief description: This is a merge of a dendrogram.
+type DendrogramMerge struct {
+	// the IDs of the two merged clusters. IDs 0..N-1 are the points, and ID
+	// N+i is the cluster created by the i-th merge.
+	Left, Right int
+
+	// the distance at which the two clusters are merged
+	Distance float64
+
+	// the size of the merged cluster, i.e. the sum of the cardinalities of
+	// its points
+	Size int
+}
+
+// =============================================================================
+// struct Dendrogram
+// brief description: This is the merge tree of an agglomerative clustering,
+//	from which flat clusterings can be cut without redoing the agglomeration.
+type Dendrogram struct {
+	// the number of points
+	N int
+
+	// the merges in the order they happened. When the points are not all
+	// connected, there are less than N-1 merges.
+	Merges []DendrogramMerge
+}
+
+// =============================================================================
+// func newDendrogramFromLinkage
+// brief description: convert a single linkage tree into a Dendrogram
+// input:
+//	n: the number of points
+//	nodes: the nodes of the tree, see buildSingleLinkageTree
+// output:
+//	the Dendrogram
+func newDendrogramFromLinkage(n int, nodes []linkageNode) *Dendrogram {
+	dendrogram := &Dendrogram{N: n, Merges: make([]DendrogramMerge, 0, len(nodes)-n)}
+	for _, node := range nodes[n:] {
+		dendrogram.Merges = append(dendrogram.Merges, DendrogramMerge{
+			Left:     node.left,
+			Right:    node.right,
+			Distance: node.distance,
+			Size:     node.size,
+		})
+	}
+	return dendrogram
+}
+
+// =============================================================================
+// func (d *Dendrogram) cut
+// brief description: replay the first merges of the dendrogram
+// input:
+//	numMerges: the number of merges to replay
+// output:
+//	the clusters after the merges, ordered by their smallest members
+func (d *Dendrogram) cut(numMerges int) []map[int]bool {
+	representatives := make([]int, d.N+len(d.Merges))
+	for pt := 0; pt < d.N; pt++ {
+		representatives[pt] = pt
+	}
+	uf := newUnionFind(d.N)
+	for i, merge := range d.Merges[:numMerges] {
+		representatives[d.N+i] = representatives[merge.Left]
+		uf.union(representatives[merge.Left], representatives[merge.Right])
+	}
+	communities, _ := uf.sets()
+	return communities
+}
+
+// =============================================================================
+// func (d *Dendrogram) CutAt
+// brief description: cut the dendrogram at a distance
+// input:
+//	eps: the distance. Only the merges at distance <= eps are kept.
+// output:
+//	the clusters, ordered by their smallest members
+// note:
+//	The merges are replayed in order until the first one above eps, so for
+//	dendrograms whose distances are not monotone, e.g. of centroid linkage, a
+//	merge at distance <= eps after a merge above eps is not kept.
+func (d *Dendrogram) CutAt(eps float64) []map[int]bool {
+	numMerges := 0
+	for numMerges < len(d.Merges) && d.Merges[numMerges].Distance <= eps {
+		numMerges++
+	}
+	return d.cut(numMerges)
+}
+
+// =============================================================================
+// func (d *Dendrogram) CutK
+// brief description: cut the dendrogram into a number of clusters
+// input:
+//	k: the number of clusters, must be > 0.
+// output:
+//	output 1: the clusters, ordered by their smallest members. There are more
+//		than k clusters if the dendrogram has more than k roots, and
+//		singletons if k >= N.
+//	output 2: an error if k <= 0, nil otherwise
+func (d *Dendrogram) CutK(k int) ([]map[int]bool, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k = %d must be > 0", k)
+	}
+	numMerges := d.N - k
+	if numMerges < 0 {
+		numMerges = 0
+	}
+	if numMerges > len(d.Merges) {
+		numMerges = len(d.Merges)
+	}
+	return d.cut(numMerges), nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getSimilarityEdges
+// brief description: check a similarity matrix and convert it into edges with
+//	distances 1 - similarity.
+// input:
+//	simMat: the similarity matrix. If it is nil, the concurrences are used.
+// output:
+//	output 1: the edges, each pair of nodes once
+//	output 2: an error if simMat does not have n rows, or has a node out of
+//		range or a similarity out of [0, 1], nil otherwise
+func (cm ConcurrenceModel) getSimilarityEdges(simMat []map[int]float64) ([]linkageEdge, error) {
+	if simMat == nil {
+		simMat = cm.concurrences
+	}
+	if len(simMat) != cm.n {
+		return nil, fmt.Errorf("simMat has %d rows, but there are %d nodes", len(simMat), cm.n)
+	}
+	edges := []linkageEdge{}
+	for u := 0; u < cm.n; u++ {
+		for v, simUV := range simMat[u] {
+			if v < 0 || v >= cm.n {
+				return nil, fmt.Errorf("simMat[%d] has node %d out of range [0, %d)", u, v, cm.n)
+			}
+			if simUV < 0.0 || simUV > 1.0 {
+				return nil, fmt.Errorf("simMat[%d][%d] = %v is not within [0, 1]", u, v, simUV)
+			}
+			if u < v {
+				edges = append(edges, linkageEdge{u: u, v: v, distance: 1.0 - simUV})
+			}
+		}
+	}
+	return edges, nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHCDendrogram
+// brief description: agglomerative hierarchical clustering with single
+//	linkage, where the distance between two points is 1 - their similarity.
+// input:
+//	simMat: the similarity matrix, e.g. cm.InduceCosineSimilarities(). It must
+//		have n rows and all elements 0~1. If it is nil, the concurrences are
+//		used as similarities, as DBScan does.
+// output:
+//	output 1: the dendrogram. Pairs without similarity are never merged
+//		directly, so each connected component of the similarity graph is a
+//		separate tree.
+//	output 2: an error if simMat is invalid, nil otherwise
+func (cm ConcurrenceModel) AHCDendrogram(simMat []map[int]float64) (*Dendrogram, error) {
+	edges, err := cm.getSimilarityEdges(simMat)
+	if err != nil {
+		return nil, err
+	}
+	nodes, _ := buildSingleLinkageTree(cm.cardinalities, edges)
+	return newDendrogramFromLinkage(cm.n, nodes), nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHC
+// brief description: single linkage agglomerative hierarchical clustering on
+//	the concurrences, cut at a distance. See AHCDendrogram.
+// input:
+//	eps: the radius of neighborhood, as in DBScan. Two points are linked if
+//		their concurrence is at least 1 - eps.
+// output:
+//	the clusters, ordered by their smallest members
+func (cm ConcurrenceModel) AHC(eps float64) []map[int]bool {
+	dendrogram, err := cm.AHCDendrogram(nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return dendrogram.CutAt(eps)
+}
+
+// =============================================================================
+// type Linkage
+// brief description: This is the way AHC measures the distance between two
+//	clusters from the distances between their points.
+type Linkage int
+
+const (
+	// the smallest distance between their points
+	SingleLinkage Linkage = iota
+
+	// the largest distance between their points
+	CompleteLinkage
+
+	// the mean distance between their points, i.e. UPGMA
+	AverageLinkage
+
+	// the increase of the within-cluster variance when merging them, taking
+	// the distances as squared Euclidean distances
+	WardLinkage
+)
+
+// =============================================================================
+// func getLanceWilliamsDistance
+// brief description: the Lance-Williams update of the distance from a cluster
+//	D to the merge of clusters C1 and C2.
+// input:
+//	linkage: the linkage
+//	d1, d2: the distances from D to C1 and C2
+//	d12: the distance between C1 and C2
+//	size1, size2, sizeD: the sizes of C1, C2 and D
+// output:
+//	the distance from D to the merge of C1 and C2
+func getLanceWilliamsDistance(linkage Linkage, d1, d2, d12 float64, size1, size2, sizeD int,
+) float64 {
+	switch linkage {
+	case SingleLinkage:
+		return math.Min(d1, d2)
+	case CompleteLinkage:
+		return math.Max(d1, d2)
+	case AverageLinkage:
+		return (float64(size1)*d1 + float64(size2)*d2) / float64(size1+size2)
+	case WardLinkage:
+		return (float64(size1+sizeD)*d1 + float64(size2+sizeD)*d2 - float64(sizeD)*d12) /
+			float64(size1+size2+sizeD)
+	}
+	log.Fatalln(fmt.Sprintf("unknown linkage %d", linkage))
+	return 0.0
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHCDendrogramWithLinkage
+// brief description: agglomerative hierarchical clustering with a linkage,
+//	where the distance between two points is 1 - their similarity. The
+//	distances between clusters are updated by the Lance-Williams formula, and
+//	the closest pair is found with a lazy heap.
+// input:
+//	simMat: the similarity matrix. See AHCDendrogram.
+//	linkage: the linkage. SingleLinkage gives the same as AHCDendrogram.
+// output:
+//	output 1: the dendrogram. See the note for pairs without similarity.
+//	output 2: an error if simMat is invalid, nil otherwise
+// note:
+//	Pairs without similarity are at distance 1. With single, complete and
+//	average linkage, the merges happen in order of increasing distance, so the
+//	merges at distance 1 only come last, and they are left out: as with
+//	AHCDendrogram, the clusters at distance 1 remain separate trees, and only
+//	the pairs with similarities are stored, i.e. the memory is O(number of
+//	similarities). Ward's distances are not bounded by 1, so WardLinkage
+//	stores the distances of all pairs, taking O(n^2) memory and time
+//	O(n^2 log(n)), and always builds a single tree.
+//	The sizes of clusters are sums of cardinalities.
+func (cm ConcurrenceModel) AHCDendrogramWithLinkage(simMat []map[int]float64, linkage Linkage,
+) (*Dendrogram, error) {
+	return cm.AHCDendrogramWithOptions(simMat, linkage, ClusteringOptions{})
+}
+
+// =============================================================================
+// const ahcProgressInterval
+// brief description: the number of merges between two calls of Progress in
+//	AHCDendrogramWithOptions
+const ahcProgressInterval = 1000
+
+// =============================================================================
+// func reportMerge
+// brief description: call opts.Progress for a merge of AHC if it is due, i.e.
+//	every ahcProgressInterval merges and after the last merge.
+// input:
+//	opts: the options. Nothing is done if opts.Progress is nil.
+//	merges: the merges so far.
+//	isLast: whether this is the last merge.
+func reportMerge(opts ClusteringOptions, merges []DendrogramMerge, isLast bool) {
+	numMerges := len(merges)
+	if opts.Progress == nil || numMerges == 0 || (numMerges%ahcProgressInterval != 0 && !isLast) {
+		return
+	}
+	merge := merges[numMerges-1]
+	opts.Progress("ahc", numMerges, merge.Distance, merge.Size)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHCDendrogramWithOptions
+// brief description: AHCDendrogramWithLinkage with options. Only opts.Progress
+//	is used: it is called with stage "ahc" every ahcProgressInterval merges and
+//	after the last merge, with the number of merges so far, the distance of
+//	the latest merge and the size of the cluster it made.
+// input:
+//	simMat: the similarity matrix. See AHCDendrogram.
+//	linkage: the linkage. See AHCDendrogramWithLinkage.
+//	opts: the options.
+// output:
+//	the same as AHCDendrogramWithLinkage
+// note:
+//	SingleLinkage finds all the merges at once after sorting the similarities,
+//	so its merges are only reported after they are all found.
+func (cm ConcurrenceModel) AHCDendrogramWithOptions(simMat []map[int]float64, linkage Linkage,
+	opts ClusteringOptions) (*Dendrogram, error) {
+	// -------------------------------------------------------------------------
+	// step 1: collect the distances between points
+	if linkage == SingleLinkage {
+		dendrogram, err := cm.AHCDendrogram(simMat)
+		if err != nil || opts.Progress == nil {
+			return dendrogram, err
+		}
+		merges := dendrogram.Merges
+		for idxMerge, _ := range merges {
+			reportMerge(opts, merges[:idxMerge+1], idxMerge == len(merges)-1)
+		}
+		return dendrogram, nil
+	}
+	edges, err := cm.getSimilarityEdges(simMat)
+	if err != nil {
+		return nil, err
+	}
+	n := cm.n
+	dense := linkage == WardLinkage
+	distances := make([]map[int]float64, n, 2*n)
+	sizes := make([]int, n, 2*n)
+	for u := 0; u < n; u++ {
+		distances[u] = map[int]float64{}
+		sizes[u] = cm.cardinalities[u]
+		if dense {
+			for v := 0; v < n; v++ {
+				if v != u {
+					distances[u][v] = 1.0
+				}
+			}
+		}
+	}
+	for _, edge := range edges {
+		if dense || edge.distance < 1.0 {
+			distances[edge.u][edge.v] = edge.distance
+			distances[edge.v][edge.u] = edge.distance
+		}
+	}
+	h := &clusterPairHeap{}
+	for u := 0; u < n; u++ {
+		for v, distance := range distances[u] {
+			if u < v {
+				*h = append(*h, clusterPair{distance: distance, a: u, b: v})
+			}
+		}
+	}
+	heap.Init(h)
+
+	// -------------------------------------------------------------------------
+	// step 2: merge the closest pair until none is left
+	dendrogram := &Dendrogram{N: n, Merges: []DendrogramMerge{}}
+	for h.Len() > 0 {
+		// (2.1) pop the closest pair, skipping stale ones
+		pair := heap.Pop(h).(clusterPair)
+		if distances[pair.a] == nil || distances[pair.b] == nil {
+			continue
+		}
+		current, exists := distances[pair.a][pair.b]
+		if !exists || current != pair.distance {
+			continue
+		}
+
+		// (2.2) record the merge
+		idxC3 := len(distances)
+		size := sizes[pair.a] + sizes[pair.b]
+		dendrogram.Merges = append(dendrogram.Merges, DendrogramMerge{
+			Left:     pair.a,
+			Right:    pair.b,
+			Distance: pair.distance,
+			Size:     size,
+		})
+
+		// (2.3) update the distances to the merged cluster. Missing distances
+		// are 1.
+		distancesOfC3 := map[int]float64{}
+		for _, idxC := range []int{pair.a, pair.b} {
+			for idxD, _ := range distances[idxC] {
+				if idxD == pair.a || idxD == pair.b {
+					continue
+				}
+				_, done := distancesOfC3[idxD]
+				if done {
+					continue
+				}
+				d1, exists1 := distances[pair.a][idxD]
+				if !exists1 {
+					d1 = 1.0
+				}
+				d2, exists2 := distances[pair.b][idxD]
+				if !exists2 {
+					d2 = 1.0
+				}
+				distance := getLanceWilliamsDistance(linkage, d1, d2, pair.distance,
+					sizes[pair.a], sizes[pair.b], sizes[idxD])
+				distancesOfC3[idxD] = distance
+			}
+		}
+		for idxD, distance := range distancesOfC3 {
+			delete(distances[idxD], pair.a)
+			delete(distances[idxD], pair.b)
+			if !dense && distance >= 1.0 {
+				delete(distancesOfC3, idxD)
+				continue
+			}
+			distances[idxD][idxC3] = distance
+			heap.Push(h, clusterPair{distance: distance, a: idxD, b: idxC3})
+		}
+		distances = append(distances, distancesOfC3)
+		sizes = append(sizes, size)
+		distances[pair.a] = nil
+		distances[pair.b] = nil
+		reportMerge(opts, dendrogram.Merges, false)
+	}
+	if len(dendrogram.Merges)%ahcProgressInterval != 0 {
+		reportMerge(opts, dendrogram.Merges, true)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return dendrogram, nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHCWithLinkage
+// brief description: agglomerative hierarchical clustering on the
+//	concurrences with a linkage, cut at a distance. See AHC and
+//	AHCDendrogramWithLinkage.
+func (cm ConcurrenceModel) AHCWithLinkage(eps float64, linkage Linkage) []map[int]bool {
+	dendrogram, err := cm.AHCDendrogramWithLinkage(nil, linkage)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return dendrogram.CutAt(eps)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) AHCK
+// brief description: agglomerative hierarchical clustering on the
+//	concurrences, stopped when a number of clusters remain. See
+//	AHCDendrogramWithLinkage and Dendrogram.CutK.
+// input:
+//	k: the number of clusters, must be > 0.
+//	linkage: the linkage, SingleLinkage as AHC does.
+// output:
+//	output 1: the clusters, ordered by their smallest members. Clusters at
+//		distance 1, i.e. without any similarity between them, are never merged
+//		except by WardLinkage, so there are more than k clusters if the
+//		concurrence graph has more than k connected components.
+//	output 2: an error if k <= 0 or the concurrences are not within [0, 1],
+//		nil otherwise
+func (cm ConcurrenceModel) AHCK(k int, linkage Linkage) ([]map[int]bool, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k = %d must be > 0", k)
+	}
+	dendrogram, err := cm.AHCDendrogramWithLinkage(nil, linkage)
+	if err != nil {
+		return nil, err
+	}
+	return dendrogram.CutK(k)
+}
@@ -0,0 +1,192 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAHCProgressReportsMerges(t *testing.T) {
+	// a connected random graph of 1500 nodes, so that there are 1499 merges
+	rng := rand.New(rand.NewSource(1))
+	edges := []Edge{}
+	for u := 1; u < 1500; u++ {
+		edges = append(edges, Edge{rng.Intn(u), u, 0.5 + 0.5*rng.Float64()})
+	}
+	cm := newTestModel(t, edges)
+	for _, linkage := range []Linkage{SingleLinkage, AverageLinkage} {
+		reported := []int{}
+		dendrogram, err := cm.AHCDendrogramWithOptions(nil, linkage, ClusteringOptions{
+			Progress: func(stage string, sweep int, quality float64, moved int) {
+				if stage != "ahc" {
+					t.Fatalf("stage = %q, want ahc", stage)
+				}
+				reported = append(reported, sweep)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		numMerges := len(dendrogram.Merges)
+		if numMerges != 1499 || len(reported) != 2 || reported[0] != 1000 ||
+			reported[1] != numMerges {
+			t.Fatalf("linkage %d: %d merges, reported %v", linkage, numMerges, reported)
+		}
+	}
+}
+
+func TestAHCKRejectsNonPositiveK(t *testing.T) {
+	// two triangles joined by a weaker edge
+	cm := newTestModel(t, append(append(cliqueEdges(0, 3, 0.9), cliqueEdges(3, 3, 0.8)...),
+		Edge{2, 3, 0.2}))
+	for _, k := range []int{0, -1} {
+		if _, err := cm.AHCK(k, SingleLinkage); err == nil {
+			t.Fatalf("AHCK accepts k = %d", k)
+		}
+	}
+	dendrogram, err := cm.AHCDendrogramWithLinkage(nil, SingleLinkage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dendrogram.CutK(0); err == nil {
+		t.Fatal("CutK accepts k = 0")
+	}
+
+	// k >= n gives singletons
+	communities, err := cm.AHCK(cm.n+1, SingleLinkage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, []map[int]bool{
+		{0: true}, {1: true}, {2: true}, {3: true}, {4: true}, {5: true}})
+	communities, err = cm.AHCK(2, SingleLinkage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, []map[int]bool{
+		{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}})
+}
+
+func TestCutAtMatchesConnectedComponents(t *testing.T) {
+	// single linkage cut at eps links the pairs with similarity >= 1 - eps,
+	// so it gives the connected components at eps, as the old AHC did
+	rng := rand.New(rand.NewSource(1))
+	cm := randomWeights(t, ringOfCliques(t, 10, 5), rng)
+	dendrogram, err := cm.AHCDendrogram(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the merges build up the sizes of the clusters, and the distances of
+	// single linkage are monotone
+	sizes := append([]int(nil), cm.cardinalities...)
+	for i, merge := range dendrogram.Merges {
+		sizes = append(sizes, sizes[merge.Left]+sizes[merge.Right])
+		if merge.Size != sizes[cm.n+i] || (i > 0 && merge.Distance < dendrogram.Merges[i-1].Distance) {
+			t.Fatalf("merge %d = %+v, want size %d and a distance not below the "+
+				"previous merge", i, merge, sizes[cm.n+i])
+		}
+	}
+	if len(dendrogram.Merges) != cm.n-1 {
+		t.Fatalf("%d merges of a connected graph of %d nodes", len(dendrogram.Merges), cm.n)
+	}
+
+	for _, eps := range []float64{0.0, 0.1, 0.3, 0.5, 0.7, 0.9, 1.0} {
+		want := cm.ConnectedComponents(eps)
+		assertSamePartition(t, dendrogram.CutAt(eps), want)
+		assertSamePartition(t, cm.AHC(eps), want)
+		communities, _ := cm.DBScan(eps, 1)
+		assertSamePartition(t, communities, want)
+	}
+}
+
+func TestLinkagesGiveDifferentClusters(t *testing.T) {
+	// points on a line, with the similarity 1 - their distance and distinct
+	// distances, so that no merge is tied. The gaps between neighbors are
+	// 0.31, 0.20, 0.13, 0.08, 0.24 and 0.03: single linkage chains the gaps
+	// below 0.31, complete linkage stops at the diameters, average linkage
+	// pulls 2 to 3 and 4, and Ward balances the variances.
+	xs := []float64{0, 0.31, 0.51, 0.64, 0.72, 0.96, 0.99}
+	n := len(xs)
+	simMat := make([]map[int]float64, n)
+	for u := 0; u < n; u++ {
+		simMat[u] = map[int]float64{}
+		for v := 0; v < n; v++ {
+			if v != u {
+				simMat[u][v] = 1.0 - math.Abs(xs[u]-xs[v])
+			}
+		}
+	}
+	cm, err := newConcurrenceModelFromEdges(n, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for linkage, want := range map[Linkage][]map[int]bool{
+		SingleLinkage:   {{0: true}, {1: true, 2: true, 3: true, 4: true, 5: true, 6: true}},
+		CompleteLinkage: {{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true, 6: true}},
+		AverageLinkage:  {{0: true, 1: true}, {2: true, 3: true, 4: true, 5: true, 6: true}},
+		WardLinkage:     {{0: true, 1: true, 2: true, 3: true, 4: true}, {5: true, 6: true}},
+	} {
+		dendrogram, err := cm.AHCDendrogramWithLinkage(simMat, linkage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := dendrogram.CutK(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("linkage %d: clusters = %v, want %v", linkage, got, want)
+		}
+	}
+
+	// single linkage is the default of AHC and AHCDendrogram
+	single, err := cm.AHCDendrogram(simMat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withLinkage, err := cm.AHCDendrogramWithLinkage(simMat, SingleLinkage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(single.CutAt(0.25), withLinkage.CutAt(0.25)) {
+		t.Fatalf("AHCDendrogram cuts into %v, single linkage into %v", single.CutAt(0.25),
+			withLinkage.CutAt(0.25))
+	}
+}
+
+func TestAHCKFindsThreeGroups(t *testing.T) {
+	// three cliques of 5 nodes, each joined to the next by a weak edge
+	edges := []Edge{}
+	for c := 0; c < 3; c++ {
+		edges = append(edges, cliqueEdges(5*c, 5, 0.8+0.05*float64(c))...)
+		edges = append(edges, Edge{5 * c, (5*c + 7) % 15, 0.1})
+	}
+	cm := newTestModel(t, edges)
+	groups := []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true, 4: true},
+		{5: true, 6: true, 7: true, 8: true, 9: true},
+		{10: true, 11: true, 12: true, 13: true, 14: true},
+	}
+	for _, linkage := range []Linkage{SingleLinkage, CompleteLinkage, AverageLinkage, WardLinkage} {
+		communities, err := cm.AHCK(3, linkage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(communities, groups) {
+			t.Fatalf("linkage %d: clusters = %v, want the groups", linkage, communities)
+		}
+	}
+
+	// without the weak edges, the groups are never merged, so fewer than 3
+	// clusters cannot be reached
+	cm = newTestModel(t, append(append(cliqueEdges(0, 5, 0.8), cliqueEdges(5, 5, 0.85)...),
+		cliqueEdges(10, 5, 0.9)...))
+	communities, err := cm.AHCK(1, AverageLinkage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(communities, groups) {
+		t.Fatalf("clusters = %v, want the groups", communities)
+	}
+}
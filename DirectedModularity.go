@@ -0,0 +1,229 @@
+package ConcurrenceBasedClustering
+
+// =============================================================================
+// struct DirectedModularity
+// brief introduction: this is the directed Modularity of Leicht and Newman
+//	(2008), which compares the weight inside communities with the expectation
+//	from the out-degrees and the in-degrees of their nodes.
+// note:
+//	The concurrences are read as directed: concurrences[u][v] is the weight of
+//	the edge from u to v, and NewConcurrenceModel stores the neighbors of each
+//	node as given, without symmetrizing them. So sumConcurrencesOf[u] is the
+//	out-degree k_u^out of u and sumConcurrences is the total weight m, each
+//	edge counted once. The quality is
+//	Q = 1/m sum_{i,j} (A_ij - r k_i^out k_j^in / m) delta(c_i, c_j),
+//	including the terms with i == j, so it equals NewModularityStandard on a
+//	symmetric model.
+type DirectedModularity struct {
+	r float64
+
+	// the in-degree of each node, weighted by the cardinalities like
+	// sumConcurrencesOf
+	inDegrees []float64
+
+	// the weights of the edges in both directions between each node and its
+	// neighbors, i.e. neighbors[u][v] = concurrences[u][v] + concurrences[v][u]
+	neighbors []map[int]float64
+
+	ConcurrenceModel
+}
+
+// =============================================================================
+// func NewDirectedModularity
+// brief description: create a new DirectedModularity
+// input:
+//	r: the resolution of modularity
+//	cm: a ConcurrenceModel whose concurrences are the directed edges
+func NewDirectedModularity(r float64, cm ConcurrenceModel) DirectedModularity {
+	// -------------------------------------------------------------------------
+	// step 1: compute the in-degrees and the neighbors in both directions
+	inDegrees := make([]float64, cm.n)
+	neighbors := make([]map[int]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		neighbors[u] = map[int]float64{}
+	}
+	for u := 0; u < cm.n; u++ {
+		for v, weightUV := range cm.concurrences[u] {
+			if v == u {
+				continue
+			}
+			inDegrees[v] += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+			neighbors[u][v] += weightUV
+			neighbors[v][u] += weightUV
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: return the result
+	return DirectedModularity{
+		r:                r,
+		inDegrees:        inDegrees,
+		neighbors:        neighbors,
+		ConcurrenceModel: cm,
+	}
+}
+
+// =============================================================================
+// func (qm DirectedModularity) GetNeighbors
+// note:
+//	Louvain moves a node only into the communities of its neighbors, so the
+//	neighbors include the nodes with an edge to u as well as those with an
+//	edge from u.
+func (qm DirectedModularity) GetNeighbors(u int) map[int]float64 {
+	return qm.neighbors[u]
+}
+
+// =============================================================================
+// func (qm DirectedModularity) Aggregate
+// note:
+//	ConcurrenceModel.Aggregate keeps the directions of the weights between
+//	communities and sums the out-degrees of their members. The in-degrees are
+//	summed here the same way, so that the weights inside the aggregated nodes
+//	are still counted.
+func (qm DirectedModularity) Aggregate(communities []map[int]bool) QualityModel {
+	result := NewDirectedModularity(qm.r, qm.ConcurrenceModel.Aggregate(communities))
+	for idxC, c := range communities {
+		result.inDegrees[idxC] = 0.0
+		for u, _ := range c {
+			result.inDegrees[idxC] += qm.inDegrees[u]
+		}
+	}
+	return QualityModel(result)
+}
+
+// =============================================================================
+// func (qm DirectedModularity) getSelfWeight
+// brief description: get the weight of the edges inside a node, which is 0
+//	unless the node is an aggregated one
+func (qm DirectedModularity) getSelfWeight(u int) float64 {
+	result := qm.sumConcurrencesOf[u]
+	for v, weightUV := range qm.concurrences[u] {
+		if v != u {
+			result -= weightUV * float64(qm.cardinalities[u]*qm.cardinalities[v])
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (qm DirectedModularity) Quality
+// brief description: this implements Quality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+// output:
+//	the value of DirectedModularity
+func (qm DirectedModularity) Quality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute 1/m
+	oneOverM := 1.0 / qm.sumConcurrences
+
+	// -------------------------------------------------------------------------
+	// step 2: compute modularity using the following equation:
+	// modularity = sum_c (w_c / m - r k_c^out k_c^in / m^2),
+	// where:
+	//	w_c is the weight of the edges inside c, including the weights inside
+	//		the nodes of an aggregated model,
+	//	k_c^out and k_c^in are the sums of the out-degrees and the in-degrees
+	//		over c.
+	result := 0.0
+	for _, c := range communities {
+		weightC := 0.0
+		outDegreeC := 0.0
+		inDegreeC := 0.0
+		for u, _ := range c {
+			outDegreeC += qm.sumConcurrencesOf[u]
+			inDegreeC += qm.inDegrees[u]
+			weightC += qm.getSelfWeight(u)
+			for v, weightUV := range qm.concurrences[u] {
+				if v != u && c[v] {
+					weightC += weightUV * float64(qm.cardinalities[u]*qm.cardinalities[v])
+				}
+			}
+		}
+		result += weightC*oneOverM - qm.r*outDegreeC*inDegreeC*oneOverM*oneOverM
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return result
+}
+
+// =============================================================================
+// func (qm DirectedModularity) getDeltaQuality
+// brief description: get the change of DirectedModularity from the totals of
+//	the two communities involved in a move
+// input:
+//	u: a node ID in oldCu
+//	weightToOld, weightToNew: the weights of the edges in both directions
+//		between u and the other nodes of oldCu, and between u and newCu
+//	outOld, inOld: the total out-degree and in-degree of oldCu, u included
+//	outNew, inNew: the total out-degree and in-degree of newCu
+// output:
+//	The change amount of DirectedModularity.
+func (qm DirectedModularity) getDeltaQuality(u int, weightToOld, weightToNew,
+	outOld, inOld, outNew, inNew float64) float64 {
+	// the weight inside u moves with u, so only the edges between u and the
+	// two communities change the weight inside them, while k_c^out k_c^in
+	// changes by k_u^out (k_new^in - k_old^in) + k_u^in (k_new^out - k_old^out)
+	// + 2 k_u^out k_u^in
+	oneOverM := 1.0 / qm.sumConcurrences
+	outU := qm.sumConcurrencesOf[u]
+	inU := qm.inDegrees[u]
+	return (weightToNew-weightToOld)*oneOverM -
+		qm.r*(outU*(inNew-inOld)+inU*(outNew-outOld)+2.0*outU*inU)*oneOverM*oneOverM
+}
+
+// =============================================================================
+// func (qm DirectedModularity) DeltaQuality
+// brief description: this implements DeltaQuality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+//	u: a node ID, 0 <= u < n.
+//	oldCu: the ID of the cluster u currently locates in.
+//	newCu: the ID of the cluster u wants to move in.
+// output:
+//	The change amount of DirectedModularity.
+func (qm DirectedModularity) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check whether oldCu and newCu are the same one.
+	// no change if oldCu == newCu
+	if oldCu == newCu {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the degrees of the two communities
+	outOld := 0.0
+	inOld := 0.0
+	for j, _ := range communities[oldCu] {
+		outOld += qm.sumConcurrencesOf[j]
+		inOld += qm.inDegrees[j]
+	}
+	outNew := 0.0
+	inNew := 0.0
+	for j, _ := range communities[newCu] {
+		outNew += qm.sumConcurrencesOf[j]
+		inNew += qm.inDegrees[j]
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: compute the weights between u and the two communities, in both
+	// directions
+	weightToOld := 0.0
+	weightToNew := 0.0
+	for j, weightUJ := range qm.neighbors[u] {
+		if j == u {
+			continue
+		}
+		weight := weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+		if communities[oldCu][j] {
+			weightToOld += weight
+		} else if communities[newCu][j] {
+			weightToNew += weight
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return qm.getDeltaQuality(u, weightToOld, weightToNew, outOld, inOld, outNew, inNew)
+}
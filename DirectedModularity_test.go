@@ -0,0 +1,76 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"testing"
+)
+
+// =============================================================================
+// func allPartitions
+// brief description: enumerate all partitions of the nodes 0..n-1 by their
+//	restricted growth strings, for brute-force optima of small graphs
+func allPartitions(n int) [][]map[int]bool {
+	result := [][]map[int]bool{}
+	labels := make([]int, n)
+	var grow func(u, numLabels int)
+	grow = func(u, numLabels int) {
+		if u == n {
+			communities := make([]map[int]bool, numLabels)
+			for c := 0; c < numLabels; c++ {
+				communities[c] = map[int]bool{}
+			}
+			for v := 0; v < n; v++ {
+				communities[labels[v]][v] = true
+			}
+			result = append(result, communities)
+			return
+		}
+		for label := 0; label <= numLabels && label < n; label++ {
+			labels[u] = label
+			if label == numLabels {
+				grow(u+1, numLabels+1)
+			} else {
+				grow(u+1, numLabels)
+			}
+		}
+	}
+	grow(0, 0)
+	return result
+}
+
+func TestDirectedModularityOfTwoCycles(t *testing.T) {
+	// the directed cycles 0->1->2->0 and 3->4->5->3, and the edge 2->3. The
+	// out-degrees are 1, 1, 2, 1, 1, 1 and the in-degrees 1, 1, 1, 2, 1, 1, so
+	// with m = 7 the cycles have Q = (6 - (4*3 + 3*4)/7)/7 = 18/49.
+	neighbors := [][]int{{1}, {2}, {0, 3}, {4}, {5}, {3}}
+	sims := [][]float64{{1}, {1}, {1, 1}, {1}, {1}, {1}}
+	cm := NewConcurrenceModel(neighbors, sims, []int{1, 1, 1, 1, 1, 1})
+	qm := NewDirectedModularity(1.0, cm)
+	cycles := []map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}}
+	if math.Abs(qm.Quality(cycles)-18.0/49.0) > 1e-12 {
+		t.Fatalf("Q of the cycles = %v, want 18/49", qm.Quality(cycles))
+	}
+
+	// the cycles are the unique optimum among the 203 partitions
+	partitions := allPartitions(cm.n)
+	if len(partitions) != 203 {
+		t.Fatalf("%d partitions of 6 nodes, want 203", len(partitions))
+	}
+	for _, communities := range partitions {
+		// community 0 has node 0, so the cycles are the partition whose
+		// community 0 is {0, 1, 2} and the other one the rest
+		isCycles := len(communities) == 2 && len(communities[0]) == 3 && communities[0][1] &&
+			communities[0][2]
+		if !isCycles {
+			if qm.Quality(communities) >= qm.Quality(cycles)-1e-12 {
+				t.Fatalf("Q of %v = %v >= Q of the cycles", communities,
+					qm.Quality(communities))
+			}
+		}
+	}
+	communities, _, err := LouvainWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, cycles)
+}
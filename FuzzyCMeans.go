@@ -0,0 +1,217 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// func getEmbedding
+// brief description: embed each node as the sparse vector of its similarities,
+//	including its similarity 1 to itself, normalized to unit length.
+// input:
+//	simMat: the similarity matrix
+// output:
+//	the unit vector of each node
+func getEmbedding(simMat []map[int]float64) []map[int]float64 {
+	embedding := make([]map[int]float64, len(simMat))
+	parallelFor(len(simMat), NumWorkers, func(u int) {
+		vector := make(map[int]float64, len(simMat[u])+1)
+		sumSquares := 0.0
+		for v, simUV := range simMat[u] {
+			if v != u && simUV > 0.0 {
+				vector[v] = simUV
+				sumSquares += simUV * simUV
+			}
+		}
+		vector[u] = 1.0
+		norm := math.Sqrt(sumSquares + 1.0)
+		for v, _ := range vector {
+			vector[v] /= norm
+		}
+		embedding[u] = vector
+	})
+	return embedding
+}
+
+// =============================================================================
+// func getCosineDistance
+// brief description: the cosine distance, i.e. 1 - cosine similarity, between a
+//	unit vector and a centroid.
+// input:
+//	vector: a unit vector
+//	centroid: a vector
+//	normOfCentroid: the norm of the centroid
+// output:
+//	the distance within [0, 1] for non-negative vectors
+func getCosineDistance(vector, centroid map[int]float64, normOfCentroid float64) float64 {
+	if normOfCentroid <= 0.0 {
+		return 1.0
+	}
+	dot := 0.0
+	for v, x := range vector {
+		dot += x * centroid[v]
+	}
+	return math.Max(0.0, 1.0-dot/normOfCentroid)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) FuzzyCMeans
+// brief description: fuzzy c-means clustering (Bezdek) over the similarity
+//	embedding, giving each node graded memberships of k clusters instead of
+//	a hard partition.
+// input:
+//	k: the number of clusters, within [1, n].
+//	m: the fuzzifier, must be > 1. The larger it is, the softer the
+//		memberships are; 2 is a common choice.
+//	simMat: the similarity matrix, e.g. cm.InduceCosineSimilarities(). It must
+//		have n rows, be symmetric and all elements 0~1. If it is nil, the
+//		concurrences are used as similarities, as DBScan does.
+//	maxIter: the maximum number of iterations, must be > 0.
+// output:
+//	the memberships of each node, i.e. a distribution over the clusters 0..k-1
+//	summing to 1. Zero memberships are not stored.
+// note:
+//	Each node is embedded as the unit vector of its similarities to all nodes,
+//	itself included, and the distance between a node and a centroid is 1 - sim
+//	with sim the cosine similarity between them. The centroids start at the
+//	nodes picked by farthest-first traversal from the node with the most
+//	similarities, so the result is deterministic. The iterations stop when no
+//	membership changes by more than 1e-6.
+func (cm ConcurrenceModel) FuzzyCMeans(k int, m float64, simMat []map[int]float64,
+	maxIter int) []map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and embed the nodes
+	n := cm.n
+	if k < 1 || k > n {
+		log.Fatalln(fmt.Sprintf("k = %d must be within [1, %d]", k, n))
+	}
+	if m <= 1.0 {
+		log.Fatalln(fmt.Sprintf("m = %v must be > 1", m))
+	}
+	if maxIter <= 0 {
+		log.Fatalln(fmt.Sprintf("maxIter = %d must be > 0", maxIter))
+	}
+	if simMat == nil {
+		simMat = cm.concurrences
+	}
+	if len(simMat) != n {
+		log.Fatalln(fmt.Sprintf("simMat has %d rows, but there are %d nodes", len(simMat), n))
+	}
+	embedding := getEmbedding(simMat)
+
+	// -------------------------------------------------------------------------
+	// step 2: initialize the centroids by farthest-first traversal
+	centroids := make([]map[int]float64, 0, k)
+	first := 0
+	for u := 1; u < n; u++ {
+		if len(simMat[u]) > len(simMat[first]) {
+			first = u
+		}
+	}
+	centroids = append(centroids, embedding[first])
+	minDistances := make([]float64, n)
+	for u := 0; u < n; u++ {
+		minDistances[u] = getCosineDistance(embedding[u], embedding[first], 1.0)
+	}
+	for len(centroids) < k {
+		farthest := 0
+		for u := 1; u < n; u++ {
+			if minDistances[u] > minDistances[farthest] {
+				farthest = u
+			}
+		}
+		centroids = append(centroids, embedding[farthest])
+		for u := 0; u < n; u++ {
+			minDistances[u] = math.Min(minDistances[u],
+				getCosineDistance(embedding[u], embedding[farthest], 1.0))
+		}
+	}
+	normsOfCentroids := make([]float64, k)
+	for c := 0; c < k; c++ {
+		normsOfCentroids[c] = 1.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: alternately update the memberships and the centroids
+	memberships := make([][]float64, n)
+	for u := 0; u < n; u++ {
+		memberships[u] = make([]float64, k)
+	}
+	exponent := 2.0 / (m - 1.0)
+	for iter := 0; iter < maxIter; iter++ {
+		// (3.1) update the memberships from the distances to the centroids
+		maxChanges := make([]float64, n)
+		parallelFor(n, NumWorkers, func(u int) {
+			distances := make([]float64, k)
+			numZeros := 0
+			for c := 0; c < k; c++ {
+				distances[c] = getCosineDistance(embedding[u], centroids[c], normsOfCentroids[c])
+				if distances[c] == 0.0 {
+					numZeros++
+				}
+			}
+			for c := 0; c < k; c++ {
+				membership := 0.0
+				if numZeros > 0 {
+					// the node is at some centroids, so it belongs to them only
+					if distances[c] == 0.0 {
+						membership = 1.0 / float64(numZeros)
+					}
+				} else {
+					sum := 0.0
+					for d := 0; d < k; d++ {
+						sum += math.Pow(distances[c]/distances[d], exponent)
+					}
+					membership = 1.0 / sum
+				}
+				maxChanges[u] = math.Max(maxChanges[u], math.Abs(membership-memberships[u][c]))
+				memberships[u][c] = membership
+			}
+		})
+		maxChange := 0.0
+		for u := 0; u < n; u++ {
+			maxChange = math.Max(maxChange, maxChanges[u])
+		}
+		if maxChange <= 1e-6 {
+			break
+		}
+
+		// (3.2) update each centroid as the weighted mean of the nodes
+		parallelFor(k, NumWorkers, func(c int) {
+			centroid := map[int]float64{}
+			sumWeights := 0.0
+			for u := 0; u < n; u++ {
+				weight := math.Pow(memberships[u][c], m)
+				if weight == 0.0 {
+					continue
+				}
+				for v, x := range embedding[u] {
+					centroid[v] += weight * x
+				}
+				sumWeights += weight
+			}
+			sumSquares := 0.0
+			for v, _ := range centroid {
+				centroid[v] /= sumWeights
+				sumSquares += centroid[v] * centroid[v]
+			}
+			centroids[c] = centroid
+			normsOfCentroids[c] = math.Sqrt(sumSquares)
+		})
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the non-zero memberships
+	result := make([]map[int]float64, n)
+	for u := 0; u < n; u++ {
+		result[u] = map[int]float64{}
+		for c, membership := range memberships[u] {
+			if membership > 0.0 {
+				result[u][c] = membership
+			}
+		}
+	}
+	return result
+}
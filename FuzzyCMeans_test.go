@@ -0,0 +1,69 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyCMeansRecoversPlantedPartition(t *testing.T) {
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 3, 10, 0.6, 0.02)
+	memberships := cm.FuzzyCMeans(3, 2.0, nil, 100)
+	clusters := make([]map[int]bool, 3)
+	for c := 0; c < 3; c++ {
+		clusters[c] = map[int]bool{}
+	}
+	for u, membershipsOfU := range memberships {
+		sum := 0.0
+		argmax := -1
+		for c, membership := range membershipsOfU {
+			sum += membership
+			if argmax < 0 || membership > membershipsOfU[argmax] {
+				argmax = c
+			}
+		}
+		if math.Abs(sum-1.0) > 1e-12 {
+			t.Fatalf("memberships of %d = %v sum to %v", u, membershipsOfU, sum)
+		}
+		clusters[argmax][u] = true
+	}
+	assertSamePartition(t, clusters, truth)
+}
+
+func TestFuzzyCMeansWithOneCluster(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 3, 10, 0.6, 0.02)
+	for u, membershipsOfU := range cm.FuzzyCMeans(1, 2.0, nil, 100) {
+		if !reflect.DeepEqual(membershipsOfU, map[int]float64{0: 1.0}) {
+			t.Fatalf("memberships of %d = %v, want 1 in cluster 0", u, membershipsOfU)
+		}
+	}
+}
+
+func TestFuzzyCMeansNodeOnCentroid(t *testing.T) {
+	// the centroids start at node 2, which has the most similarities, and then
+	// at the isolated node 6, the farthest from it. Node 6 is embedded as the
+	// unit vector of itself, so it is exactly at its centroid in the first
+	// iteration, and belongs to that cluster only, instead of dividing by a
+	// zero distance.
+	cm, err := newConcurrenceModelFromEdges(7, twoTriangles(t).GetEdges())
+	if err != nil {
+		t.Fatal(err)
+	}
+	memberships := cm.FuzzyCMeans(3, 2.0, nil, 1)
+	if !reflect.DeepEqual(memberships[6], map[int]float64{1: 1.0}) {
+		t.Fatalf("memberships of 6 = %v, want 1 in cluster 1", memberships[6])
+	}
+	for u := 0; u < 6; u++ {
+		sum := 0.0
+		for _, membership := range memberships[u] {
+			if math.IsNaN(membership) {
+				t.Fatalf("memberships of %d = %v", u, memberships[u])
+			}
+			sum += membership
+		}
+		if math.Abs(sum-1.0) > 1e-12 {
+			t.Fatalf("memberships of %d = %v sum to %v", u, memberships[u], sum)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ConcurrenceBasedClustering
+
+import (
+	"container/heap"
+)
+
+// =============================================================================
+// struct greedyCommunity
+// brief description: This is a community of GreedyModularity with the
+//	statistics needed to merge it.
+type greedyCommunity struct {
+	// the sum of the cardinalities of the nodes
+	size int
+
+	// the sum of sumConcurrencesOf over the nodes
+	degree float64
+
+	// the total concurrence to each adjacent community, each pair of nodes
+	// counted once and weighted by the cardinalities of both ends
+	weights map[int]float64
+
+	// the current modularity gain of merging with each adjacent community
+	deltas map[int]float64
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) GreedyModularity
+// brief description: the greedy modularity agglomeration of Clauset, Newman
+//	and Moore. Starting from single node communities, it repeatedly merges
+//	the two adjacent communities whose merge increases modularity the most,
+//	or decreases it the least, until no adjacent communities are left.
+// output:
+//	output 1: the dendrogram of the merges. The distance of a merge is minus
+//		its modularity gain, so the distances are not monotone in general,
+//		and the dendrogram has one root per connected component of the
+//		concurrence graph.
+//	output 2: the cut of the dendrogram with the largest modularity, in
+//		canonical order.
+// note:
+//	The modularity is the textbook one with r = 1, i.e. the Quality of
+//	NewModularityStandard(1.0, cm). A merge of C1 and C2 changes it by
+//	2 (w_12 / (2m) - k_1 k_2 / (2m)^2), where w_12 is the weight between them
+//	and k_1, k_2 are their degrees, so after a merge only the gains with the
+//	communities adjacent to the merged one change, and they are computed from
+//	the summed weights. With a max-heap of the gains, which is used lazily
+//	like in AHCDendrogramWithLinkage, it takes O(m d log n) time for a
+//	dendrogram of depth d.
+func (cm ConcurrenceModel) GreedyModularity() (*Dendrogram, []map[int]bool) {
+	// -------------------------------------------------------------------------
+	// step 1: initialize the single node communities and the modularity
+	n := cm.n
+	dendrogram := &Dendrogram{N: n, Merges: make([]DendrogramMerge, 0, n)}
+	if cm.sumConcurrences <= 0.0 {
+		return dendrogram, dendrogram.cut(0)
+	}
+	oneOverTwoM := 1.0 / cm.sumConcurrences
+	communities := make([]*greedyCommunity, n, 2*n)
+	modularity := 0.0
+	for u := 0; u < n; u++ {
+		communities[u] = &greedyCommunity{
+			size:    cm.cardinalities[u],
+			degree:  cm.sumConcurrencesOf[u],
+			weights: map[int]float64{},
+			deltas:  map[int]float64{},
+		}
+		for v, weightUV := range cm.concurrences[u] {
+			if v != u {
+				communities[u].weights[v] = weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+			}
+		}
+		a := cm.sumConcurrencesOf[u] * oneOverTwoM
+		modularity += 2.0*cm.getSelfWeight(u)*oneOverTwoM - a*a
+	}
+	getDelta := func(c1, c2 *greedyCommunity, weight12 float64) float64 {
+		return 2.0 * (weight12*oneOverTwoM - c1.degree*c2.degree*oneOverTwoM*oneOverTwoM)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: put the gains of adjacent nodes into the heap, with distances
+	// -gain so that the min-heap pops the largest gain
+	h := &clusterPairHeap{}
+	for u := 0; u < n; u++ {
+		for v, weightUV := range communities[u].weights {
+			if u < v {
+				delta := getDelta(communities[u], communities[v], weightUV)
+				communities[u].deltas[v] = delta
+				communities[v].deltas[u] = delta
+				*h = append(*h, clusterPair{distance: -delta, a: u, b: v})
+			}
+		}
+	}
+	heap.Init(h)
+
+	// -------------------------------------------------------------------------
+	// step 3: merge the adjacent communities with the largest gain until none
+	// is left, recording the merges and the best modularity
+	bestModularity := modularity
+	bestNumMerges := 0
+	alive := make([]bool, n, 2*n)
+	for u := 0; u < n; u++ {
+		alive[u] = true
+	}
+	for h.Len() > 0 {
+		// (3.1) pop the pair with the largest gain, skipping stale ones
+		pair := heap.Pop(h).(clusterPair)
+		if !alive[pair.a] || !alive[pair.b] || communities[pair.a].deltas[pair.b] != -pair.distance {
+			continue
+		}
+		c1 := communities[pair.a]
+		c2 := communities[pair.b]
+
+		// (3.2) create the merged community, summing the weights to the
+		// communities adjacent to c1 or c2
+		c3 := &greedyCommunity{
+			size:    c1.size + c2.size,
+			degree:  c1.degree + c2.degree,
+			weights: map[int]float64{},
+			deltas:  map[int]float64{},
+		}
+		for _, idxC := range []int{pair.a, pair.b} {
+			for idxD, weight := range communities[idxC].weights {
+				if idxD != pair.a && idxD != pair.b {
+					c3.weights[idxD] += weight
+				}
+			}
+		}
+		idxC3 := len(communities)
+		communities = append(communities, c3)
+		alive = append(alive, true)
+		alive[pair.a] = false
+		alive[pair.b] = false
+
+		// (3.3) update the adjacent communities and their gains with c3
+		for idxD, weight := range c3.weights {
+			d := communities[idxD]
+			delete(d.weights, pair.a)
+			delete(d.weights, pair.b)
+			delete(d.deltas, pair.a)
+			delete(d.deltas, pair.b)
+			d.weights[idxC3] = weight
+			delta := getDelta(c3, d, weight)
+			c3.deltas[idxD] = delta
+			d.deltas[idxC3] = delta
+			heap.Push(h, clusterPair{distance: -delta, a: idxD, b: idxC3})
+		}
+		communities[pair.a] = nil
+		communities[pair.b] = nil
+
+		// (3.4) record the merge and the modularity after it
+		modularity -= pair.distance
+		dendrogram.Merges = append(dendrogram.Merges, DendrogramMerge{
+			Left:     pair.a,
+			Right:    pair.b,
+			Distance: pair.distance,
+			Size:     c3.size,
+		})
+		if modularity > bestModularity {
+			bestModularity = modularity
+			bestNumMerges = len(dendrogram.Merges)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return dendrogram, dendrogram.cut(bestNumMerges)
+}
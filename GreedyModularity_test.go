@@ -0,0 +1,30 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGreedyModularityOfKarateClub(t *testing.T) {
+	// the partition and modularity reported by
+	// networkx.algorithms.community.greedy_modularity_communities on the
+	// unweighted karate club
+	cm, _ := karateClub(t)
+	dendrogram, communities := cm.GreedyModularity()
+	want := []map[int]bool{
+		{0: true, 4: true, 5: true, 6: true, 10: true, 11: true, 16: true, 19: true},
+		{1: true, 2: true, 3: true, 7: true, 9: true, 12: true, 13: true, 17: true, 21: true},
+		{8: true, 14: true, 15: true, 18: true, 20: true, 22: true, 23: true, 24: true, 25: true,
+			26: true, 27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true},
+	}
+	assertSamePartition(t, communities, want)
+	quality := NewModularityStandard(1.0, cm).Quality(communities)
+	if math.Abs(quality-0.3806706114398422) > 1e-12 {
+		t.Fatalf("modularity = %v, want 0.3806706114398422", quality)
+	}
+	cut, err := dendrogram.CutK(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, cut, want)
+}
@@ -0,0 +1,394 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"sort"
+)
+
+// =============================================================================
+// struct linkageNode
+// brief description: This is a node of a single linkage tree. Nodes 0..n-1 are
+//	the points, and node n+i is the i-th merge.
+type linkageNode struct {
+	left, right int
+	distance    float64
+	size        int
+}
+
+// =============================================================================
+// struct condensedCluster
+// brief description: This is a cluster of the condensed tree of HDBSCAN.
+type condensedCluster struct {
+	parent      int
+	birthLambda float64
+	stability   float64
+	children    []int
+
+	// the points leaving the cluster, and the lambda at which each one leaves
+	points  []int
+	lambdas []float64
+}
+
+// =============================================================================
+// struct linkageEdge
+// brief description: This is an edge between two points with a distance.
+type linkageEdge struct {
+	u, v     int
+	distance float64
+}
+
+// =============================================================================
+// func buildSingleLinkageTree
+// brief description: build the single linkage tree of points with Kruskal's
+//	algorithm.
+// input:
+//	sizes: the size of each point, e.g. its cardinality
+//	edges: the edges between points. They are sorted in place.
+// output:
+//	output 1: the nodes of the tree, the points first and then the merges in
+//		the order of increasing distance
+//	output 2: the roots of the tree, one for each connected component, in
+//		ascending order
+func buildSingleLinkageTree(sizes []int, edges []linkageEdge) ([]linkageNode, []int) {
+	// -------------------------------------------------------------------------
+	// step 1: sort the edges by distance
+	n := len(sizes)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].distance != edges[j].distance {
+			return edges[i].distance < edges[j].distance
+		}
+		if edges[i].u != edges[j].u {
+			return edges[i].u < edges[j].u
+		}
+		return edges[i].v < edges[j].v
+	})
+
+	// -------------------------------------------------------------------------
+	// step 2: merge the components along the edges of the minimum spanning
+	// forest
+	nodes := make([]linkageNode, n, 2*n)
+	tops := make([]int, n)
+	for pt := 0; pt < n; pt++ {
+		nodes[pt] = linkageNode{left: -1, right: -1, size: sizes[pt]}
+		tops[pt] = pt
+	}
+	uf := newUnionFind(n)
+	for _, edge := range edges {
+		rootU := uf.find(edge.u)
+		rootV := uf.find(edge.v)
+		if rootU == rootV {
+			continue
+		}
+		left := tops[rootU]
+		right := tops[rootV]
+		nodes = append(nodes, linkageNode{
+			left:     left,
+			right:    right,
+			distance: edge.distance,
+			size:     nodes[left].size + nodes[right].size,
+		})
+		uf.union(rootU, rootV)
+		tops[uf.find(rootU)] = len(nodes) - 1
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	roots := []int{}
+	for pt := 0; pt < n; pt++ {
+		if uf.find(pt) == pt {
+			roots = append(roots, tops[pt])
+		}
+	}
+	sort.Ints(roots)
+	return nodes, roots
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getMutualReachabilityTree
+// brief description: build the single linkage tree of the mutual
+//	reachability distances with Kruskal's algorithm.
+// input:
+//	coreDistances: the core distance of each point
+// output:
+//	output 1: the nodes of the tree, the points first and then the merges in
+//		the order of increasing distance
+//	output 2: the roots of the tree, one for each connected component, in
+//		ascending order
+func (cm ConcurrenceModel) getMutualReachabilityTree(coreDistances []float64,
+) ([]linkageNode, []int) {
+	// -------------------------------------------------------------------------
+	// step 1: collect the edges with finite mutual reachability distances
+	edges := []linkageEdge{}
+	for u := 0; u < cm.n; u++ {
+		for v, similarity := range cm.concurrences[u] {
+			if v <= u {
+				continue
+			}
+			distance := math.Max(1.0-similarity, math.Max(coreDistances[u], coreDistances[v]))
+			if !math.IsInf(distance, 1) {
+				edges = append(edges, linkageEdge{u: u, v: v, distance: distance})
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: build the tree
+	return buildSingleLinkageTree(cm.cardinalities, edges)
+}
+
+// =============================================================================
+// func collectLeaves
+// brief description: collect the points under a node of a single linkage tree
+func collectLeaves(nodes []linkageNode, node int, points []int) []int {
+	stack := []int{node}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if nodes[top].left < 0 {
+			points = append(points, top)
+		} else {
+			stack = append(stack, nodes[top].left, nodes[top].right)
+		}
+	}
+	return points
+}
+
+// =============================================================================
+// func (c *condensedCluster) addPoints
+// brief description: add the points under a node of a single linkage tree to
+//	the points leaving a cluster at a lambda
+func (c *condensedCluster) addPoints(nodes []linkageNode, node int, lambda float64) {
+	c.points = collectLeaves(nodes, node, c.points)
+	for len(c.lambdas) < len(c.points) {
+		c.lambdas = append(c.lambdas, lambda)
+	}
+}
+
+// =============================================================================
+// func lambdaOf
+// brief description: convert a distance into the density level lambda = 1/d
+//	used by HDBSCAN. Distance 0 is mapped to a large but finite lambda.
+func lambdaOf(distance float64) float64 {
+	return 1.0 / math.Max(distance, 1e-12)
+}
+
+// =============================================================================
+// func condenseTree
+// brief description: condense a single linkage tree into the clusters of size
+//	at least minClusterSize, computing their stabilities.
+// input:
+//	nodes, roots: the single linkage tree
+//	minClusterSize: the minimum size of clusters
+// output:
+//	the condensed clusters. Cluster 0 is the root of all points, and each
+//	cluster has a larger index than its parent.
+func condenseTree(nodes []linkageNode, roots []int, minClusterSize int) []condensedCluster {
+	// -------------------------------------------------------------------------
+	// step 1: create the root, and a child of it for each large enough
+	// component, which are separated at distance +Inf, i.e. lambda 0
+	clusters := []condensedCluster{{parent: -1}}
+	type task struct {
+		node, cluster int
+
+		// the lambda of the split reaching the node
+		lambda float64
+	}
+	stack := []task{}
+	if len(roots) == 1 {
+		stack = append(stack, task{node: roots[0], cluster: 0})
+	} else {
+		for _, root := range roots {
+			if nodes[root].size >= minClusterSize {
+				clusters = append(clusters, condensedCluster{parent: 0})
+				clusters[0].children = append(clusters[0].children, len(clusters)-1)
+				stack = append(stack, task{node: root, cluster: len(clusters) - 1})
+			} else {
+				clusters[0].addPoints(nodes, root, 0.0)
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: walk down the tree. At each split, a cluster either splits into
+	// two new clusters, or loses the points of its small side, which leave it
+	// at the lambda of the split.
+	for len(stack) > 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := nodes[t.node]
+		c := t.cluster
+		if node.left < 0 {
+			clusters[c].points = append(clusters[c].points, t.node)
+			clusters[c].lambdas = append(clusters[c].lambdas, t.lambda)
+			continue
+		}
+		lambda := lambdaOf(node.distance)
+		birth := clusters[c].birthLambda
+		leftIsLarge := nodes[node.left].size >= minClusterSize
+		rightIsLarge := nodes[node.right].size >= minClusterSize
+		switch {
+		case leftIsLarge && rightIsLarge:
+			clusters[c].stability += (lambda - birth) * float64(node.size)
+			for _, child := range []int{node.left, node.right} {
+				clusters = append(clusters, condensedCluster{parent: c, birthLambda: lambda})
+				clusters[c].children = append(clusters[c].children, len(clusters)-1)
+				stack = append(stack, task{node: child, cluster: len(clusters) - 1, lambda: lambda})
+			}
+		case leftIsLarge || rightIsLarge:
+			large, small := node.left, node.right
+			if rightIsLarge {
+				large, small = node.right, node.left
+			}
+			clusters[c].stability += (lambda - birth) * float64(nodes[small].size)
+			clusters[c].addPoints(nodes, small, lambda)
+			stack = append(stack, task{node: large, cluster: c, lambda: lambda})
+		default:
+			clusters[c].stability += (lambda - birth) * float64(node.size)
+			clusters[c].addPoints(nodes, t.node, lambda)
+		}
+	}
+	return clusters
+}
+
+// =============================================================================
+// func selectStableClusters
+// brief description: select the clusters of excess of mass, i.e. the clusters
+//	that are more stable than their descendants together.
+// input:
+//	clusters: the condensed clusters
+// output:
+//	whether each cluster is selected. The root is never selected.
+func selectStableClusters(clusters []condensedCluster) []bool {
+	// -------------------------------------------------------------------------
+	// step 1: compare each cluster with its children bottom up
+	selected := make([]bool, len(clusters))
+	stabilities := make([]float64, len(clusters))
+	for c := len(clusters) - 1; c > 0; c-- {
+		sumChildren := 0.0
+		for _, child := range clusters[c].children {
+			sumChildren += stabilities[child]
+		}
+		if len(clusters[c].children) > 0 && sumChildren > clusters[c].stability {
+			stabilities[c] = sumChildren
+		} else {
+			stabilities[c] = clusters[c].stability
+			selected[c] = true
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: deselect the descendants of selected clusters top down
+	covered := make([]bool, len(clusters))
+	for c := 1; c < len(clusters); c++ {
+		parent := clusters[c].parent
+		if covered[parent] || selected[parent] {
+			covered[c] = true
+			selected[c] = false
+		}
+	}
+	return selected
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) HDBSCAN
+// brief description: This is an implementation of HDBSCAN, which extracts the
+//	most stable clusters from the hierarchy of all DBScan clusterings instead
+//	of using a single eps.
+// input:
+//	minClusterSize: the minimum size of clusters, which is also used as the
+//		minPts of the core distances. Sizes count cardinalities.
+// output:
+//	output 1: the stable clusters, ordered by their smallest members.
+//	output 2: the noise points, which are in no cluster.
+// note:
+//	Like DBScan, the concurrences are used directly as similarities, and the
+//	distance between two points is 1 - similarity. The hierarchy is the
+//	single linkage tree of the mutual reachability distances. Points without
+//	concurrence are never linked directly, so each connected component is a
+//	separate subtree. See HDBSCANWithProbabilities for the strength of
+//	membership of each point.
+func (cm ConcurrenceModel) HDBSCAN(minClusterSize int) ([]map[int]bool, map[int]bool) {
+	communities, noise, _ := cm.HDBSCANWithProbabilities(minClusterSize)
+	return communities, noise
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) HDBSCANWithProbabilities
+// brief description: HDBSCAN with the membership probability of each point.
+//	See HDBSCAN.
+// input:
+//	minClusterSize: the same as in HDBSCAN.
+// output:
+//	output 1: the stable clusters, ordered by their smallest members.
+//	output 2: the noise points, which are in no cluster.
+//	output 3: the membership probability of each point within [0, 1], 0 for
+//		noise points.
+// note:
+//	As in the hdbscan library, the probability of a point in a selected
+//	cluster is min(lambda_p, lambda_max) / lambda_max, where lambda_p is the
+//	lambda at which the point leaves the condensed tree, and lambda_max is the
+//	largest lambda at which a point or a child cluster leaves the selected
+//	cluster itself. So the points in child clusters of the selected cluster
+//	have probability 1.
+func (cm ConcurrenceModel) HDBSCANWithProbabilities(minClusterSize int) ([]map[int]bool,
+	map[int]bool, []float64) {
+	// -------------------------------------------------------------------------
+	// step 1: build and condense the hierarchy
+	coreDistances := cm.getCoreDistances(minClusterSize)
+	nodes, roots := cm.getMutualReachabilityTree(coreDistances)
+	clusters := condenseTree(nodes, roots, minClusterSize)
+
+	// -------------------------------------------------------------------------
+	// step 2: find the lambda at which each point leaves the condensed tree,
+	// and the largest lambda at which something leaves each cluster
+	lambdas := make([]float64, cm.n)
+	maxLambdas := make([]float64, len(clusters))
+	for c, cluster := range clusters {
+		for i, pt := range cluster.points {
+			lambdas[pt] = cluster.lambdas[i]
+			maxLambdas[c] = math.Max(maxLambdas[c], cluster.lambdas[i])
+		}
+		for _, child := range cluster.children {
+			maxLambdas[c] = math.Max(maxLambdas[c], clusters[child].birthLambda)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: collect the points of selected clusters, including the points
+	// of their descendants
+	selected := selectStableClusters(clusters)
+	members := make([][]int, len(clusters))
+	for c := len(clusters) - 1; c >= 0; c-- {
+		members[c] = append(members[c], clusters[c].points...)
+		if c > 0 {
+			parent := clusters[c].parent
+			members[parent] = append(members[parent], members[c]...)
+		}
+	}
+	communities := []map[int]bool{}
+	noise := map[int]bool{}
+	for pt := 0; pt < cm.n; pt++ {
+		noise[pt] = true
+	}
+	probabilities := make([]float64, cm.n)
+	for c := 1; c < len(clusters); c++ {
+		if !selected[c] {
+			continue
+		}
+		community := map[int]bool{}
+		for _, pt := range members[c] {
+			community[pt] = true
+			delete(noise, pt)
+			if maxLambdas[c] > 0.0 {
+				probabilities[pt] = math.Min(lambdas[pt], maxLambdas[c]) / maxLambdas[c]
+			} else {
+				probabilities[pt] = 1.0
+			}
+		}
+		communities = append(communities, community)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return canonicalizeCommunities(communities, nil), noise, probabilities
+}
@@ -0,0 +1,69 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHDBSCANMatchesReference(t *testing.T) {
+	// points on a line, with the similarity 1 - their distance between every
+	// pair, i.e. the precomputed metric |x_u - x_v|. The expected values were
+	// computed by a separate implementation of the algorithm of the hdbscan
+	// library: core distances counting the point itself, Prim's minimum
+	// spanning tree, the condensed tree, excess of mass and its probabilities.
+	for _, tc := range []struct {
+		xs             []float64
+		minClusterSize int
+		clusters       []map[int]bool
+		probabilities  []float64
+	}{
+		{
+			xs:             []float64{0, 0.02, 0.05, 0.09, 0.14, 0.40, 0.43, 0.47, 0.48, 0.52, 0.80, 0.9},
+			minClusterSize: 3,
+			clusters: []map[int]bool{{0: true, 1: true, 2: true, 3: true, 4: true},
+				{5: true, 6: true, 7: true, 8: true, 9: true}},
+			probabilities: []float64{1, 1, 1, 1, 0.5555555555555555, 0.5714285714285714, 1, 1, 1,
+				0.8, 0, 0},
+		},
+		{
+			xs: []float64{0, 0.01, 0.03, 0.06, 0.16, 0.17, 0.19, 0.22, 0.5, 0.56, 0.58, 0.59,
+				0.63, 0.95},
+			minClusterSize: 3,
+			clusters: []map[int]bool{{0: true, 1: true, 2: true, 3: true},
+				{4: true, 5: true, 6: true, 7: true}, {8: true, 9: true, 10: true, 11: true, 12: true}},
+			probabilities: []float64{1, 1, 1, 0.6, 1, 1, 1, 0.6, 0.375, 1, 1, 1, 0.6, 0},
+		},
+		{
+			xs: []float64{0, 0.01, 0.03, 0.06, 0.16, 0.17, 0.19, 0.22, 0.5, 0.56, 0.58, 0.59,
+				0.63, 0.95},
+			minClusterSize: 4,
+			clusters: []map[int]bool{{0: true, 1: true, 2: true, 3: true},
+				{4: true, 5: true, 6: true, 7: true}, {8: true, 9: true, 10: true, 11: true, 12: true}},
+			probabilities: []float64{1, 1, 1, 1, 1, 1, 1, 1, 0.7777777777777778, 1, 1, 1, 1, 0},
+		},
+	} {
+		edges := []Edge{}
+		for u := 0; u < len(tc.xs); u++ {
+			for v := u + 1; v < len(tc.xs); v++ {
+				edges = append(edges, Edge{u, v, 1.0 - math.Abs(tc.xs[u]-tc.xs[v])})
+			}
+		}
+		cm := newTestModel(t, edges)
+		communities, noise, probabilities := cm.HDBSCANWithProbabilities(tc.minClusterSize)
+		assertSamePartition(t, communities, tc.clusters)
+		for u := 0; u < cm.n; u++ {
+			inCluster := false
+			for _, c := range tc.clusters {
+				inCluster = inCluster || c[u]
+			}
+			if noise[u] == inCluster {
+				t.Fatalf("minClusterSize = %d: point %d is noise = %v", tc.minClusterSize, u,
+					noise[u])
+			}
+			if math.Abs(probabilities[u]-tc.probabilities[u]) > 1e-9 {
+				t.Fatalf("minClusterSize = %d: probability of %d = %v, want %v", tc.minClusterSize,
+					u, probabilities[u], tc.probabilities[u])
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+)
+
+// =============================================================================
+// struct IncrementalDBScan
+// brief description: This is a DBScan clusterer that follows the additions of
+//	concurrences to a model, updating only the neighborhoods they touch
+//	instead of clustering again from scratch.
+// note:
+//	Adding concurrences only increases similarities, so neighborhoods only
+//	grow, points only become core, and clusters only grow or merge, as in the
+//	insertions of incremental DBSCAN (Ester et al., 1998). The clusters of core
+//	points are kept in a union-find, and each non-core point keeps the first
+//	core neighbor it got as its anchor. Removals, which may split clusters,
+//	are not supported; run DBScan again after them. The methods are not safe
+//	for concurrent use.
+type IncrementalDBScan struct {
+	cm     *ConcurrenceModel
+	eps    float64
+	minPts int
+
+	// the density of the neighborhood of each point, itself included
+	densities []int
+
+	// the connected components of core points, over all points
+	uf unionFind
+
+	// the core neighbor of each non-core point, -1 if it has none
+	anchors []int
+}
+
+// =============================================================================
+// func (cm *ConcurrenceModel) IncrementalDBScan
+// brief description: run DBScan on the model and keep its state for updates.
+//	See DBScan.
+// input:
+//	eps: the radius of neighborhood.
+//	minPts: the minimum density of core points.
+// output:
+//	the clusterer. The points and concurrences must be added through its
+//	AddNode and AddConcurrence from now on, so that it sees them.
+func (cm *ConcurrenceModel) IncrementalDBScan(eps float64, minPts int) *IncrementalDBScan {
+	// -------------------------------------------------------------------------
+	// step 1: compute the densities of all points
+	idb := &IncrementalDBScan{
+		cm:        cm,
+		eps:       eps,
+		minPts:    minPts,
+		densities: make([]int, cm.n),
+		uf:        newUnionFind(cm.n),
+		anchors:   make([]int, cm.n),
+	}
+	for pt := 0; pt < cm.n; pt++ {
+		idb.densities[pt] = cm.cardinalities[pt]
+		for neighbor, _ := range cm.concurrences[pt] {
+			if idb.isNeighbor(pt, neighbor) {
+				idb.densities[pt] += cm.cardinalities[neighbor]
+			}
+		}
+		idb.anchors[pt] = -1
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: link the core points and anchor the non-core points
+	for pt := 0; pt < cm.n; pt++ {
+		if idb.isCore(pt) {
+			idb.linkCorePoint(pt)
+		}
+	}
+	return idb
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) isNeighbor
+// brief description: check whether two different points are neighbors, i.e.
+//	their similarity sim satisfies sim+eps >= 1, as in DBScan
+func (idb *IncrementalDBScan) isNeighbor(u, v int) bool {
+	similarity, exists := idb.cm.concurrences[u][v]
+	return exists && u != v && similarity+idb.eps >= 1.0
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) isCore
+// brief description: check whether a point is a core point
+func (idb *IncrementalDBScan) isCore(pt int) bool {
+	return idb.densities[pt] >= idb.minPts
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) linkCorePoint
+// brief description: link a core point to its core neighbors, and anchor its
+//	non-core neighbors without a core neighbor to it
+func (idb *IncrementalDBScan) linkCorePoint(pt int) {
+	for neighbor, _ := range idb.cm.concurrences[pt] {
+		if !idb.isNeighbor(pt, neighbor) {
+			continue
+		}
+		if idb.isCore(neighbor) {
+			idb.uf.union(pt, neighbor)
+		} else if idb.anchors[neighbor] < 0 {
+			idb.anchors[neighbor] = pt
+		}
+	}
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) AddNode
+// brief description: add an isolated point to the model. See
+//	ConcurrenceModel.AddNode.
+// input:
+//	cardinality: the cardinality of the new point, must be > 0.
+// output:
+//	the ID of the new point. It is a singleton cluster until concurrences are
+//	added to it, and a core point if cardinality >= minPts.
+func (idb *IncrementalDBScan) AddNode(cardinality int) int {
+	pt := idb.cm.AddNode(cardinality)
+	idb.uf.add()
+	idb.densities = append(idb.densities, cardinality)
+	idb.anchors = append(idb.anchors, -1)
+	return pt
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) AddConcurrence
+// brief description: add an amount of concurrence between two points to the
+//	model, and update the clusters. See ConcurrenceModel.AddConcurrence.
+// input:
+//	i, j: two point IDs. If any of them is not less than n, the model grows to
+//		contain it.
+//	delta: the amount of concurrence to be added, must be > 0.
+// note:
+//	If i and j become neighbors, only their densities change, and only the
+//	neighborhoods of the points becoming core are scanned, so an addition
+//	takes O(1) unless it creates core points.
+func (idb *IncrementalDBScan) AddConcurrence(i, j int, delta float64) {
+	// -------------------------------------------------------------------------
+	// step 1: add the concurrence, and grow the state with the model. A new
+	// point has no neighbor but the other one, which is linked below.
+	wasNeighbor := i < idb.cm.n && j < idb.cm.n && idb.isNeighbor(i, j)
+	idb.cm.AddConcurrence(i, j, delta)
+	for len(idb.densities) < idb.cm.n {
+		pt := idb.uf.add()
+		idb.densities = append(idb.densities, idb.cm.cardinalities[pt])
+		idb.anchors = append(idb.anchors, -1)
+	}
+	if wasNeighbor || !idb.isNeighbor(i, j) {
+		return
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: update the densities, and link the points becoming core
+	wasCoreI := idb.isCore(i)
+	wasCoreJ := idb.isCore(j)
+	idb.densities[i] += idb.cm.cardinalities[j]
+	idb.densities[j] += idb.cm.cardinalities[i]
+	if !wasCoreI && idb.isCore(i) {
+		idb.linkCorePoint(i)
+	}
+	if !wasCoreJ && idb.isCore(j) {
+		idb.linkCorePoint(j)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: link the new pair of neighbors if they were not linked above
+	switch {
+	case idb.isCore(i) && idb.isCore(j):
+		idb.uf.union(i, j)
+	case idb.isCore(i) && idb.anchors[j] < 0:
+		idb.anchors[j] = i
+	case idb.isCore(j) && idb.anchors[i] < 0:
+		idb.anchors[i] = j
+	}
+}
+
+// =============================================================================
+// func (idb *IncrementalDBScan) Communities
+// brief description: get the current clusters
+// output:
+//	output 1: A list of clusters, ordered by their smallest members. Like
+//		DBScan, points that are not density-reachable from any core point are
+//		singleton clusters.
+//	output 2: the community ID of each point.
+// note:
+//	The core points are clustered exactly as DBScan does. A border point
+//	reachable from several clusters is in the cluster of its anchor, which
+//	may differ from the choice of DBScan.
+func (idb *IncrementalDBScan) Communities() ([]map[int]bool, []int) {
+	n := idb.cm.n
+	if len(idb.densities) != n {
+		log.Fatalln(fmt.Sprintf("the model has %d points, but the clusterer has %d. "+
+			"Points must be added through IncrementalDBScan.AddNode and AddConcurrence.",
+			n, len(idb.densities)))
+	}
+	communities := []map[int]bool{}
+	communityIDs := make([]int, n)
+	idOfRoot := map[int]int{}
+	for pt := 0; pt < n; pt++ {
+		representative := pt
+		if !idb.isCore(pt) && idb.anchors[pt] >= 0 {
+			representative = idb.anchors[pt]
+		}
+		root := idb.uf.find(representative)
+		id, exists := idOfRoot[root]
+		if !exists || !idb.isCore(representative) {
+			id = len(communities)
+			communities = append(communities, map[int]bool{})
+			if idb.isCore(representative) {
+				idOfRoot[root] = id
+			}
+		}
+		communities[id][pt] = true
+		communityIDs[pt] = id
+	}
+	communities = canonicalizeCommunities(communities, communityIDs)
+	return communities, communityIDs
+}
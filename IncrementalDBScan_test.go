@@ -0,0 +1,86 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIncrementalDBScanMatchesRebuild(t *testing.T) {
+	const eps = 0.5
+	const minPts = 4
+	rng := rand.New(rand.NewSource(1))
+	cm, err := newConcurrenceModelFromEdges(30, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idb := cm.IncrementalDBScan(eps, minPts)
+	for step := 0; step < 400; step++ {
+		// ---------------------------------------------------------------------
+		// step 1: add a node, or a concurrence that may also grow the model
+		switch rng.Intn(40) {
+		case 0:
+			idb.AddNode(1 + rng.Intn(2))
+		case 1:
+			idb.AddConcurrence(cm.n, rng.Intn(cm.n), 0.6)
+		default:
+			i := rng.Intn(cm.n)
+			j := rng.Intn(cm.n)
+			if i != j {
+				idb.AddConcurrence(i, j, 0.1+0.4*rng.Float64())
+			}
+		}
+		if step%20 != 19 {
+			continue
+		}
+
+		// ---------------------------------------------------------------------
+		// step 2: compare with DBScan from scratch. The core points must be
+		// clustered the same way, and each other point must be with one of its
+		// core neighbors, or alone if it has none.
+		communities, communityIDs := idb.Communities()
+		if err := ValidatePartition(communities, cm.n); err != nil {
+			t.Fatal(err)
+		}
+		_, rebuiltIDs := cm.DBScan(eps, minPts)
+		isCore := make([]bool, cm.n)
+		for u := 0; u < cm.n; u++ {
+			density := cm.cardinalities[u]
+			for v, sim := range cm.concurrences[u] {
+				if v != u && sim+eps >= 1.0 {
+					density += cm.cardinalities[v]
+				}
+			}
+			isCore[u] = density >= minPts
+		}
+		for u := 0; u < cm.n; u++ {
+			if isCore[u] {
+				for v := 0; v < u; v++ {
+					if isCore[v] && (communityIDs[u] == communityIDs[v]) !=
+						(rebuiltIDs[u] == rebuiltIDs[v]) {
+						t.Fatalf("step %d: core points %d and %d are together = %v, "+
+							"but %v from scratch", step, u, v,
+							communityIDs[u] == communityIDs[v], rebuiltIDs[u] == rebuiltIDs[v])
+					}
+				}
+				continue
+			}
+			hasCoreNeighbor := false
+			withCoreNeighbor := false
+			for v, sim := range cm.concurrences[u] {
+				if v != u && sim+eps >= 1.0 && isCore[v] {
+					hasCoreNeighbor = true
+					withCoreNeighbor = withCoreNeighbor || communityIDs[v] == communityIDs[u]
+				}
+			}
+			if withCoreNeighbor != hasCoreNeighbor ||
+				(!hasCoreNeighbor && len(communities[communityIDs[u]]) != 1) {
+				t.Fatalf("step %d: non-core point %d is in %v", step, u,
+					communities[communityIDs[u]])
+			}
+		}
+	}
+	if len(idb.densities) != cm.n || cm.n <= 30 {
+		t.Fatalf("the model has grown to %d points, the clusterer to %d", cm.n,
+			len(idb.densities))
+	}
+}
@@ -0,0 +1,221 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+)
+
+// =============================================================================
+// struct InfomapQuality
+// brief introduction: this is the two-level map equation of Infomap (Rosvall
+//	and Bergstrom, 2008) as a quality model. The map equation L is the
+//	expected description length of a step of a random walk on the concurrence
+//	graph when the nodes are coded by modules, so InfomapQuality = -L and a
+//	larger value is better.
+// note:
+//	The walk is undirected, so its stationary distribution is p_u = k_u / 2m,
+//	with k_u the sumConcurrencesOf[u] and 2m the sumConcurrences, and the
+//	exit flow of a module c is q_c = cut_c / 2m, with cut_c the weight between
+//	c and the other nodes. With plogp(x) = x log2(x) and plogp(0) = 0,
+//	L = plogp(sum_c q_c) - 2 sum_c plogp(q_c) - sum_u plogp(p_u)
+//		+ sum_c plogp(q_c + p_c),
+//	where p_c is the sum of p_u over c. Nodes with zero degree have no flow
+//	and do not change L, and neither do empty modules.
+type InfomapQuality struct {
+	// -sum_u plogp(p_u) over the nodes of the original model. It does not
+	// depend on the modules, and is kept by Aggregate so that the quality is
+	// the same on the aggregated model.
+	nodeEntropy float64
+	ConcurrenceModel
+}
+
+func (qm InfomapQuality) GetNeighbors(u int) map[int]float64 {
+	return qm.concurrences[u]
+}
+
+// =============================================================================
+// func NewInfomapQuality
+// brief description: create a new InfomapQuality
+func NewInfomapQuality(cm ConcurrenceModel) InfomapQuality {
+	nodeEntropy := 0.0
+	if cm.sumConcurrences > 0.0 {
+		for u := 0; u < cm.n; u++ {
+			nodeEntropy -= plogp(cm.sumConcurrencesOf[u] / cm.sumConcurrences)
+		}
+	}
+	return InfomapQuality{
+		nodeEntropy:      nodeEntropy,
+		ConcurrenceModel: cm,
+	}
+}
+
+// =============================================================================
+// func (qm InfomapQuality) Aggregate
+// note:
+//	The aggregated model keeps the volumes of the communities as the degrees of
+//	its nodes and the weights between them, so the flows of modules are the
+//	same on it. The node entropy of the original model is kept as well.
+func (qm InfomapQuality) Aggregate(communities []map[int]bool) QualityModel {
+	return QualityModel(InfomapQuality{qm.nodeEntropy, qm.ConcurrenceModel.Aggregate(communities)})
+}
+
+// =============================================================================
+// func plogp
+// brief description: x log2(x), with plogp(0) = 0
+func plogp(x float64) float64 {
+	if x <= 0.0 {
+		return 0.0
+	}
+	return x * math.Log2(x)
+}
+
+// =============================================================================
+// func (qm InfomapQuality) getExternalWeight
+// brief description: get the weight from a node to all other nodes. It is
+//	sumConcurrencesOf[u] on an original model, but smaller on an aggregated
+//	one, where sumConcurrencesOf[u] includes the weight inside u.
+func (qm InfomapQuality) getExternalWeight(u int) float64 {
+	result := 0.0
+	for j, weightUJ := range qm.concurrences[u] {
+		if j != u {
+			result += weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (qm InfomapQuality) getModuleFlows
+// brief description: get the flows of the modules
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	output 1: the cut of each community, i.e. 2m times its exit flow
+//	output 2: the volume of each community, i.e. 2m times its visit rate
+//	output 3: the total of output 1
+func (qm InfomapQuality) getModuleFlows(communities []map[int]bool) ([]float64, []float64, float64) {
+	cuts := make([]float64, len(communities))
+	volumes := make([]float64, len(communities))
+	totalCut := 0.0
+	for idxC, c := range communities {
+		for i, _ := range c {
+			volumes[idxC] += qm.sumConcurrencesOf[i]
+			for j, weightIJ := range qm.concurrences[i] {
+				if i != j && !c[j] {
+					cuts[idxC] += weightIJ * float64(qm.cardinalities[i]*qm.cardinalities[j])
+				}
+			}
+		}
+		totalCut += cuts[idxC]
+	}
+	return cuts, volumes, totalCut
+}
+
+// =============================================================================
+// func (qm InfomapQuality) getDeltaMapEquation
+// brief description: get the change of the map equation when a node moves
+//	between two modules
+// input:
+//	ku: sumConcurrencesOf[u]
+//	externalU: the weight from u to all other nodes
+//	weightToOld, weightToNew: the weights from u to the other nodes of oldCu
+//		and to newCu
+//	cutOld, volumeOld: the cut and the volume of oldCu, u included
+//	cutNew, volumeNew: the cut and the volume of newCu
+//	totalCut: the total cut of all modules
+// output:
+//	the change of L, in bits
+func (qm InfomapQuality) getDeltaMapEquation(ku, externalU, weightToOld, weightToNew,
+	cutOld, volumeOld, cutNew, volumeNew, totalCut float64) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: update the cuts and the volumes. The edges from u to oldCu become
+	// cut, while those to the rest of the graph stop being cut by oldCu, and
+	// conversely for newCu.
+	newCutOld := cutOld - externalU + 2.0*weightToOld
+	newCutNew := cutNew + externalU - 2.0*weightToNew
+	newTotalCut := totalCut + (newCutOld - cutOld) + (newCutNew - cutNew)
+	newVolumeOld := volumeOld - ku
+	newVolumeNew := volumeNew + ku
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the change of the terms of L depending on the two
+	// modules, with all flows divided by 2m
+	m2 := qm.sumConcurrences
+	result := plogp(newTotalCut/m2) - plogp(totalCut/m2)
+	result -= 2.0 * (plogp(newCutOld/m2) + plogp(newCutNew/m2) - plogp(cutOld/m2) - plogp(cutNew/m2))
+	result += plogp((newCutOld+newVolumeOld)/m2) + plogp((newCutNew+newVolumeNew)/m2) -
+		plogp((cutOld+volumeOld)/m2) - plogp((cutNew+volumeNew)/m2)
+	return result
+}
+
+// =============================================================================
+// func (qm InfomapQuality) Quality
+// brief description: this implements Quality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+// output:
+//	the value of InfomapQuality, i.e. minus the map equation
+func (qm InfomapQuality) Quality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: a model without any weight has no walk to describe
+	if qm.sumConcurrences <= 0.0 {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the map equation from the flows of the modules
+	cuts, volumes, totalCut := qm.getModuleFlows(communities)
+	m2 := qm.sumConcurrences
+	result := plogp(totalCut/m2) + qm.nodeEntropy
+	for idxC, _ := range communities {
+		result += plogp((cuts[idxC]+volumes[idxC])/m2) - 2.0*plogp(cuts[idxC]/m2)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return -result
+}
+
+// =============================================================================
+// func (qm InfomapQuality) DeltaQuality
+// brief description: this implements DeltaQuality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+//	u: a node ID, 0 <= u < n.
+//	oldCu: the ID of the cluster u currently locates in.
+//	newCu: the ID of the cluster u wants to move in.
+// output:
+//	The change amount of InfomapQuality.
+// note:
+//	The total exit flow depends on all modules, so this takes O(m). Louvain
+//	uses the cached version, which takes O(degree of u).
+func (qm InfomapQuality) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check whether oldCu and newCu are the same one.
+	// no change if oldCu == newCu
+	if oldCu == newCu || qm.sumConcurrences <= 0.0 {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the flows of the modules and the weights from u to them
+	cuts, volumes, totalCut := qm.getModuleFlows(communities)
+	weightToOld := 0.0
+	weightToNew := 0.0
+	for j, weightUJ := range qm.concurrences[u] {
+		if j == u {
+			continue
+		}
+		weight := weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+		if communities[oldCu][j] {
+			weightToOld += weight
+		} else if communities[newCu][j] {
+			weightToNew += weight
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return -qm.getDeltaMapEquation(qm.sumConcurrencesOf[u], qm.getExternalWeight(u),
+		weightToOld, weightToNew, cuts[oldCu], volumes[oldCu], cuts[newCu], volumes[newCu],
+		totalCut)
+}
@@ -0,0 +1,49 @@
+package ConcurrenceBasedClustering
+
+import (
+	"testing"
+)
+
+func TestInfomapRecoversRingOfCliquesThatModularityMerges(t *testing.T) {
+	// the resolution limit: on a ring of 30 cliques of 5, merging the cliques
+	// in pairs has a larger Modularity than the cliques themselves
+	const k = 30
+	cm := ringOfCliques(t, k, 5)
+	cliques := make([]map[int]bool, k)
+	pairs := make([]map[int]bool, k/2)
+	for u := 0; u < cm.n; u++ {
+		if cliques[u/5] == nil {
+			cliques[u/5] = map[int]bool{}
+		}
+		if pairs[u/10] == nil {
+			pairs[u/10] = map[int]bool{}
+		}
+		cliques[u/5][u] = true
+		pairs[u/10][u] = true
+	}
+	modularity := NewModularity(1.0, cm)
+	infomap := NewInfomapQuality(cm)
+	if modularity.Quality(pairs) <= modularity.Quality(cliques) {
+		t.Fatalf("Modularity of the pairs = %v <= %v of the cliques",
+			modularity.Quality(pairs), modularity.Quality(cliques))
+	}
+	if infomap.Quality(pairs) >= infomap.Quality(cliques) {
+		t.Fatalf("InfomapQuality of the pairs = %v >= %v of the cliques",
+			infomap.Quality(pairs), infomap.Quality(cliques))
+	}
+
+	opts := ClusteringOptions{MaxIters: 100, Seed: 1}
+	levels, err := LouvainHierarchyWithOptions(infomap, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, levels[len(levels)-1], cliques)
+	levels, err = LouvainHierarchyWithOptions(modularity, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels[len(levels)-1]) >= k {
+		t.Fatalf("Modularity finds %d communities, want the cliques merged",
+			len(levels[len(levels)-1]))
+	}
+}
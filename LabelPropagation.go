@@ -0,0 +1,193 @@
+package ConcurrenceBasedClustering
+
+import (
+	"sort"
+)
+
+// =============================================================================
+// func (cm ConcurrenceModel) getGreedyColoring
+// brief description: color the concurrence graph so that no two concurring
+//	nodes have the same color. Nodes are colored greedily with the smallest
+//	color unused by their neighbors, in breadth-first order from the smallest
+//	uncolored node, visiting the neighbors of each node in ascending order, so
+//	that the coloring does not depend on the iteration order of maps.
+// output:
+//	output 1: the nodes of each color class, each in ascending order
+//	output 2: the color of each node
+// note:
+//	In breadth-first order, the neighbors colored before a node are all in the
+//	previous layer, so a bipartite graph gets exactly 2 colors. Other graphs
+//	get as many colors as the greedy scheme needs, at most the maximum degree
+//	plus one.
+func (cm ConcurrenceModel) getGreedyColoring() ([][]int, []int) {
+	// -------------------------------------------------------------------------
+	// step 1: color the nodes in breadth-first order
+	colors := make([]int, cm.n)
+	for u := 0; u < cm.n; u++ {
+		colors[u] = -1
+	}
+	numColors := 0
+	for root := 0; root < cm.n; root++ {
+		if colors[root] >= 0 {
+			continue
+		}
+		colors[root] = 0
+		queue := []int{root}
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			neighbors := make([]int, 0, len(cm.concurrences[u]))
+			for v, _ := range cm.concurrences[u] {
+				if v != u && colors[v] < 0 {
+					neighbors = append(neighbors, v)
+				}
+			}
+			sort.Ints(neighbors)
+			for _, v := range neighbors {
+				if colors[v] >= 0 {
+					continue
+				}
+
+				// (1.1) find the smallest color unused by v's neighbors
+				used := map[int]bool{}
+				for w, _ := range cm.concurrences[v] {
+					if w != v && colors[w] >= 0 {
+						used[colors[w]] = true
+					}
+				}
+				color := 0
+				for used[color] {
+					color++
+				}
+				colors[v] = color
+				queue = append(queue, v)
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: collect the color classes
+	for u := 0; u < cm.n; u++ {
+		if colors[u]+1 > numColors {
+			numColors = colors[u] + 1
+		}
+	}
+	classes := make([][]int, numColors)
+	for u := 0; u < cm.n; u++ {
+		classes[colors[u]] = append(classes[colors[u]], u)
+	}
+	return classes, colors
+}
+
+// =============================================================================
+// func getTieBreakKey
+// brief description: a pseudo-random key for breaking a tie between labels.
+// input:
+//	u: the node being updated
+//	label: a candidate label
+//	iter: the round
+// output:
+//	a key that looks random, but depends only on the inputs.
+// note:
+//	Breaking ties in favor of the smallest label floods the graph: in the first
+//	round, every neighbor label ties, and the small labels win everywhere,
+//	merging neighboring groups. A key that varies with the node and the round
+//	acts like a random choice, while keeping the result reproducible.
+func getTieBreakKey(u, label, iter int) uint64 {
+	x := uint64(u)*0x9e3779b97f4a7c15 ^ uint64(label)*0xbf58476d1ce4e5b9 ^
+		uint64(iter)*0x94d049bb133111eb
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getDominantLabel
+// brief description: find the label with the largest total concurrence among
+//	the neighbors of a node.
+// input:
+//	u: a node ID
+//	labels: the label of each node
+//	iter: the round, used to break ties
+// output:
+//	the dominant label. Ties are broken in favor of the current label of u,
+//	then of the smallest key from getTieBreakKey. A node without neighbors
+//	keeps its label.
+func (cm ConcurrenceModel) getDominantLabel(u int, labels []int, iter int) int {
+	weights := map[int]float64{}
+	for v, weightUV := range cm.concurrences[u] {
+		if v != u {
+			weights[labels[v]] += weightUV * float64(cm.cardinalities[v])
+		}
+	}
+	best := labels[u]
+	bestWeight := weights[best]
+	for label, weight := range weights {
+		if weight > bestWeight || (weight == bestWeight && best != labels[u] &&
+			getTieBreakKey(u, label, iter) < getTieBreakKey(u, best, iter)) {
+			best = label
+			bestWeight = weight
+		}
+	}
+	return best
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) SemiSyncLabelPropagation
+// brief description: semi-synchronous label propagation (Cordasco and Gargano).
+//	Every node starts with its own label. The graph is colored so that no two
+//	concurring nodes share a color, and each round updates the color classes
+//	one after another: all nodes of a class take the dominant label among
+//	their neighbors at the same time, weighted by concurrences.
+// input:
+//	maxIter: the maximum number of rounds.
+// output:
+//	the communities, i.e. the nodes of each final label, in canonical order.
+// note:
+//	Synchronous label propagation can oscillate forever between two states,
+//	e.g. on a bipartite graph, where the two sides swap their labels in every
+//	round. Here, the nodes updated together never concur, so each update
+//	sees the labels its neighbors have just taken, exactly as a sequential
+//	update would. Together with keeping the current label on ties, this rules
+//	out such oscillations, and the rounds stop as soon as no label changes.
+//	The nodes of one class are updated in parallel.
+func (cm ConcurrenceModel) SemiSyncLabelPropagation(maxIter int) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: color the graph and give each node its own label
+	classes, _ := cm.getGreedyColoring()
+	labels := make([]int, cm.n)
+	for u := 0; u < cm.n; u++ {
+		labels[u] = u
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: update the color classes in turn until no label changes
+	for iter := 0; iter < maxIter; iter++ {
+		numChanges := 0
+		for _, class := range classes {
+			// (2.1) compute the new labels of the class from the current ones
+			newLabels := make([]int, len(class))
+			parallelFor(len(class), NumWorkers, func(i int) {
+				newLabels[i] = cm.getDominantLabel(class[i], labels, iter)
+			})
+
+			// (2.2) apply them at once
+			for i, u := range class {
+				if newLabels[i] != labels[u] {
+					labels[u] = newLabels[i]
+					numChanges++
+				}
+			}
+		}
+		if numChanges == 0 {
+			break
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the communities
+	return canonicalizeCommunities(PartitionFromAssignment(labels), nil)
+}
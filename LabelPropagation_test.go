@@ -0,0 +1,60 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// =============================================================================
+// func assertLabelPropagationConverges
+// brief description: check that SemiSyncLabelPropagation stops changing after
+//	a few rounds, and that its result is a fixed point of the updates
+// output:
+//	the communities
+func assertLabelPropagationConverges(t *testing.T, cm ConcurrenceModel, maxRounds int,
+) []map[int]bool {
+	result := cm.SemiSyncLabelPropagation(1000)
+	for maxIter := maxRounds; maxIter <= maxRounds+20; maxIter++ {
+		communities := cm.SemiSyncLabelPropagation(maxIter)
+		if !reflect.DeepEqual(communities, result) {
+			t.Fatalf("maxIter = %d gives %v, maxIter = 1000 gives %v", maxIter, communities,
+				result)
+		}
+	}
+	labels, err := CommunitiesToLabels(result, cm.GetN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for u := 0; u < cm.GetN(); u++ {
+		if label := cm.getDominantLabel(u, labels, 0); label != labels[u] {
+			t.Fatalf("node %d in community %d would move to %d", u, labels[u], label)
+		}
+	}
+	return result
+}
+
+func TestSemiSyncLabelPropagationOnBipartiteGraph(t *testing.T) {
+	// on the complete bipartite graph K(4, 4), synchronous label propagation
+	// swaps the labels of the two sides forever
+	edges := []Edge{}
+	for u := 0; u < 4; u++ {
+		for v := 4; v < 8; v++ {
+			edges = append(edges, Edge{u, v, 1})
+		}
+	}
+	cm := newTestModel(t, edges)
+	classes, _ := cm.getGreedyColoring()
+	if !reflect.DeepEqual(classes, [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}}) {
+		t.Fatalf("color classes = %v, want the two sides", classes)
+	}
+	communities := assertLabelPropagationConverges(t, cm, 3)
+	assertSamePartition(t, communities, []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true}})
+}
+
+func TestSemiSyncLabelPropagationRecoversPlantedPartition(t *testing.T) {
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 4, 20, 0.5, 0.01)
+	communities := assertLabelPropagationConverges(t, cm, 10)
+	assertSamePartition(t, communities, truth)
+}
@@ -0,0 +1,220 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// =============================================================================
+// struct LabeledConcurrenceModel
+// brief description: This is a ConcurrenceModel whose nodes carry string
+//	labels. It maintains the bidirectional mapping between labels and the
+//	dense node IDs used by the clustering algorithms.
+type LabeledConcurrenceModel struct {
+	ConcurrenceModel
+	labels []string
+	ids    map[string]int
+}
+
+// =============================================================================
+// func NewLabeledConcurrenceModel
+// brief description: create a new LabeledConcurrenceModel
+// input:
+//	labels: the labels of nodes 0, 1, ... in this order. It may be nil. Labels
+//		must not be duplicated.
+//	edges: the concurrences between labels. Each pair may be given in either
+//		or both directions, but when given in both directions, the two weights
+//		must be the same. Labels not in the input labels are appended to them
+//		in sorted order.
+// output:
+//	output 1: the new LabeledConcurrenceModel
+//	output 2: an error if the input is invalid, nil otherwise
+func NewLabeledConcurrenceModel(labels []string, edges map[string]map[string]float64,
+) (LabeledConcurrenceModel, error) {
+	// -------------------------------------------------------------------------
+	// step 1: map the input labels to node IDs
+	lm := LabeledConcurrenceModel{
+		ConcurrenceModel: newConcurrenceModel([]map[int]float64{}, []int{}),
+		labels:           []string{},
+		ids:              map[string]int{},
+	}
+	for _, label := range labels {
+		_, exists := lm.ids[label]
+		if exists {
+			return LabeledConcurrenceModel{}, fmt.Errorf("duplicated label %q", label)
+		}
+		lm.AddLabel(label)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: map the labels only appearing in edges to node IDs
+	newLabels := map[string]bool{}
+	for a, edgesOfA := range edges {
+		newLabels[a] = true
+		for b, _ := range edgesOfA {
+			newLabels[b] = true
+		}
+	}
+	sortedNewLabels := []string{}
+	for label, _ := range newLabels {
+		_, exists := lm.ids[label]
+		if !exists {
+			sortedNewLabels = append(sortedNewLabels, label)
+		}
+	}
+	sort.Strings(sortedNewLabels)
+	for _, label := range sortedNewLabels {
+		lm.AddLabel(label)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: add the concurrences
+	for a, edgesOfA := range edges {
+		for b, weightAB := range edgesOfA {
+			if a == b {
+				return LabeledConcurrenceModel{}, fmt.Errorf("self concurrence of %q", a)
+			}
+			weightBA, exists := edges[b][a]
+			if exists {
+				if weightBA != weightAB {
+					return LabeledConcurrenceModel{}, fmt.Errorf(
+						"concurrence(%q, %q) = %v != concurrence(%q, %q) = %v",
+						a, b, weightAB, b, a, weightBA)
+				}
+				if a > b {
+					continue
+				}
+			}
+			if weightAB <= 0.0 {
+				return LabeledConcurrenceModel{}, fmt.Errorf(
+					"concurrence(%q, %q) = %v must be > 0", a, b, weightAB)
+			}
+			lm.AddConcurrenceLabeled(a, b, weightAB)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return lm, nil
+}
+
+// =============================================================================
+// func (lm LabeledConcurrenceModel) IDOf
+// brief description: get the node ID of a label
+// input:
+//	label: a node label
+// output:
+//	output 1: the node ID of the label
+//	output 2: whether the label exists
+func (lm LabeledConcurrenceModel) IDOf(label string) (int, bool) {
+	id, exists := lm.ids[label]
+	return id, exists
+}
+
+// =============================================================================
+// func (lm LabeledConcurrenceModel) LabelOf
+// brief description: get the label of a node ID
+// input:
+//	id: a node ID
+// output:
+//	the label of the node, or "" if the node has no label, e.g. a node added
+//	through the embedded ConcurrenceModel's AddConcurrence.
+func (lm LabeledConcurrenceModel) LabelOf(id int) string {
+	if id < 0 || id >= len(lm.labels) {
+		return ""
+	}
+	return lm.labels[id]
+}
+
+// =============================================================================
+// func (lm *LabeledConcurrenceModel) AddLabel
+// brief description: get the node ID of a label, adding a new isolated node for
+//	it if the label is new.
+// input:
+//	label: a node label
+// output:
+//	the node ID of the label
+func (lm *LabeledConcurrenceModel) AddLabel(label string) int {
+	id, exists := lm.ids[label]
+	if exists {
+		return id
+	}
+	for len(lm.labels) < lm.n {
+		lm.labels = append(lm.labels, "")
+	}
+	id = len(lm.labels)
+	lm.labels = append(lm.labels, label)
+	lm.ids[label] = id
+	lm.grow(id)
+	return id
+}
+
+// =============================================================================
+// func (lm *LabeledConcurrenceModel) AddConcurrenceLabeled
+// brief description: add an amount of concurrence between two labels, growing
+//	the mapping if any of them is new.
+// input:
+//	a, b: two different node labels
+//	delta: the amount of concurrence to be added, must be > 0.
+func (lm *LabeledConcurrenceModel) AddConcurrenceLabeled(a, b string, delta float64) {
+	lm.AddConcurrence(lm.AddLabel(a), lm.AddLabel(b), delta)
+}
+
+// =============================================================================
+// func (lm LabeledConcurrenceModel) CommunitiesAsLabels
+// brief description: translate communities of node IDs into labels
+// input:
+//	communities: a list of clusters, e.g. the output of a clustering method.
+// output:
+//	the labels of each community, sorted within each community.
+func (lm LabeledConcurrenceModel) CommunitiesAsLabels(communities []map[int]bool) [][]string {
+	result := make([][]string, len(communities))
+	for idxC, c := range communities {
+		labelsOfC := make([]string, 0, len(c))
+		for u, _ := range c {
+			labelsOfC = append(labelsOfC, lm.LabelOf(u))
+		}
+		sort.Strings(labelsOfC)
+		result[idxC] = labelsOfC
+	}
+	return result
+}
+
+// =============================================================================
+// func (lm LabeledConcurrenceModel) WriteGraphML
+// brief description: write the concurrence graph as GraphML with the labels of
+//	nodes as the "label" attribute. See ConcurrenceModel.WriteGraphML.
+func (lm LabeledConcurrenceModel) WriteGraphML(w io.Writer, communities []map[int]bool) error {
+	labels := make([]string, lm.n)
+	for u := 0; u < lm.n; u++ {
+		labels[u] = lm.LabelOf(u)
+	}
+	return lm.writeGraphML(w, communities, labels)
+}
+
+// =============================================================================
+// func LoadLabeledGraphML
+// brief description: read a LabeledConcurrenceModel from GraphML. The "label"
+//	node attribute is used as the label, falling back to the GraphML node ID.
+// input:
+//	r: the stream. See LoadGraphML.
+// output:
+//	output 1: the LabeledConcurrenceModel read from r.
+//	output 2: an error if the stream is invalid or labels are duplicated, nil
+//		otherwise
+func LoadLabeledGraphML(r io.Reader) (LabeledConcurrenceModel, error) {
+	cm, _, labels, err := readGraphML(r)
+	if err != nil {
+		return LabeledConcurrenceModel{}, err
+	}
+	ids := make(map[string]int, len(labels))
+	for u, label := range labels {
+		_, exists := ids[label]
+		if exists {
+			return LabeledConcurrenceModel{}, fmt.Errorf("duplicated label %q", label)
+		}
+		ids[label] = u
+	}
+	return LabeledConcurrenceModel{ConcurrenceModel: cm, labels: labels, ids: ids}, nil
+}
@@ -0,0 +1,75 @@
+package ConcurrenceBasedClustering
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLabeledConcurrenceModelRoundTrip(t *testing.T) {
+	// -------------------------------------------------------------------------
+	// step 1: insert the labels in an order unrelated to their IDs, with a
+	// label added before any of its edges
+	lm, err := NewLabeledConcurrenceModel([]string{"zed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm.AddConcurrenceLabeled("user-1000", "user-7", 1.0)
+	lm.AddConcurrenceLabeled("user-7", "user-42", 1.0)
+	lm.AddConcurrenceLabeled("user-42", "user-1000", 1.0)
+	lm.AddLabel("lonely")
+	lm.AddConcurrenceLabeled("b", "a", 1.0)
+	lm.AddConcurrenceLabeled("a", "zed", 1.0)
+	lm.AddConcurrenceLabeled("zed", "b", 1.0)
+	lm.AddConcurrenceLabeled("user-7", "a", 0.1)
+
+	// -------------------------------------------------------------------------
+	// step 2: labels and IDs map to each other
+	for _, label := range []string{"zed", "user-1000", "user-7", "user-42", "lonely", "b", "a"} {
+		id, exists := lm.IDOf(label)
+		if !exists || lm.LabelOf(id) != label {
+			t.Fatalf("IDOf(%q) = %d, %v and LabelOf = %q", label, id, exists, lm.LabelOf(id))
+		}
+	}
+	if lm.GetN() != 7 {
+		t.Fatalf("n = %d, want 7", lm.GetN())
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: communities of IDs map back to the labels
+	communities := lm.ConnectedComponents(0.5)
+	got := lm.CommunitiesAsLabels(communities)
+	want := [][]string{{"a", "b", "zed"}, {"user-1000", "user-42", "user-7"}, {"lonely"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CommunitiesAsLabels = %v, want %v", got, want)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: the model survives a GraphML round trip
+	var buf bytes.Buffer
+	if err := lm.WriteGraphML(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadLabeledGraphML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded.labels, lm.labels) || !reflect.DeepEqual(reloaded.ids, lm.ids) {
+		t.Fatalf("labels = %v, want %v", reloaded.labels, lm.labels)
+	}
+	assertSameModel(t, reloaded.ConcurrenceModel, lm.ConcurrenceModel)
+}
+
+func TestLabeledConcurrenceModelRejectsBadInput(t *testing.T) {
+	_, err := NewLabeledConcurrenceModel([]string{"a", "b", "a"}, nil)
+	if err == nil {
+		t.Fatal("duplicated labels are accepted")
+	}
+	_, err = NewLabeledConcurrenceModel(nil, map[string]map[string]float64{
+		"a": {"b": 1.0},
+		"b": {"a": 2.0},
+	})
+	if err == nil {
+		t.Fatal("asymmetric concurrences are accepted")
+	}
+}
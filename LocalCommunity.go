@@ -0,0 +1,439 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// =============================================================================
+// type LocalFitness
+// brief description: This is the fitness function LocalCommunity maximizes.
+type LocalFitness int
+
+const (
+	// minus the conductance of the community, i.e. -cut/min(volume, the volume
+	// of the complement)
+	LocalConductance LocalFitness = iota
+
+	// the local modularity R of Clauset, i.e. the fraction of the weight of
+	// the edges touching the boundary of the community that is inside it. The
+	// boundary is the members with neighbors outside.
+	LocalModularityR
+)
+
+// =============================================================================
+// struct LocalOptions
+// brief description: This is the options of LocalCommunity.
+type LocalOptions struct {
+	// Fitness is the fitness function. The default is LocalConductance.
+	Fitness LocalFitness
+
+	// MaxSize, if > 0, is the maximum size of the community, counting the
+	// cardinalities of its members.
+	MaxSize int
+
+	// AllowRemoval lets the expansion also remove members other than the
+	// seeds after each addition, while that improves the fitness.
+	AllowRemoval bool
+}
+
+// =============================================================================
+// struct localCommunity
+// brief description: This is the state of the expansion of LocalCommunity.
+type localCommunity struct {
+	cm      ConcurrenceModel
+	members map[int]bool
+	size    int
+
+	// the sum of sumConcurrencesOf of the members, and the weight between the
+	// members and the other nodes
+	volume float64
+	cut    float64
+
+	// the weight from each node adjacent to the community to the members
+	// other than itself
+	weightsToMembers map[int]float64
+}
+
+// =============================================================================
+// func (lc *localCommunity) getWeight
+// brief description: the concurrence between two nodes weighted by their
+//	cardinalities, as in sumConcurrencesOf
+func (lc *localCommunity) getWeight(u, v int, weightUV float64) float64 {
+	return weightUV * float64(lc.cm.cardinalities[u]*lc.cm.cardinalities[v])
+}
+
+// =============================================================================
+// func (lc *localCommunity) update
+// brief description: add a node to the community or remove a member from it
+// input:
+//	u: the node
+//	add: true to add u, false to remove it
+func (lc *localCommunity) update(u int, add bool) {
+	sign := 1.0
+	if !add {
+		sign = -1.0
+	}
+	lc.volume += sign * lc.cm.sumConcurrencesOf[u]
+	lc.cut += sign * (lc.cm.sumConcurrencesOf[u] - 2.0*lc.weightsToMembers[u])
+	lc.size += int(sign) * lc.cm.cardinalities[u]
+	if add {
+		lc.members[u] = true
+	} else {
+		delete(lc.members, u)
+	}
+	for v, weightUV := range lc.cm.concurrences[u] {
+		if v != u {
+			lc.weightsToMembers[v] += sign * lc.getWeight(u, v, weightUV)
+		}
+	}
+}
+
+// =============================================================================
+// func (lc *localCommunity) getFitnessAfter
+// brief description: compute the fitness of the community after adding or
+//	removing a node, without changing the community
+// input:
+//	u: the node, or -1 for the community as it is
+//	add: true to add u, false to remove it
+//	fitness: the fitness function
+// output:
+//	the fitness
+func (lc *localCommunity) getFitnessAfter(u int, add bool, fitness LocalFitness) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: get the volume and the cut after the change
+	volume := lc.volume
+	cut := lc.cut
+	isMember := func(v int) bool {
+		return lc.members[v]
+	}
+	if u >= 0 {
+		sign := 1.0
+		if !add {
+			sign = -1.0
+		}
+		volume += sign * lc.cm.sumConcurrencesOf[u]
+		cut += sign * (lc.cm.sumConcurrencesOf[u] - 2.0*lc.weightsToMembers[u])
+		isMember = func(v int) bool {
+			if v == u {
+				return add
+			}
+			return lc.members[v]
+		}
+	}
+	cut = math.Max(0.0, cut)
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the fitness
+	switch fitness {
+	case LocalConductance:
+		minVolume := math.Min(volume, lc.cm.sumConcurrences-volume)
+		if minVolume <= 0.0 {
+			return 0.0
+		}
+		return -cut / minVolume
+	case LocalModularityR:
+		// (2.1) find the members on the boundary, i.e. with weights outside
+		members := make([]int, 0, len(lc.members)+1)
+		for v, _ := range lc.members {
+			if isMember(v) {
+				members = append(members, v)
+			}
+		}
+		if u >= 0 && add {
+			members = append(members, u)
+		}
+		isBoundary := make(map[int]bool, len(members))
+		for _, v := range members {
+			for w, _ := range lc.cm.concurrences[v] {
+				if w != v && !isMember(w) {
+					isBoundary[v] = true
+					break
+				}
+			}
+		}
+
+		// (2.2) sum the internal weight touching the boundary
+		internal := 0.0
+		for _, v := range members {
+			for w, weightVW := range lc.cm.concurrences[v] {
+				if w <= v || !isMember(w) {
+					continue
+				}
+				if isBoundary[v] || isBoundary[w] {
+					internal += lc.getWeight(v, w, weightVW)
+				}
+			}
+		}
+		if internal+cut <= 0.0 {
+			return 1.0
+		}
+		return internal / (internal + cut)
+	}
+	log.Fatalln(fmt.Sprintf("unknown local fitness %d", fitness))
+	return 0.0
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) LocalCommunity
+// brief description: find the community around some seeds without clustering
+//	the whole model, by greedily adding the neighbor of the community that
+//	improves a local fitness function the most.
+// input:
+//	seeds: the seeds, at least one, all within [0, n). They are expanded
+//		jointly and are never removed.
+//	opts: the options.
+// output:
+//	the community, seeds included
+// note:
+//	The expansion stops when no neighbor improves the fitness, or when all
+//	neighbors would make the community larger than opts.MaxSize. Weights are
+//	concurrences weighted by the cardinalities of both ends, as in
+//	CommunityStats. Ties are broken by smaller node IDs. Each step scans the
+//	neighbors of the community, and LocalModularityR rescans the edges of
+//	the community for each of them, so it is meant for small communities.
+//	Since each step strictly improves the fitness, the expansion terminates
+//	even with opts.AllowRemoval.
+func (cm ConcurrenceModel) LocalCommunity(seeds []int, opts LocalOptions) map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and start from the seeds
+	if len(seeds) == 0 {
+		log.Fatalln("at least one seed is needed in LocalCommunity")
+	}
+	lc := &localCommunity{
+		cm:               cm,
+		members:          map[int]bool{},
+		weightsToMembers: map[int]float64{},
+	}
+	isSeed := map[int]bool{}
+	for _, seed := range seeds {
+		if seed < 0 || seed >= cm.n {
+			log.Fatalln(fmt.Sprintf("seed %d is out of range [0, %d)", seed, cm.n))
+		}
+		if !isSeed[seed] {
+			isSeed[seed] = true
+			lc.update(seed, true)
+		}
+	}
+	fitness := lc.getFitnessAfter(-1, true, opts.Fitness)
+
+	// -------------------------------------------------------------------------
+	// step 2: add the best neighbor until none improves the fitness
+	for {
+		// (2.1) find the best neighbor within the size cap
+		best := -1
+		bestFitness := fitness
+		for v, _ := range lc.weightsToMembers {
+			if lc.members[v] {
+				continue
+			}
+			if opts.MaxSize > 0 && lc.size+cm.cardinalities[v] > opts.MaxSize {
+				continue
+			}
+			fitnessV := lc.getFitnessAfter(v, true, opts.Fitness)
+			if fitnessV > bestFitness || (fitnessV == bestFitness && best >= 0 && v < best) {
+				best = v
+				bestFitness = fitnessV
+			}
+		}
+		if best < 0 {
+			break
+		}
+		lc.update(best, true)
+		fitness = bestFitness
+
+		// (2.2) remove the worst members while that improves the fitness
+		for opts.AllowRemoval {
+			worst := -1
+			worstFitness := fitness
+			for u, _ := range lc.members {
+				if isSeed[u] {
+					continue
+				}
+				fitnessU := lc.getFitnessAfter(u, false, opts.Fitness)
+				if fitnessU > worstFitness || (fitnessU == worstFitness && worst >= 0 && u < worst) {
+					worst = u
+					worstFitness = fitnessU
+				}
+			}
+			if worst < 0 {
+				break
+			}
+			lc.update(worst, false)
+			fitness = worstFitness
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return lc.members
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) PersonalizedPageRank
+// brief description: approximate the personalized PageRank of the nodes by
+//	the push algorithm of Andersen, Chung and Lang, which only touches the
+//	nodes around the seeds.
+// input:
+//	seeds: the teleport distribution, i.e. a non-negative weight for each
+//		seed. It is normalized to sum 1.
+//	alpha: the teleport probability, within (0, 1). The smaller it is, the
+//		farther the walks go from the seeds.
+//	tol: the tolerance, > 0. The push stops when the residual of every node
+//		u is below tol*d(u), where d(u) is its degree.
+// output:
+//	the PageRank of each node. It sums to at most 1, and for each node it is
+//	at most tol*d(u) below the exact value.
+// note:
+//	The random walk moves along concurrences weighted by the cardinalities of
+//	both ends, so d(u) is sumConcurrencesOf[u]. The walk cannot leave an
+//	isolated node, so such a node keeps all its residual. It takes
+//	O(1/(tol*alpha)) pushes regardless of n.
+func (cm ConcurrenceModel) PersonalizedPageRank(seeds map[int]float64, alpha float64,
+	tol float64) []float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and initialize the residuals
+	if alpha <= 0.0 || alpha >= 1.0 {
+		log.Fatalln(fmt.Sprintf("alpha = %v must be within (0, 1)", alpha))
+	}
+	if tol <= 0.0 {
+		log.Fatalln(fmt.Sprintf("tol = %v must be > 0", tol))
+	}
+	sumSeeds := 0.0
+	for seed, weight := range seeds {
+		if seed < 0 || seed >= cm.n {
+			log.Fatalln(fmt.Sprintf("seed %d is out of range [0, %d)", seed, cm.n))
+		}
+		if weight < 0.0 {
+			log.Fatalln(fmt.Sprintf("the weight %v of seed %d must be >= 0", weight, seed))
+		}
+		sumSeeds += weight
+	}
+	if sumSeeds <= 0.0 {
+		log.Fatalln("the weights of seeds must sum to > 0")
+	}
+	pageRanks := make([]float64, cm.n)
+	residuals := map[int]float64{}
+	queue := []int{}
+	inQueue := map[int]bool{}
+	for seed, weight := range seeds {
+		if weight > 0.0 {
+			residuals[seed] = weight / sumSeeds
+			queue = append(queue, seed)
+			inQueue[seed] = true
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: push the residuals above the tolerance
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		delete(inQueue, u)
+		residualU := residuals[u]
+		degreeU := cm.sumConcurrencesOf[u]
+		if degreeU <= 0.0 {
+			pageRanks[u] += residualU
+			delete(residuals, u)
+			continue
+		}
+		if residualU < tol*degreeU {
+			continue
+		}
+		pageRanks[u] += alpha * residualU
+		delete(residuals, u)
+		for v, weightUV := range cm.concurrences[u] {
+			if v == u {
+				continue
+			}
+			weight := weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+			residuals[v] += (1.0 - alpha) * residualU * weight / degreeU
+			if !inQueue[v] && residuals[v] >= tol*cm.sumConcurrencesOf[v] {
+				queue = append(queue, v)
+				inQueue[v] = true
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return pageRanks
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) SweepCut
+// brief description: find the community of lowest conductance among the
+//	prefixes of the nodes ordered by score/degree, e.g. with the scores of
+//	PersonalizedPageRank, which gives the local clustering of Andersen, Chung
+//	and Lang.
+// input:
+//	scores: the score of each node. Only the nodes with positive scores and
+//		degrees are swept.
+// output:
+//	output 1: the prefix with the lowest conductance, empty if no node is
+//		swept
+//	output 2: its conductance, as in CommunityStats, or 1 if no node is swept
+// note:
+//	Degrees are sumConcurrencesOf, as in PersonalizedPageRank. Ties of
+//	score/degree are broken by smaller node IDs. The prefixes are evaluated
+//	incrementally, so it takes O(k log(k) + the sum of their degrees) for k
+//	swept nodes.
+func (cm ConcurrenceModel) SweepCut(scores []float64) (map[int]bool, float64) {
+	// -------------------------------------------------------------------------
+	// step 1: order the nodes by score/degree
+	if len(scores) != cm.n {
+		log.Fatalln(fmt.Sprintf("len(scores) = %d != n = %d", len(scores), cm.n))
+	}
+	nodes := []int{}
+	for u := 0; u < cm.n; u++ {
+		if scores[u] > 0.0 && cm.sumConcurrencesOf[u] > 0.0 {
+			nodes = append(nodes, u)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		ratioI := scores[nodes[i]] / cm.sumConcurrencesOf[nodes[i]]
+		ratioJ := scores[nodes[j]] / cm.sumConcurrencesOf[nodes[j]]
+		if ratioI != ratioJ {
+			return ratioI > ratioJ
+		}
+		return nodes[i] < nodes[j]
+	})
+
+	// -------------------------------------------------------------------------
+	// step 2: evaluate the conductance of each prefix
+	inPrefix := map[int]bool{}
+	volume := 0.0
+	cut := 0.0
+	bestLength := 0
+	bestConductance := 1.0
+	for idx, u := range nodes {
+		weightToPrefix := 0.0
+		for v, weightUV := range cm.concurrences[u] {
+			if v != u && inPrefix[v] {
+				weightToPrefix += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+			}
+		}
+		inPrefix[u] = true
+		volume += cm.sumConcurrencesOf[u]
+		cut += cm.sumConcurrencesOf[u] - 2.0*weightToPrefix
+		minVolume := math.Min(volume, cm.sumConcurrences-volume)
+		if minVolume <= 0.0 {
+			continue
+		}
+		conductance := math.Max(0.0, cut) / minVolume
+		if bestLength == 0 || conductance < bestConductance {
+			bestLength = idx + 1
+			bestConductance = conductance
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the best prefix
+	result := make(map[int]bool, bestLength)
+	for _, u := range nodes[:bestLength] {
+		result[u] = true
+	}
+	return result, bestConductance
+}
@@ -0,0 +1,70 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// =============================================================================
+// func getF1Score
+// brief description: the F1 score of a community found for a true one
+func getF1Score(found, truth map[int]bool) float64 {
+	numShared := 0
+	for u, _ := range found {
+		if truth[u] {
+			numShared++
+		}
+	}
+	return 2.0 * float64(numShared) / float64(len(found)+len(truth))
+}
+
+func TestLocalCommunityRecoversPlantedCommunity(t *testing.T) {
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 30, 0.3, 0.02)
+	for _, fitness := range []LocalFitness{LocalConductance, LocalModularityR} {
+		for _, allowRemoval := range []bool{false, true} {
+			opts := LocalOptions{Fitness: fitness, AllowRemoval: allowRemoval}
+			for g, group := range truth {
+				for _, seeds := range [][]int{{g * 30}, {g*30 + 7}, {g*30 + 1, g*30 + 2}} {
+					community := cm.LocalCommunity(seeds, opts)
+					if f1 := getF1Score(community, group); f1 < 0.9 {
+						t.Fatalf("%+v, seeds %v: F1 = %v, community = %v", opts, seeds, f1,
+							community)
+					}
+				}
+			}
+		}
+	}
+
+	// the expansion stops at the cap, mostly within the planted community
+	community := cm.LocalCommunity([]int{0}, LocalOptions{MaxSize: 10})
+	if len(community) != 10 || !community[0] || getF1Score(community, truth[0]) < 0.4 {
+		t.Fatalf("MaxSize = 10: community = %v", community)
+	}
+}
+
+func TestPersonalizedPageRankSweepCutOfBarbell(t *testing.T) {
+	// each bell is a clique of 10 nodes with the volume 10*9 plus the bridge
+	cm := barbell(t, 10)
+	bells := []map[int]bool{{}, {}}
+	for u := 0; u < cm.n; u++ {
+		bells[u/10][u] = true
+	}
+	for _, seeds := range []map[int]float64{{0: 1}, {9: 1}, {2: 1, 5: 3}, {15: 1}} {
+		scores := cm.PersonalizedPageRank(seeds, 0.15, 1e-6)
+		sumScores := 0.0
+		for _, score := range scores {
+			sumScores += score
+		}
+		if sumScores > 1.0+1e-12 || sumScores < 0.99 {
+			t.Fatalf("seeds %v: the scores sum to %v", seeds, sumScores)
+		}
+		community, conductance := cm.SweepCut(scores)
+		for seed, _ := range seeds {
+			assertSamePartition(t, []map[int]bool{community}, []map[int]bool{bells[seed/10]})
+		}
+		if math.Abs(conductance-1.0/91.0) > 1e-12 {
+			t.Fatalf("seeds %v: conductance = %v, want 1/91", seeds, conductance)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// const mclTolerance
+// brief description: MCL stops when no element of the matrix changes by more
+//	than this in an iteration.
+const mclTolerance = 1e-9
+
+// =============================================================================
+// func normalizeColumn
+// brief description: drop the elements of a column below a threshold and
+//	scale the rest to sum 1. The largest element is always kept.
+func normalizeColumn(column map[int]float64, pruneThreshold float64) map[int]float64 {
+	largest := -1
+	for i, x := range column {
+		if largest < 0 || x > column[largest] || (x == column[largest] && i < largest) {
+			largest = i
+		}
+	}
+	sum := 0.0
+	for i, x := range column {
+		if x < pruneThreshold && i != largest {
+			delete(column, i)
+		} else {
+			sum += x
+		}
+	}
+	for i, _ := range column {
+		column[i] /= sum
+	}
+	return column
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) MCL
+// brief description: the Markov Cluster algorithm of van Dongen, which
+//	alternates expansion, i.e. powers of the random walk matrix, and
+//	inflation, i.e. elementwise powers, until the walks are trapped in
+//	clusters.
+// input:
+//	expansion: the power of expansion, must be >= 2. 2 is the usual choice.
+//	inflation: the power of inflation, must be > 1. The larger it is, the
+//		smaller the clusters are; 2 is the usual choice.
+//	maxIter: the maximum number of iterations, must be > 0.
+//	pruneThreshold: after each inflation, the elements of a column below it
+//		are dropped, except the largest one, to keep the matrix sparse. 0
+//		keeps everything; 1e-4 is a common choice.
+// output:
+//	the clusters, ordered by their smallest members
+// note:
+//	The matrix is the column-normalized concurrences with a loop added to
+//	each node, weighing the largest concurrence of the node, or 1 for
+//	isolated nodes, as in the mcl program. Cardinalities are not used. The
+//	iterations stop when no element changes by more than mclTolerance. At
+//	the end, the nodes with diagonal elements above mclTolerance are the
+//	attractors, and each node is clustered with the attractors its column
+//	reaches with more than mclTolerance, so the clusters are the connected
+//	components of these links. Nodes that share attractors of different
+//	clusters, which is rare, join the clusters together.
+func (cm ConcurrenceModel) MCL(expansion int, inflation float64, maxIter int,
+	pruneThreshold float64) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and build the column-normalized matrix
+	n := cm.n
+	if expansion < 2 {
+		log.Fatalln(fmt.Sprintf("expansion = %d must be >= 2", expansion))
+	}
+	if inflation <= 1.0 {
+		log.Fatalln(fmt.Sprintf("inflation = %v must be > 1", inflation))
+	}
+	if maxIter <= 0 {
+		log.Fatalln(fmt.Sprintf("maxIter = %d must be > 0", maxIter))
+	}
+	columns := make([]map[int]float64, n)
+	for j := 0; j < n; j++ {
+		column := make(map[int]float64, len(cm.concurrences[j])+1)
+		loop := 0.0
+		for i, weightIJ := range cm.concurrences[j] {
+			if i != j && weightIJ > 0.0 {
+				column[i] = weightIJ
+				loop = math.Max(loop, weightIJ)
+			}
+		}
+		if loop == 0.0 {
+			loop = 1.0
+		}
+		column[j] = loop
+		columns[j] = normalizeColumn(column, 0.0)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: expand and inflate until convergence
+	for iter := 0; iter < maxIter; iter++ {
+		// (2.1) expand and inflate each column of the product
+		current := columns
+		next := make([]map[int]float64, n)
+		changes := make([]float64, n)
+		parallelFor(n, NumWorkers, func(j int) {
+			column := current[j]
+			for power := 1; power < expansion; power++ {
+				product := map[int]float64{}
+				for k, xKJ := range column {
+					for i, xIK := range current[k] {
+						product[i] += xIK * xKJ
+					}
+				}
+				column = product
+			}
+			for i, x := range column {
+				column[i] = math.Pow(x, inflation)
+			}
+			column = normalizeColumn(column, pruneThreshold)
+			for i, x := range column {
+				changes[j] = math.Max(changes[j], math.Abs(x-current[j][i]))
+			}
+			for i, x := range current[j] {
+				_, exists := column[i]
+				if !exists {
+					changes[j] = math.Max(changes[j], x)
+				}
+			}
+			next[j] = column
+		})
+		columns = next
+
+		// (2.2) stop if the matrix no longer changes
+		maxChange := 0.0
+		for j := 0; j < n; j++ {
+			maxChange = math.Max(maxChange, changes[j])
+		}
+		if maxChange <= mclTolerance {
+			break
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: link each node to the attractors it reaches. The elements not
+	// above mclTolerance are the remains of walks that have not yet vanished
+	// when the iterations stop, so they are taken as 0.
+	uf := newUnionFind(n)
+	for j := 0; j < n; j++ {
+		for i, x := range columns[j] {
+			if x > mclTolerance && columns[i][i] > mclTolerance {
+				uf.union(i, j)
+			}
+		}
+	}
+	communities, _ := uf.sets()
+	return communities
+}
@@ -0,0 +1,24 @@
+package ConcurrenceBasedClustering
+
+import (
+	"testing"
+)
+
+func TestMCLOfTwoCliques(t *testing.T) {
+	// two cliques of 6 nodes joined by a single edge, with and without weights
+	for _, weight := range []float64{1.0, 3.0} {
+		cm := barbell(t, 6)
+		if weight != 1.0 {
+			edges := append(cliqueEdges(0, 6, weight), cliqueEdges(6, 6, weight)...)
+			cm = newTestModel(t, append(edges, Edge{5, 6, 1.0}))
+		}
+		for _, inflation := range []float64{1.8, 2.0, 2.2} {
+			for _, pruneThreshold := range []float64{0.0, 1e-4} {
+				communities := cm.MCL(2, inflation, 100, pruneThreshold)
+				assertSamePartition(t, communities, []map[int]bool{
+					{0: true, 1: true, 2: true, 3: true, 4: true, 5: true},
+					{6: true, 7: true, 8: true, 9: true, 10: true, 11: true}})
+			}
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package ConcurrenceBasedClustering
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// =============================================================================
+// struct reachabilityItem
+// brief description: This is an item of the seed queue of OPTICS.
+type reachabilityItem struct {
+	pt           int
+	reachability float64
+}
+
+// =============================================================================
+// type reachabilityHeap
+// brief description: This is a min-heap of reachabilityItems that implements
+//	heap.Interface. Ties are broken by smaller point IDs.
+type reachabilityHeap []reachabilityItem
+
+func (h reachabilityHeap) Len() int {
+	return len(h)
+}
+
+func (h reachabilityHeap) Less(i, j int) bool {
+	if h[i].reachability != h[j].reachability {
+		return h[i].reachability < h[j].reachability
+	}
+	return h[i].pt < h[j].pt
+}
+
+func (h reachabilityHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *reachabilityHeap) Push(x interface{}) {
+	*h = append(*h, x.(reachabilityItem))
+}
+
+func (h *reachabilityHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getCoreDistances
+// brief description: compute the core distance of each point, i.e. the
+//	smallest radius whose neighborhood is dense.
+// input:
+//	minPts: the same as in DBScan.
+// output:
+//	the core distance of each point, +Inf if no neighborhood of it is dense.
+func (cm ConcurrenceModel) getCoreDistances(minPts int) []float64 {
+	coreDistances := make([]float64, cm.n)
+	for pt := 0; pt < cm.n; pt++ {
+		// (1) a point alone may be dense enough
+		density := cm.cardinalities[pt]
+		if density >= minPts {
+			coreDistances[pt] = 0.0
+			continue
+		}
+
+		// (2) otherwise, add neighbors from the nearest one
+		neighbors := make([]int, 0, len(cm.concurrences[pt]))
+		for neighbor, _ := range cm.concurrences[pt] {
+			if neighbor != pt {
+				neighbors = append(neighbors, neighbor)
+			}
+		}
+		sort.Slice(neighbors, func(i, j int) bool {
+			simI := cm.concurrences[pt][neighbors[i]]
+			simJ := cm.concurrences[pt][neighbors[j]]
+			if simI != simJ {
+				return simI > simJ
+			}
+			return neighbors[i] < neighbors[j]
+		})
+		coreDistances[pt] = math.Inf(1)
+		for _, neighbor := range neighbors {
+			density += cm.cardinalities[neighbor]
+			if density >= minPts {
+				coreDistances[pt] = 1.0 - cm.concurrences[pt][neighbor]
+				break
+			}
+		}
+	}
+	return coreDistances
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) OPTICS
+// brief description: This is an implementation of the OPTICS algorithm, which
+//	orders points so that DBScan clusters of any eps can be extracted from a
+//	single run, see ExtractDBScanClusters and ExtractXiClusters.
+// input:
+//	minPts: the same as in DBScan.
+// output:
+//	output 1: the cluster ordering of points. Each walk through the seed
+//		queue starts from the unvisited core point with the smallest ID, or
+//		from the unvisited point with the smallest ID when all core points
+//		are visited.
+//	output 2: the reachability distance of each point, +Inf for the points
+//		starting a walk.
+//	output 3: the core distance of each point, +Inf for non-core points.
+// note:
+//	Like DBScan, the concurrences are used directly as similarities, and the
+//	distance between two points is 1 - similarity. Points without concurrence
+//	are not neighbors at any distance.
+func (cm ConcurrenceModel) OPTICS(minPts int) ([]int, []float64, []float64) {
+	// -------------------------------------------------------------------------
+	// step 1: initialize auxiliary data structures
+	coreDistances := cm.getCoreDistances(minPts)
+	reachabilities := make([]float64, cm.n)
+	for pt := 0; pt < cm.n; pt++ {
+		reachabilities[pt] = math.Inf(1)
+	}
+	processed := make([]bool, cm.n)
+	order := make([]int, 0, cm.n)
+
+	// -------------------------------------------------------------------------
+	// step 2: walk from each unprocessed point through the seed queue. Walks
+	// start from core points first, so that border points are reached from
+	// their core points instead of being visited alone.
+	starts := make([]int, 0, cm.n)
+	for pt := 0; pt < cm.n; pt++ {
+		if !math.IsInf(coreDistances[pt], 1) {
+			starts = append(starts, pt)
+		}
+	}
+	for pt := 0; pt < cm.n; pt++ {
+		if math.IsInf(coreDistances[pt], 1) {
+			starts = append(starts, pt)
+		}
+	}
+	for _, start := range starts {
+		if processed[start] {
+			continue
+		}
+		seeds := &reachabilityHeap{{pt: start, reachability: math.Inf(1)}}
+		for seeds.Len() > 0 {
+			// (2.1) take the nearest seed, skipping outdated items
+			item := heap.Pop(seeds).(reachabilityItem)
+			pt := item.pt
+			if processed[pt] {
+				continue
+			}
+			processed[pt] = true
+			order = append(order, pt)
+
+			// (2.2) update the reachabilities of the neighbors of core points
+			coreDistance := coreDistances[pt]
+			if math.IsInf(coreDistance, 1) {
+				continue
+			}
+			for neighbor, similarity := range cm.concurrences[pt] {
+				if neighbor == pt || processed[neighbor] {
+					continue
+				}
+				reachability := math.Max(coreDistance, 1.0-similarity)
+				if reachability < reachabilities[neighbor] {
+					reachabilities[neighbor] = reachability
+					heap.Push(seeds, reachabilityItem{pt: neighbor, reachability: reachability})
+				}
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return order, reachabilities, coreDistances
+}
+
+// =============================================================================
+// func ExtractDBScanClusters
+// brief description: extract the DBScan clusters of a given eps from the
+//	output of OPTICS.
+// input:
+//	order: the cluster ordering of points.
+//	reachabilities: the reachability distance of each point.
+//	coreDistances: the core distance of each point.
+//	eps: the radius of neighborhood, the same as in DBScan.
+// output:
+//	A list of clusters, ordered by their smallest members. Like DBScan, points
+//	that are not density-reachable from any core point are singleton clusters.
+// note:
+//	This is the ExtractDBSCAN-Clustering of the OPTICS paper. The core points
+//	are clustered exactly as DBScan does, but a border point may be assigned
+//	to a different cluster, or, if it is visited before the core points of
+//	its cluster, be reported as a singleton cluster.
+func ExtractDBScanClusters(order []int, reachabilities, coreDistances []float64,
+	eps float64) []map[int]bool {
+	communities := []map[int]bool{}
+	var current map[int]bool
+	for _, pt := range order {
+		if reachabilities[pt] > eps {
+			if coreDistances[pt] <= eps {
+				current = map[int]bool{pt: true}
+				communities = append(communities, current)
+			} else {
+				current = nil
+				communities = append(communities, map[int]bool{pt: true})
+			}
+		} else if current != nil {
+			current[pt] = true
+		} else {
+			communities = append(communities, map[int]bool{pt: true})
+		}
+	}
+	return canonicalizeCommunities(communities, nil)
+}
+
+// =============================================================================
+// struct steepDownArea
+// brief description: This is a steep down area of a reachability plot, from
+//	which a cluster of ExtractXiClusters may start.
+type steepDownArea struct {
+	// the first and the last positions of the area in the cluster ordering
+	start int
+	end   int
+
+	// the maximum reachability between the end of the area and the current
+	// position
+	mib float64
+}
+
+// =============================================================================
+// func extendSteepArea
+// brief description: extend a steep area of a reachability plot as far as
+//	possible.
+// input:
+//	steep: whether each position is steep in the direction of the area
+//	reverse: whether each position goes in the opposite direction
+//	start: the first position of the area, which must be steep
+//	minPts: the maximum number of consecutive positions that are not steep
+// output:
+//	the last position of the area, which is steep
+func extendSteepArea(steep, reverse []bool, start, minPts int) int {
+	numNonSteep := 0
+	end := start
+	for idx := start; idx < len(steep); idx++ {
+		if steep[idx] {
+			numNonSteep = 0
+			end = idx
+		} else if reverse[idx] {
+			break
+		} else {
+			numNonSteep++
+			if numNonSteep > minPts {
+				break
+			}
+		}
+	}
+	return end
+}
+
+// =============================================================================
+// func filterSteepDownAreas
+// brief description: drop the steep down areas that cannot start a cluster
+//	any more, and update the mib of the others.
+// input:
+//	areas: the steep down areas
+//	mib: the maximum reachability since the last steep area
+//	xiComplement: 1 - xi
+//	plot: the reachability plot
+// output:
+//	the remaining steep down areas
+func filterSteepDownAreas(areas []*steepDownArea, mib, xiComplement float64, plot []float64,
+) []*steepDownArea {
+	if math.IsInf(mib, 1) {
+		return []*steepDownArea{}
+	}
+	result := []*steepDownArea{}
+	for _, area := range areas {
+		if mib <= plot[area.start]*xiComplement {
+			area.mib = math.Max(area.mib, mib)
+			result = append(result, area)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func ExtractXiClusters
+// brief description: extract clusters of varying densities from the output of
+//	OPTICS by the steepness of the reachability plot, i.e. the ξ method of the
+//	OPTICS paper, without choosing an eps.
+// input:
+//	order: the cluster ordering of points.
+//	reachabilities: the reachability distance of each point.
+//	xi: the minimum relative steepness, within (0, 1). A cluster starts where
+//		the reachability drops by a factor 1 - xi and ends where it rises by
+//		it, so the smaller xi is, the more clusters are found.
+//	minPts: the same as in OPTICS. Steep areas may contain up to minPts
+//		consecutive points that are not steep.
+//	minClusterSize: the minimum number of points of a cluster.
+// output:
+//	A list of clusters, ordered by their smallest members. The ξ clusters are
+//	nested, and only the innermost ones are returned, i.e. a cluster is kept
+//	if it does not overlap a smaller one. Points in no kept cluster are
+//	singleton clusters, as noise is in DBScan.
+// note:
+//	This follows the ExtractClusters of the OPTICS paper as corrected by
+//	scikit-learn, i.e. the condition 4c of its Definition 11 uses r(x) > r(sD),
+//	without the predecessor correction, since OPTICS does not output the
+//	predecessors. The reachability after the last point is regarded as +Inf,
+//	so the last walk can end a cluster.
+func ExtractXiClusters(order []int, reachabilities []float64, xi float64, minPts,
+	minClusterSize int) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: build the reachability plot and find its steep points
+	if xi <= 0.0 || xi >= 1.0 {
+		log.Fatalln(fmt.Sprintf("xi = %v must be within (0, 1)", xi))
+	}
+	n := len(order)
+	plot := make([]float64, n+1)
+	for idx, pt := range order {
+		plot[idx] = reachabilities[pt]
+	}
+	plot[n] = math.Inf(1)
+	xiComplement := 1.0 - xi
+	steepUp := make([]bool, n)
+	steepDown := make([]bool, n)
+	up := make([]bool, n)
+	down := make([]bool, n)
+	for idx := 0; idx < n; idx++ {
+		// the ratio is NaN for 0/0 and Inf/Inf, which is neither up nor down
+		ratio := plot[idx] / plot[idx+1]
+		steepUp[idx] = ratio <= xiComplement
+		steepDown[idx] = ratio >= 1.0/xiComplement
+		up[idx] = ratio < 1.0
+		down[idx] = ratio > 1.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: scan the steep areas, pairing each steep up area with the steep
+	// down areas before it
+	clusters := [][2]int{}
+	areas := []*steepDownArea{}
+	idx := 0
+	mib := 0.0
+	for steepIdx := 0; steepIdx < n; steepIdx++ {
+		if steepIdx < idx || (!steepUp[steepIdx] && !steepDown[steepIdx]) {
+			continue
+		}
+		for k := idx; k <= steepIdx; k++ {
+			mib = math.Max(mib, plot[k])
+		}
+		areas = filterSteepDownAreas(areas, mib, xiComplement, plot)
+
+		// (2.1) a steep down area may start clusters
+		if steepDown[steepIdx] {
+			end := extendSteepArea(steepDown, up, steepIdx, minPts)
+			areas = append(areas, &steepDownArea{start: steepIdx, end: end, mib: 0.0})
+			idx = end + 1
+			mib = plot[idx]
+			continue
+		}
+
+		// (2.2) a steep up area ends the clusters of the steep down areas
+		// matching it
+		upStart := steepIdx
+		upEnd := extendSteepArea(steepUp, down, upStart, minPts)
+		idx = upEnd + 1
+		mib = plot[idx]
+		clustersOfUp := [][2]int{}
+		for _, area := range areas {
+			start := area.start
+			end := upEnd
+			if plot[end+1]*xiComplement < area.mib {
+				continue
+			}
+			maxOfArea := plot[area.start]
+			if maxOfArea*xiComplement >= plot[end+1] {
+				for plot[start+1] > plot[end+1] && start < area.end {
+					start++
+				}
+			} else if plot[end+1]*xiComplement >= maxOfArea {
+				for plot[end-1] > maxOfArea && end > upStart {
+					end--
+				}
+			}
+			if end-start+1 < minClusterSize || start > area.end || end < upStart {
+				continue
+			}
+			clustersOfUp = append(clustersOfUp, [2]int{start, end})
+		}
+		// the later areas give the smaller clusters, which are added first
+		for k := len(clustersOfUp) - 1; k >= 0; k-- {
+			clusters = append(clusters, clustersOfUp[k])
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: keep the clusters not overlapping a kept one, and return them
+	// with the remaining points as singletons
+	taken := make([]bool, n)
+	communities := []map[int]bool{}
+	for _, cluster := range clusters {
+		overlapping := false
+		for k := cluster[0]; k <= cluster[1]; k++ {
+			if taken[k] {
+				overlapping = true
+				break
+			}
+		}
+		if overlapping {
+			continue
+		}
+		community := map[int]bool{}
+		for k := cluster[0]; k <= cluster[1]; k++ {
+			taken[k] = true
+			community[order[k]] = true
+		}
+		communities = append(communities, community)
+	}
+	for k := 0; k < n; k++ {
+		if !taken[k] {
+			communities = append(communities, map[int]bool{order[k]: true})
+		}
+	}
+	return canonicalizeCommunities(communities, nil)
+}
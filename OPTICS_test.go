@@ -0,0 +1,84 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestExtractDBScanClustersMatchesDBScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	planted, _ := plantedPartition(t, rng, 4, 15, 0.4, 0.05)
+	cm := randomWeights(t, planted, rng)
+	const minPts = 4
+	order, reachabilities, coreDistances := cm.OPTICS(minPts)
+	for _, eps := range []float64{0.3, 0.5, 0.7, 0.9} {
+		extracted := ExtractDBScanClusters(order, reachabilities, coreDistances, eps)
+		dbscan, _ := cm.DBScan(eps, minPts)
+		extractedIDs, err := CommunitiesToLabels(extracted, cm.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbscanIDs, err := CommunitiesToLabels(dbscan, cm.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// (1) the core points are those of DBScan, and are clustered the same
+		isCore := make([]bool, cm.n)
+		for u := 0; u < cm.n; u++ {
+			density := cm.cardinalities[u]
+			for _, similarity := range cm.concurrences[u] {
+				if similarity+eps >= 1.0 {
+					density++
+				}
+			}
+			isCore[u] = density >= minPts
+			if isCore[u] != (coreDistances[u] <= eps) {
+				t.Fatalf("eps = %v: core distance of %d = %v, density %d", eps, u,
+					coreDistances[u], density)
+			}
+		}
+		for u := 0; u < cm.n; u++ {
+			for v := 0; v < cm.n; v++ {
+				if isCore[u] && isCore[v] &&
+					(extractedIDs[u] == extractedIDs[v]) != (dbscanIDs[u] == dbscanIDs[v]) {
+					t.Fatalf("eps = %v: core points %d and %d are clustered differently", eps, u, v)
+				}
+			}
+		}
+
+		// (2) a border point is in the cluster of one of its core neighbors,
+		// which may differ between the two, and other points are noise. The
+		// extraction may also report a border point visited before the core
+		// points of its cluster as noise.
+		for u := 0; u < cm.n; u++ {
+			if isCore[u] {
+				continue
+			}
+			for _, result := range []struct {
+				communities  []map[int]bool
+				communityIDs []int
+				isExact      bool
+			}{{extracted, extractedIDs, false}, {dbscan, dbscanIDs, true}} {
+				c := result.communityIDs[u]
+				isBorder, inCoreCluster := false, false
+				for v, similarity := range cm.concurrences[u] {
+					if isCore[v] && similarity+eps >= 1.0 {
+						isBorder = true
+						inCoreCluster = inCoreCluster || result.communityIDs[v] == c
+					}
+				}
+				isNoise := len(result.communities[c]) == 1
+				if inCoreCluster == isNoise || (!isBorder && !isNoise) ||
+					(isBorder && isNoise && result.isExact) {
+					t.Fatalf("eps = %v: point %d is in %v of %v", eps, u,
+						result.communities[c], result.communities)
+				}
+			}
+		}
+	}
+	if !math.IsInf(reachabilities[order[0]], 1) {
+		t.Fatalf("reachability of the first point = %v, want +Inf", reachabilities[order[0]])
+	}
+}
@@ -0,0 +1,125 @@
+package ConcurrenceBasedClustering
+
+// =============================================================================
+// interface OverlappingQualityModel
+// brief description: This is an interface for quality models that can also
+//	evaluate overlapping communities, where a node may belong to several
+//	communities.
+type OverlappingQualityModel interface {
+	QualityModel
+
+	// QualityOverlap evaluates overlapping communities given as the
+	// memberships of nodes, i.e. memberships[u][c] == true if node u belongs
+	// to community c. Nodes without memberships belong to no community.
+	QualityOverlap(memberships map[int]map[int]bool) float64
+}
+
+// =============================================================================
+// func MembershipsOf
+// brief description: convert a list of possibly overlapping communities into
+//	the memberships of nodes
+// input:
+//	communities: a list of clusters. They may overlap.
+// output:
+//	the memberships, i.e. result[u][c] == true if communities[c][u] == true
+func MembershipsOf(communities []map[int]bool) map[int]map[int]bool {
+	result := map[int]map[int]bool{}
+	for idxC, c := range communities {
+		for u, _ := range c {
+			membershipsOfU, exists := result[u]
+			if !exists {
+				membershipsOfU = map[int]bool{}
+				result[u] = membershipsOfU
+			}
+			membershipsOfU[idxC] = true
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// struct OverlappingModularity
+// brief introduction: this is an implementation of Modularity for overlapping
+//	communities (Shen et al., 2009, and Nepusz et al., 2008), where the
+//	contribution of a node is split evenly across its communities. For
+//	disjoint communities, it is the same as Modularity.
+type OverlappingModularity struct {
+	Modularity
+}
+
+// =============================================================================
+// func NewOverlappingModularity
+// brief description: create a new OverlappingModularity
+// input:
+//	r: a threshold of modularity
+func NewOverlappingModularity(r float64, cm ConcurrenceModel) OverlappingModularity {
+	return OverlappingModularity{
+		Modularity: NewModularity(r, cm),
+	}
+}
+
+// =============================================================================
+// func (qm OverlappingModularity) Aggregate
+func (qm OverlappingModularity) Aggregate(communities []map[int]bool) QualityModel {
+	return QualityModel(OverlappingModularity{
+		Modularity{qm.r, qm.standard, qm.ConcurrenceModel.Aggregate(communities)},
+	})
+}
+
+// =============================================================================
+// func (qm OverlappingModularity) QualityOverlap
+// brief description: this implements QualityOverlap for interface
+//	OverlappingQualityModel
+// input:
+//	memberships: the communities of each node
+// output:
+//	the value of overlapping Modularity
+func (qm OverlappingModularity) QualityOverlap(memberships map[int]map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: collect the members of each community
+	members := map[int][]int{}
+	numMemberships := map[int]int{}
+	for u, membershipsOfU := range memberships {
+		for c, isMember := range membershipsOfU {
+			if isMember {
+				members[c] = append(members[c], u)
+				numMemberships[u]++
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute 1/m and r/m
+	oneOverM := 1.0 / float64(qm.sumConcurrences)
+	rOverM := qm.r * oneOverM
+
+	// -------------------------------------------------------------------------
+	// step 3: compute overlapping modularity using the following equation:
+	// modularity = 1/m sum_c sum_{i,j in c} (w_{i,j} - k_i * k_j * r/m) / (o_i o_j),
+	// where:
+	//	1/m = oneOverM,
+	//	w_{i,j} = concurrence[i][j],
+	//	k_u = nodewiseSumWeights[u],
+	//	o_u = the number of communities of u.
+	result := 0.0
+	for _, c := range members {
+		for _, i := range c {
+			ki := qm.sumConcurrencesOf[i]
+			oi := float64(numMemberships[i])
+			for _, j := range c {
+				if i == j {
+					continue
+				}
+				kj := qm.sumConcurrencesOf[j]
+				oj := float64(numMemberships[j])
+				result += (qm.GetConcurrence(i, j)*float64(qm.cardinalities[i]*qm.cardinalities[j]) -
+					rOverM*ki*kj) / (oi * oj)
+			}
+		}
+	}
+	result *= oneOverM
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return result
+}
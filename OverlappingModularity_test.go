@@ -0,0 +1,46 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQualityOverlapOfDisjointPartitionIsModularity(t *testing.T) {
+	cm, factions := karateClub(t)
+	rng := rand.New(rand.NewSource(1))
+	partitions := [][]map[int]bool{factions}
+	for i := 0; i < 10; i++ {
+		partitions = append(partitions, randomCommunities(rng, cm.GetN(), 1+rng.Intn(6)))
+	}
+	for _, r := range []float64{0.5, 1.0, 2.0} {
+		qm := NewOverlappingModularity(r, cm)
+		for _, communities := range partitions {
+			overlap := qm.QualityOverlap(MembershipsOf(communities))
+			disjoint := qm.Quality(communities)
+			if math.Abs(overlap-disjoint) > 1e-12 {
+				t.Fatalf("r = %v: QualityOverlap = %v, Quality = %v on %v", r, overlap,
+					disjoint, communities)
+			}
+		}
+	}
+}
+
+func TestQualityOverlapSplitsSharedNodes(t *testing.T) {
+	// twoTriangles has 7 edges, so m = 14, and the degrees are 2, 2, 3, 3, 2, 2.
+	// Node 3 belongs to A = {0, 1, 2, 3} and B = {3, 4, 5}, so every pair with
+	// node 3 counts (w_ij - k_i k_j / 14) / 2. Over unordered pairs:
+	//	A: (0,1) 1 - 4/14, (0,2) 1 - 6/14, (1,2) 1 - 6/14,
+	//	   (0,3) -6/14/2, (1,3) -6/14/2, (2,3) (1 - 9/14)/2,
+	//	B: (3,4) (1 - 6/14)/2, (3,5) (1 - 6/14)/2, (4,5) 1 - 4/14,
+	// which sum to 5.5 - 36.5/14 = 81/28. Both orders of each pair count, and
+	// the total is divided by m, so the quality is 2 * 81/28 / 14 = 81/196.
+	qm := NewOverlappingModularity(1.0, twoTriangles(t))
+	communities := []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true},
+		{3: true, 4: true, 5: true},
+	}
+	if got, want := qm.QualityOverlap(MembershipsOf(communities)), 81.0/196; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("QualityOverlap = %v, want %v", got, want)
+	}
+}
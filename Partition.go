@@ -0,0 +1,286 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// =============================================================================
+// type Partition
+// brief description: This is a list of communities, with conversions to and
+//	from the assignment vector representation, i.e. the community ID of each
+//	node.
+type Partition []map[int]bool
+
+// =============================================================================
+// func PartitionFromAssignment
+// brief description: create a Partition from an assignment vector
+// input:
+//	assignment: the community ID of each node. Nodes with negative IDs are not
+//		assigned to any community.
+// output:
+//	the Partition. Its communities are ordered by their IDs in the assignment,
+//	with IDs used by no node skipped.
+func PartitionFromAssignment(assignment []int) Partition {
+	communityOf := map[int]map[int]bool{}
+	for u, c := range assignment {
+		if c < 0 {
+			continue
+		}
+		_, exists := communityOf[c]
+		if !exists {
+			communityOf[c] = map[int]bool{}
+		}
+		communityOf[c][u] = true
+	}
+	ids := make([]int, 0, len(communityOf))
+	for c, _ := range communityOf {
+		ids = append(ids, c)
+	}
+	sort.Ints(ids)
+	result := make(Partition, len(ids))
+	for i, c := range ids {
+		result[i] = communityOf[c]
+	}
+	return result
+}
+
+// =============================================================================
+// func (p Partition) AssignmentVector
+// brief description: get the assignment vector of the partition
+// input:
+//	n: the number of nodes
+// output:
+//	the community ID of each node, -1 for nodes not in any community
+func (p Partition) AssignmentVector(n int) []int {
+	assignment, err := CommunitiesToLabels(p, n)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return assignment
+}
+
+// =============================================================================
+// func CommunitiesToLabels
+// brief description: convert communities into the flat labels used by other
+//	tools, e.g. the labels_ of scikit-learn or the membership of igraph.
+// input:
+//	communities: a list of clusters. Empty clusters are allowed.
+//	n: the number of nodes
+// output:
+//	output 1: the label of each node, i.e. the index of its community, or -1
+//		for nodes not in any community, like the noise of DBScan
+//	output 2: an error if a node is out of range or in several communities,
+//		nil otherwise
+func CommunitiesToLabels(communities []map[int]bool, n int) ([]int, error) {
+	labels := make([]int, n)
+	for u := 0; u < n; u++ {
+		labels[u] = -1
+	}
+	for c, community := range communities {
+		for u, _ := range community {
+			if u < 0 || u >= n {
+				return nil, fmt.Errorf("node %d of community %d is out of range [0, %d)", u, c, n)
+			}
+			if labels[u] >= 0 {
+				return nil, fmt.Errorf("node %d is in both community %d and %d",
+					u, labels[u], c)
+			}
+			labels[u] = c
+		}
+	}
+	return labels, nil
+}
+
+// =============================================================================
+// func LabelsToCommunities
+// brief description: convert flat labels of other tools into communities, the
+//	inverse of CommunitiesToLabels.
+// input:
+//	labels: the label of each node. Nodes with negative labels are not in any
+//		community.
+// output:
+//	the communities, ordered by their labels, with labels used by no node
+//	skipped. So labels 0..k-1 give community i for label i.
+func LabelsToCommunities(labels []int) []map[int]bool {
+	return PartitionFromAssignment(labels)
+}
+
+// =============================================================================
+// func (p Partition) Sizes
+// brief description: get the size of each community
+func (p Partition) Sizes() []int {
+	sizes := make([]int, len(p))
+	for c, community := range p {
+		sizes[c] = len(community)
+	}
+	return sizes
+}
+
+// =============================================================================
+// func (p Partition) CommunityOf
+// brief description: find the community of a node
+// input:
+//	u: a node ID
+// output:
+//	output 1: the ID of the first community containing u
+//	output 2: whether such a community exists
+func (p Partition) CommunityOf(u int) (int, bool) {
+	for c, community := range p {
+		if community[u] {
+			return c, true
+		}
+	}
+	return -1, false
+}
+
+// =============================================================================
+// func (p Partition) Canonicalize
+// brief description: put the partition into a canonical form, so that two
+//	equal partitions compare equal with reflect.DeepEqual.
+// output:
+//	a new Partition without empty communities, with communities sorted by
+//	their smallest members. The communities themselves are shared with p.
+func (p Partition) Canonicalize() Partition {
+	result := Partition{}
+	minMembers := []int{}
+	for _, community := range p {
+		if len(community) == 0 {
+			continue
+		}
+		minMember := 0
+		first := true
+		for u, _ := range community {
+			if first || u < minMember {
+				minMember = u
+				first = false
+			}
+		}
+		result = append(result, community)
+		minMembers = append(minMembers, minMember)
+	}
+	order := make([]int, len(result))
+	for i := 0; i < len(order); i++ {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return minMembers[order[i]] < minMembers[order[j]]
+	})
+	sorted := make(Partition, len(result))
+	for i, idx := range order {
+		sorted[i] = result[idx]
+	}
+	return sorted
+}
+
+// =============================================================================
+// func canonicalizeCommunities
+// brief description: put communities into the canonical order of Canonicalize,
+//	so that the community IDs returned by the clustering methods are stable
+//	across runs.
+// input:
+//	communities: a list of clusters. They must not overlap.
+//	communityIDs: the community ID of each point, updated in place to the new
+//		order. It may be nil.
+// output:
+//	the communities without empty ones, ordered by their smallest members
+func canonicalizeCommunities(communities []map[int]bool, communityIDs []int) []map[int]bool {
+	result := []map[int]bool(Partition(communities).Canonicalize())
+	if communityIDs != nil {
+		for c, community := range result {
+			for u, _ := range community {
+				communityIDs[u] = c
+			}
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func LouvainPartition
+// brief description: Louvain algorithm on Partitions. See Louvain.
+// input:
+//	qm: a quality model.
+//	p: the initial partition. If it is nil, single point communities are used.
+//	maxIters: the maximum number of iterations.
+// output:
+//	the optimized partition, nil if p does not partition the nodes of qm
+func LouvainPartition(qm QualityModel, p Partition, maxIters int) Partition {
+	if p == nil {
+		communities, _ := Louvain(qm, nil, nil, maxIters)
+		return Partition(communities)
+	}
+	communityIDs, err := CommunitiesToLabels(p, qm.GetN())
+	if err != nil {
+		return nil
+	}
+	communities, _ := Louvain(qm, p, communityIDs, maxIters)
+	return Partition(communities)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) DBScanPartition
+// brief description: DBScan returning a Partition. See DBScan.
+func (cm ConcurrenceModel) DBScanPartition(eps float64, minPts int) Partition {
+	communities, _ := cm.DBScan(eps, minPts)
+	return Partition(communities)
+}
+
+// =============================================================================
+// func SizeStats
+// brief description: summarize the sizes of communities
+// input:
+//	communities: a list of clusters.
+// output:
+//	output 1: the number of non-empty communities
+//	output 2: the size of each community, in the order of communities
+//	output 3: the size of the largest community
+//	output 4: the number of singleton communities
+func SizeStats(communities []map[int]bool) (int, []int, int, int) {
+	numCommunities := 0
+	sizes := make([]int, len(communities))
+	largest := 0
+	singletons := 0
+	for idxC, c := range communities {
+		sizes[idxC] = len(c)
+		if len(c) > 0 {
+			numCommunities++
+		}
+		if len(c) > largest {
+			largest = len(c)
+		}
+		if len(c) == 1 {
+			singletons++
+		}
+	}
+	return numCommunities, sizes, largest, singletons
+}
+
+// =============================================================================
+// func PartitionSummary
+// brief description: summarize the sizes of communities in one line, e.g. for
+//	logging. See SizeStats.
+func PartitionSummary(communities []map[int]bool) string {
+	numCommunities, sizes, largest, singletons := SizeStats(communities)
+	numNodes := 0
+	for _, size := range sizes {
+		numNodes += size
+	}
+	return fmt.Sprintf("%d nodes in %d communities, largest %d, singletons %d",
+		numNodes, numCommunities, largest, singletons)
+}
+
+// =============================================================================
+// func ValidatePartition
+// brief description: check that communities partition the nodes 0..n-1
+// input:
+//	communities: a list of clusters. Empty clusters are allowed.
+//	n: the number of nodes
+// output:
+//	an error if a node is out of range, in several communities, or in none,
+//	nil otherwise
+func ValidatePartition(communities []map[int]bool, n int) error {
+	_, err := getAssignment(communities, n)
+	return err
+}
@@ -0,0 +1,275 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"math"
+)
+
+// =============================================================================
+// func getAssignment
+// brief description: get the community ID of each node, checking that the
+//	communities cover all nodes 0..n-1 without overlaps.
+// input:
+//	communities: a list of clusters. Empty clusters are allowed.
+//	n: the number of nodes
+// output:
+//	output 1: the community ID of each node
+//	output 2: an error if a node is out of range, in several communities, or
+//		in none, nil otherwise
+func getAssignment(communities []map[int]bool, n int) ([]int, error) {
+	assignment := make([]int, n)
+	for u := 0; u < n; u++ {
+		assignment[u] = -1
+	}
+	for c, community := range communities {
+		for u, _ := range community {
+			if u < 0 || u >= n {
+				return nil, fmt.Errorf("node %d of community %d is out of range [0, %d)", u, c, n)
+			}
+			if assignment[u] >= 0 {
+				return nil, fmt.Errorf("node %d is in both community %d and %d",
+					u, assignment[u], c)
+			}
+			assignment[u] = c
+		}
+	}
+	for u := 0; u < n; u++ {
+		if assignment[u] < 0 {
+			return nil, fmt.Errorf("node %d is not in any community", u)
+		}
+	}
+	return assignment, nil
+}
+
+// =============================================================================
+// struct contingencyTable
+// brief description: the contingency table between two partitions of the same
+//	nodes, which is the basis of the external comparison metrics.
+type contingencyTable struct {
+	n       int
+	cells   map[[2]int]int
+	rowSums map[int]int
+	colSums map[int]int
+}
+
+// =============================================================================
+// func newContingencyTable
+// brief description: build the contingency table between two partitions
+// input:
+//	a, b: two partitions of the nodes 0..n-1
+//	n: the number of nodes
+// output:
+//	output 1: the contingency table
+//	output 2: an error if a or b is not a partition of 0..n-1, nil otherwise
+func newContingencyTable(a, b []map[int]bool, n int) (contingencyTable, error) {
+	assignmentA, err := getAssignment(a, n)
+	if err != nil {
+		return contingencyTable{}, fmt.Errorf("invalid partition a: %v", err)
+	}
+	assignmentB, err := getAssignment(b, n)
+	if err != nil {
+		return contingencyTable{}, fmt.Errorf("invalid partition b: %v", err)
+	}
+	table := contingencyTable{
+		n:       n,
+		cells:   map[[2]int]int{},
+		rowSums: map[int]int{},
+		colSums: map[int]int{},
+	}
+	for u := 0; u < n; u++ {
+		table.cells[[2]int{assignmentA[u], assignmentB[u]}]++
+		table.rowSums[assignmentA[u]]++
+		table.colSums[assignmentB[u]]++
+	}
+	return table, nil
+}
+
+// =============================================================================
+// func entropyOf
+// brief description: the entropy of a distribution given by counts
+// input:
+//	counts: the count of each class
+//	n: the sum of counts
+// output:
+//	the entropy in nats
+func entropyOf(counts map[int]int, n int) float64 {
+	result := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(n)
+			result -= p * math.Log(p)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (table contingencyTable) mutualInformation
+// brief description: the mutual information between the two partitions in nats
+func (table contingencyTable) mutualInformation() float64 {
+	result := 0.0
+	n := float64(table.n)
+	for cell, count := range table.cells {
+		nij := float64(count)
+		ai := float64(table.rowSums[cell[0]])
+		bj := float64(table.colSums[cell[1]])
+		result += nij / n * math.Log(n*nij/(ai*bj))
+	}
+	return result
+}
+
+// =============================================================================
+// func NMI
+// brief description: Normalized Mutual Information between two partitions,
+//	normalized by the arithmetic mean of their entropies as scikit-learn does
+//	by default.
+// input:
+//	a, b: two partitions of the nodes 0..n-1
+//	n: the number of nodes
+// output:
+//	output 1: the NMI within [0, 1]. When both partitions consist of a single
+//		community, their entropies are 0 and the NMI is defined as 1.
+//	output 2: an error if a or b is not a partition of 0..n-1, nil otherwise
+func NMI(a, b []map[int]bool, n int) (float64, error) {
+	table, err := newContingencyTable(a, b, n)
+	if err != nil {
+		return 0.0, err
+	}
+	if len(table.rowSums) == len(table.colSums) && len(table.rowSums) <= 1 {
+		return 1.0, nil
+	}
+	hA := entropyOf(table.rowSums, n)
+	hB := entropyOf(table.colSums, n)
+	normalizer := 0.5 * (hA + hB)
+	if normalizer <= 0.0 {
+		return 0.0, nil
+	}
+	return math.Min(1.0, math.Max(0.0, table.mutualInformation()/normalizer)), nil
+}
+
+// =============================================================================
+// func choose2
+// brief description: the number of unordered pairs among x items
+func choose2(x int) float64 {
+	return float64(x) * float64(x-1) / 2.0
+}
+
+// =============================================================================
+// func AdjustedRandIndex
+// brief description: Adjusted Rand Index between two partitions (Hubert and
+//	Arabie's adjustment for chance).
+// input:
+//	a, b: two partitions of the nodes 0..n-1
+//	n: the number of nodes
+// output:
+//	output 1: the ARI, 1 for identical partitions and about 0 for random
+//		ones. When the index is undefined because both partitions are the
+//		trivial single community or both are all singletons, it is 1.
+//	output 2: an error if a or b is not a partition of 0..n-1, nil otherwise
+func AdjustedRandIndex(a, b []map[int]bool, n int) (float64, error) {
+	table, err := newContingencyTable(a, b, n)
+	if err != nil {
+		return 0.0, err
+	}
+	sumCells := 0.0
+	for _, count := range table.cells {
+		sumCells += choose2(count)
+	}
+	sumRows := 0.0
+	for _, count := range table.rowSums {
+		sumRows += choose2(count)
+	}
+	sumCols := 0.0
+	for _, count := range table.colSums {
+		sumCols += choose2(count)
+	}
+	totalPairs := choose2(n)
+	if totalPairs == 0.0 {
+		return 1.0, nil
+	}
+	expected := sumRows * sumCols / totalPairs
+	maximum := 0.5 * (sumRows + sumCols)
+	if maximum == expected {
+		return 1.0, nil
+	}
+	return (sumCells - expected) / (maximum - expected), nil
+}
+
+// =============================================================================
+// func VariationOfInformation
+// brief description: Variation of Information between two partitions, i.e.
+//	H(a|b) + H(b|a). Unlike NMI, it is a true metric on partitions.
+// input:
+//	a, b: two partitions of the nodes 0..n-1. Empty communities are ignored.
+//	n: the number of nodes
+// output:
+//	output 1: the VI in nats, within [0, log(n)]. It is 0 if and only if the
+//		two partitions are the same.
+//	output 2: an error if a or b is not a partition of 0..n-1, nil otherwise
+func VariationOfInformation(a, b []map[int]bool, n int) (float64, error) {
+	table, err := newContingencyTable(a, b, n)
+	if err != nil {
+		return 0.0, err
+	}
+	// VI = -sum_{i,j} n_ij/n (log(n_ij/a_i) + log(n_ij/b_j)). Unlike
+	// H(a) + H(b) - 2 I(a, b), each term is >= 0, and all of them are exactly 0
+	// when the two partitions are the same, so there is no rounding error then.
+	result := 0.0
+	for cell, count := range table.cells {
+		nij := float64(count)
+		ai := float64(table.rowSums[cell[0]])
+		bj := float64(table.colSums[cell[1]])
+		result -= nij / float64(n) * (math.Log(nij/ai) + math.Log(nij/bj))
+	}
+	return result, nil
+}
+
+// =============================================================================
+// func projectionDistance
+// brief description: the number of nodes of a that are not in the best
+//	matching community of b, summed over all communities of a.
+// input:
+//	a, b: two partitions of the same nodes
+// output:
+//	the projection distance from a to b
+func projectionDistance(a, b []map[int]bool) int {
+	communityInB := map[int]int{}
+	for c, community := range b {
+		for u, _ := range community {
+			communityInB[u] = c
+		}
+	}
+	result := 0
+	for _, community := range a {
+		overlaps := map[int]int{}
+		maxOverlap := 0
+		for u, _ := range community {
+			c, exists := communityInB[u]
+			if !exists {
+				continue
+			}
+			overlaps[c]++
+			if overlaps[c] > maxOverlap {
+				maxOverlap = overlaps[c]
+			}
+		}
+		result += len(community) - maxOverlap
+	}
+	return result
+}
+
+// =============================================================================
+// func SplitJoinDistance
+// brief description: van Dongen's split/join distance between two partitions.
+// input:
+//	a, b: two partitions of the same nodes. Empty communities are ignored.
+// output:
+//	output 1: the projection distance from a to b, i.e. the number of nodes
+//		to move to turn a into a refinement of b
+//	output 2: the projection distance from b to a
+// note:
+//	The split/join distance is the sum of the two outputs. It is 0 if and
+//	only if the two partitions are the same.
+func SplitJoinDistance(a, b []map[int]bool) (int, int) {
+	return projectionDistance(a, b), projectionDistance(b, a)
+}
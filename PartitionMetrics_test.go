@@ -0,0 +1,125 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// metricCases are fixed pairs of labelings with the values of scikit-learn's
+// normalized_mutual_info_score and adjusted_rand_score, and the variation of
+// information in nats
+var metricCases = []struct {
+	a, b         []int
+	nmi, ari, vi float64
+}{
+	{[]int{0, 0, 1, 1}, []int{0, 0, 1, 2}, 0.8, 0.5714285714285715, 0.3465735902799725},
+	{[]int{0, 0, 1, 1}, []int{0, 1, 0, 1}, 0.0, -0.5, 1.3862943611198906},
+	{[]int{0, 0, 0, 1, 1, 1, 2, 2, 2, 2}, []int{0, 0, 1, 1, 1, 2, 2, 2, 0, 0},
+		0.3946483716358942, 0.09090909090909088, 1.3183347464017316},
+	{[]int{0, 0, 0, 0, 1, 1, 2, 3, 4, 5}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		0.8228161798644422, 0.0, 0.6931471805599445},
+}
+
+func TestNMIAndARIMatchScikitLearn(t *testing.T) {
+	for _, tc := range metricCases {
+		a, b := LabelsToCommunities(tc.a), LabelsToCommunities(tc.b)
+		nmi, err := NMI(a, b, len(tc.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ari, err := AdjustedRandIndex(a, b, len(tc.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(nmi-tc.nmi) > 1e-12 || math.Abs(ari-tc.ari) > 1e-12 {
+			t.Fatalf("%v vs %v: NMI = %v, ARI = %v, want %v, %v",
+				tc.a, tc.b, nmi, ari, tc.nmi, tc.ari)
+		}
+	}
+}
+
+func TestNMIAndARIDegenerateCases(t *testing.T) {
+	single := LabelsToCommunities([]int{0, 0, 0, 0})
+	singletons := LabelsToCommunities([]int{0, 1, 2, 3})
+	for _, pair := range [][2][]map[int]bool{{single, single}, {singletons, singletons}} {
+		nmi, _ := NMI(pair[0], pair[1], 4)
+		ari, _ := AdjustedRandIndex(pair[0], pair[1], 4)
+		if nmi != 1.0 || ari != 1.0 {
+			t.Fatalf("NMI = %v, ARI = %v of identical trivial partitions, want 1", nmi, ari)
+		}
+	}
+	nmi, _ := NMI(single, singletons, 4)
+	ari, _ := AdjustedRandIndex(single, singletons, 4)
+	if nmi != 0.0 || ari != 0.0 {
+		t.Fatalf("NMI = %v, ARI = %v of one community vs singletons, want 0", nmi, ari)
+	}
+
+	// the partitions must cover the same nodes
+	_, err := NMI(single, []map[int]bool{{0: true, 1: true}, {2: true}}, 4)
+	if err == nil {
+		t.Fatal("NMI accepts a partition missing a node")
+	}
+	_, err = AdjustedRandIndex(single, []map[int]bool{{0: true, 1: true}, {1: true, 2: true, 3: true}}, 4)
+	if err == nil {
+		t.Fatal("AdjustedRandIndex accepts overlapping communities")
+	}
+}
+
+func TestVariationOfInformationAndSplitJoin(t *testing.T) {
+	for _, tc := range metricCases {
+		a, b := LabelsToCommunities(tc.a), LabelsToCommunities(tc.b)
+		vi, err := VariationOfInformation(a, b, len(tc.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(vi-tc.vi) > 1e-12 {
+			t.Fatalf("%v vs %v: VI = %v, want %v", tc.a, tc.b, vi, tc.vi)
+		}
+	}
+
+	// empty communities are ignored, but the partitions must cover the same n
+	a := []map[int]bool{{0: true, 1: true}, {}, {2: true, 3: true}}
+	b := []map[int]bool{{0: true, 1: true}, {2: true}, {3: true}, {}}
+	vi, err := VariationOfInformation(a, a[:1:1], 2)
+	if err == nil {
+		t.Fatalf("VI = %v of partitions over different nodes", vi)
+	}
+	toB, toA := SplitJoinDistance(a, b)
+	if toB != 1 || toA != 0 {
+		t.Fatalf("SplitJoinDistance = %d, %d, want 1, 0", toB, toA)
+	}
+	toB, toA = SplitJoinDistance(b, b)
+	if toB != 0 || toA != 0 {
+		t.Fatalf("SplitJoinDistance of a partition to itself = %d, %d", toB, toA)
+	}
+}
+
+// TestVariationOfInformationAcrossLouvainIterations is an example of tracking
+// drift with VI: the partition after each iteration of Louvain gets closer to
+// the converged one.
+func TestVariationOfInformationAcrossLouvainIterations(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 5, 20, 0.4, 0.05)
+	qm := NewModularity(1.0, cm)
+	final, _, _ := LouvainWithOptions(qm, nil, nil, ClusteringOptions{MaxIters: 100, Seed: 1})
+	previous := math.Inf(1)
+	for iters := 1; ; iters++ {
+		communities, _, _ := LouvainWithOptions(qm, nil, nil,
+			ClusteringOptions{MaxIters: iters, Seed: 1})
+		vi, err := VariationOfInformation(communities, final, cm.GetN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vi > previous {
+			t.Fatalf("VI to the converged partition is %v after %d iterations, %v before",
+				vi, iters, previous)
+		}
+		if vi == 0.0 {
+			break
+		}
+		if iters >= 100 {
+			t.Fatal("the partitions never reach the converged one")
+		}
+		previous = vi
+	}
+}
@@ -0,0 +1,146 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSizeStatsAndSummary(t *testing.T) {
+	communities := []map[int]bool{{0: true, 1: true, 2: true}, {}, {3: true}, {4: true, 5: true}}
+	numCommunities, sizes, largest, singletons := SizeStats(communities)
+	if numCommunities != 3 || !reflect.DeepEqual(sizes, []int{3, 0, 1, 2}) || largest != 3 ||
+		singletons != 1 {
+		t.Fatalf("SizeStats = %d, %v, %d, %d", numCommunities, sizes, largest, singletons)
+	}
+	summary := PartitionSummary(communities)
+	if summary != "6 nodes in 3 communities, largest 3, singletons 1" {
+		t.Fatalf("PartitionSummary = %q", summary)
+	}
+}
+
+func TestValidatePartition(t *testing.T) {
+	valid := []map[int]bool{{0: true, 2: true}, {}, {1: true, 3: true}}
+	if err := ValidatePartition(valid, 4); err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range [][]map[int]bool{
+		{{0: true, 2: true}, {1: true}},                   // 3 is missing
+		{{0: true, 2: true}, {1: true, 2: true, 3: true}}, // 2 is in both
+		{{0: true, 1: true, 2: true, 3: true, 4: true}},   // 4 is out of range
+		{{-1: true, 0: true, 1: true, 2: true, 3: true}},  // -1 is out of range
+	} {
+		if err := ValidatePartition(invalid, 4); err == nil {
+			t.Fatalf("%v is accepted", invalid)
+		}
+	}
+}
+
+func TestStableCommunityIDs(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(1)), 4, 10, 0.6, 0.05)
+	qm := NewModularity(1.0, cm)
+	louvain := func(seed int64) []map[int]bool {
+		communities, communityIDs, _ := LouvainWithOptions(qm, nil, nil,
+			ClusteringOptions{MaxIters: 100, Seed: seed})
+		for u, c := range communityIDs {
+			if !communities[c][u] {
+				t.Fatalf("community ID of %d is %d, but %v does not contain it", u, c, communities[c])
+			}
+		}
+		return communities
+	}
+	dbscan := func(seed int64) []map[int]bool {
+		communities, _ := cm.DBScan(0.5, 3)
+		return communities
+	}
+	ahc := func(seed int64) []map[int]bool {
+		return cm.AHC(0.5)
+	}
+	for name, cluster := range map[string]func(seed int64) []map[int]bool{
+		"louvain": louvain, "dbscan": dbscan, "ahc": ahc,
+	} {
+		first := cluster(1)
+		if len(first) == 0 || !first[0][0] {
+			t.Fatalf("%s: the first community %v does not contain node 0", name, first)
+		}
+		for idxC := 1; idxC < len(first); idxC++ {
+			if minMember(first[idxC-1]) >= minMember(first[idxC]) {
+				t.Fatalf("%s: communities are not ordered by their smallest members: %v",
+					name, first)
+			}
+		}
+		for seed := int64(1); seed <= 3; seed++ {
+			again := cluster(seed)
+			if reflect.DeepEqual(Partition(again).Canonicalize(), Partition(first).Canonicalize()) &&
+				!reflect.DeepEqual(again, first) {
+				t.Fatalf("%s: the same partition is returned in another order", name)
+			}
+		}
+	}
+}
+
+// =============================================================================
+// func minMember
+// brief description: the smallest member of a non-empty community
+func minMember(c map[int]bool) int {
+	result := -1
+	for u, _ := range c {
+		if result < 0 || u < result {
+			result = u
+		}
+	}
+	return result
+}
+
+func TestAlgorithmsDoNotModifyTheirInput(t *testing.T) {
+	cm := ringOfCliques(t, 4, 4)
+	qm := NewModularity(1.0, cm)
+	communities := []map[int]bool{
+		{0: true, 1: true, 2: true, 3: true, 4: true},
+		{5: true, 6: true, 7: true},
+		{8: true, 9: true, 10: true, 11: true, 12: true, 13: true, 14: true, 15: true},
+	}
+	communityIDs, err := CommunitiesToLabels(communities, cm.GetN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCommunities := copyCommunities(communities)
+	wantIDs := append([]int(nil), communityIDs...)
+	assertUnchanged := func(step string) {
+		t.Helper()
+		if !reflect.DeepEqual(communities, wantCommunities) ||
+			!reflect.DeepEqual(communityIDs, wantIDs) {
+			t.Fatalf("%s modifies its input: %v, %v", step, communities, communityIDs)
+		}
+	}
+
+	Louvain(qm, communities, communityIDs, 100)
+	assertUnchanged("Louvain")
+	cm.DBScan(0.5, 2)
+	assertUnchanged("DBScan")
+	LouvainHierarchy(qm, communities, communityIDs, 100)
+	assertUnchanged("LouvainHierarchy")
+	LouvainWithOptions(qm, communities, communityIDs, ClusteringOptions{MaxIters: 100,
+		SplitDisconnected: true, MinCommunitySize: 5, FrozenCommunities: communities[1:2]})
+	assertUnchanged("LouvainWithOptions")
+	qm.Aggregate(communities)
+	assertUnchanged("Aggregate")
+	cm.SplitDisconnectedCommunities(communities)
+	cm.MergeSmallCommunities(communities, 4, qm, true)
+	assertUnchanged("SplitDisconnectedCommunities and MergeSmallCommunities")
+}
+
+func TestLouvainPartitionRejectsInvalidPartitions(t *testing.T) {
+	qm := NewModularity(1.0, twoTriangles(t))
+	for _, invalid := range []Partition{
+		{{0: true, 1: true, 2: true}, {2: true, 3: true, 4: true, 5: true}}, // 2 is in both
+		{{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true, 6: true}}, // 6 is out of range
+		{{0: true, 1: true, 2: true}, {3: true, 4: true}},                   // 5 is missing
+	} {
+		if p := LouvainPartition(qm, invalid, 100); p != nil {
+			t.Fatalf("LouvainPartition(%v) = %v, want nil", invalid, p)
+		}
+	}
+	p := LouvainPartition(qm, Partition{{0: true, 1: true, 2: true, 3: true}, {4: true, 5: true}}, 100)
+	assertSamePartition(t, p, []map[int]bool{{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}})
+}
@@ -0,0 +1,75 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// =============================================================================
+// func CheckQualityModel
+// brief description: check that the DeltaQuality of a quality model agrees
+//	with its Quality. It generates random partitions, applies random single
+//	node moves, and compares each DeltaQuality with the difference of the
+//	Qualities before and after the move. This is useful when implementing a
+//	new QualityModel, since Louvain only uses DeltaQuality to make decisions.
+// input:
+//	qm: a quality model.
+//	trials: the number of random partitions to check. Each partition is
+//		checked with several moves, including moves into an empty community.
+//	rng: the source of randomness. It must not be nil.
+// output:
+//	an error describing the first move whose DeltaQuality disagrees with the
+//	Quality difference beyond a relative tolerance of 1e-9, nil otherwise
+func CheckQualityModel(qm QualityModel, trials int, rng *rand.Rand) error {
+	n := qm.GetN()
+	if n == 0 {
+		return nil
+	}
+	const tolerance = 1e-9
+	const movesPerTrial = 10
+	for trial := 0; trial < trials; trial++ {
+		// ---------------------------------------------------------------------
+		// step 1: generate a random partition with an extra empty community
+		numCommunities := 1 + rng.Intn(n)
+		if numCommunities > 8 {
+			numCommunities = 1 + rng.Intn(8)
+		}
+		communities := make([]map[int]bool, numCommunities+1)
+		for idxC := 0; idxC <= numCommunities; idxC++ {
+			communities[idxC] = map[int]bool{}
+		}
+		communityIDs := make([]int, n)
+		for u := 0; u < n; u++ {
+			idxC := rng.Intn(numCommunities)
+			communities[idxC][u] = true
+			communityIDs[u] = idxC
+		}
+
+		// ---------------------------------------------------------------------
+		// step 2: apply random moves and compare the deltas
+		for move := 0; move < movesPerTrial; move++ {
+			u := rng.Intn(n)
+			oldCu := communityIDs[u]
+			newCu := rng.Intn(len(communities))
+			sizes := make([]int, len(communities))
+			for idxC, c := range communities {
+				sizes[idxC] = len(c)
+			}
+			before := qm.Quality(communities)
+			delta := qm.DeltaQuality(communities, u, oldCu, newCu)
+			delete(communities[oldCu], u)
+			communities[newCu][u] = true
+			communityIDs[u] = newCu
+			after := qm.Quality(communities)
+			scale := math.Max(1.0, math.Max(math.Abs(before), math.Abs(after)))
+			if math.IsNaN(delta) || math.Abs(after-before-delta) > tolerance*scale {
+				return fmt.Errorf("trial %d, move %d: moving node %d from community %d to %d "+
+					"(community sizes before the move: %v): DeltaQuality = %v, "+
+					"but Quality changed from %v to %v by %v",
+					trial, move, u, oldCu, newCu, sizes, delta, before, after, after-before)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCheckQualityModelOnModularityAndCPM(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 5; trial++ {
+		cm, _ := plantedPartition(t, rng, 3, 6+rng.Intn(6), 0.5, 0.1)
+		cm = randomWeights(t, cm, rng)
+		groups := randomCommunities(rng, cm.GetN(), 6)
+		for name, qm := range map[string]QualityModel{
+			"modularity":          NewModularity(0.5+rng.Float64(), cm),
+			"standard modularity": NewModularityStandard(0.5+rng.Float64(), cm),
+			"cpm":                 NewCPM(rng.Float64(), cm),
+		} {
+			for _, model := range []QualityModel{qm, qm.Aggregate(groups)} {
+				if err := CheckQualityModel(model, 20, rng); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckQualityModelDetectsWrongDeltas(t *testing.T) {
+	qm := halfDeltaModularity{NewModularity(1.0, twoTriangles(t))}
+	if err := CheckQualityModel(qm, 5, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("a DeltaQuality of half the change is accepted")
+	}
+}
+
+// =============================================================================
+// struct halfDeltaModularity
+// brief introduction: a broken quality model whose DeltaQuality is half of the
+//	change of its Quality, as Modularity's was before CheckQualityModel
+type halfDeltaModularity struct {
+	Modularity
+}
+
+func (qm halfDeltaModularity) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	return 0.5 * qm.Modularity.DeltaQuality(communities, u, oldCu, newCu)
+}
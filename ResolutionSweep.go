@@ -0,0 +1,63 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+)
+
+// =============================================================================
+// func newQualityModelOfType
+// brief description: create a quality model by its name
+// input:
+//	cm: a concurrence model.
+//	r: the resolution of the quality model.
+//	modelType: "modularity", "modularity-standard" or "cpm".
+// output:
+//	output 1: the quality model
+//	output 2: an error if modelType is unknown, nil otherwise
+func newQualityModelOfType(cm ConcurrenceModel, r float64, modelType string,
+) (QualityModel, error) {
+	switch modelType {
+	case "modularity":
+		return NewModularity(r, cm), nil
+	case "modularity-standard":
+		return NewModularityStandard(r, cm), nil
+	case "cpm":
+		return NewCPM(r, cm), nil
+	}
+	return nil, fmt.Errorf("unknown quality model type %q", modelType)
+}
+
+// =============================================================================
+// func ResolutionSweep
+// brief description: run multi-level Louvain for each of a list of
+//	resolutions, e.g. to find the ranges of resolutions where the number of
+//	communities is stable.
+// input:
+//	cm: a concurrence model.
+//	rValues: the resolutions.
+//	modelType: the quality model, "modularity", "modularity-standard" or
+//		"cpm".
+//	opts: the options of Louvain for each resolution. See
+//		LouvainHierarchyWithOptions.
+// output:
+//	output 1: the coarsest partition found for each resolution
+//	output 2: the number of communities for each resolution
+//	output 3: an error if modelType is unknown, nil otherwise. Nothing is
+//		computed in that case.
+func ResolutionSweep(cm ConcurrenceModel, rValues []float64, modelType string,
+	opts ClusteringOptions) ([][]map[int]bool, []int, error) {
+	if _, err := newQualityModelOfType(cm, 1.0, modelType); err != nil {
+		return nil, nil, err
+	}
+	partitions := make([][]map[int]bool, len(rValues))
+	numCommunities := make([]int, len(rValues))
+	for idxR, r := range rValues {
+		qm, _ := newQualityModelOfType(cm, r, modelType)
+		levels, _ := LouvainHierarchyWithOptions(qm, nil, nil, opts)
+		if len(levels) > 0 {
+			partitions[idxR] = levels[len(levels)-1]
+		}
+		numCommunities[idxR] = len(partitions[idxR])
+	}
+	return partitions, numCommunities, nil
+}
@@ -0,0 +1,38 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestResolutionSweepCPMRefinesWithResolution(t *testing.T) {
+	// CPM keeps a group together while its density is above r, so the planted
+	// groups of density 0.8 are found for r between the densities outside and
+	// inside the groups
+	cm, truth := plantedPartition(t, rand.New(rand.NewSource(1)), 4, 10, 0.8, 0.05)
+	rValues := []float64{0.01, 0.05, 0.1, 0.2, 0.3, 0.5, 0.7, 1.0, 1.5}
+	partitions, numCommunities, err := ResolutionSweep(cm, rValues, "cpm",
+		ClusteringOptions{MaxIters: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idxR := 1; idxR < len(rValues); idxR++ {
+		if numCommunities[idxR] < numCommunities[idxR-1] {
+			t.Fatalf("number of communities %v decreases at r = %v", numCommunities,
+				rValues[idxR])
+		}
+	}
+	if numCommunities[0] != 1 || numCommunities[len(rValues)-1] != cm.GetN() {
+		t.Fatalf("number of communities = %v, want 1 first and %d last", numCommunities,
+			cm.GetN())
+	}
+	assertSamePartition(t, partitions[3], truth)
+}
+
+func TestResolutionSweepRejectsUnknownModelType(t *testing.T) {
+	partitions, numCommunities, err := ResolutionSweep(twoTriangles(t), []float64{1.0},
+		"modularty", ClusteringOptions{MaxIters: 100})
+	if err == nil {
+		t.Fatalf("ResolutionSweep = %v, %v, want an error", partitions, numCommunities)
+	}
+}
@@ -0,0 +1,98 @@
+package ConcurrenceBasedClustering
+
+import (
+	"sync"
+)
+
+// =============================================================================
+// struct SafeConcurrenceModel
+// brief description: This is a concurrent-safe wrapper of ConcurrenceModel for
+//	read-heavy serving. Reads are done under a read lock and updates under a
+//	write lock.
+type SafeConcurrenceModel struct {
+	sync.RWMutex
+	cm ConcurrenceModel
+}
+
+// =============================================================================
+// func NewSafeConcurrenceModel
+// brief description: create a new SafeConcurrenceModel
+// input:
+//	cm: the wrapped ConcurrenceModel. The caller should not use it directly
+//		afterwards, since its maps are shared with the wrapper.
+func NewSafeConcurrenceModel(cm ConcurrenceModel) *SafeConcurrenceModel {
+	return &SafeConcurrenceModel{cm: cm}
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) GetN
+func (scm *SafeConcurrenceModel) GetN() int {
+	scm.RLock()
+	defer scm.RUnlock()
+	return scm.cm.GetN()
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) GetConcurrence
+// brief description: get concurrence between i and j
+// input:
+//	i, j: two point IDs
+// output:
+//	the frequency of the concurrence between i and j if the edge exists, 0
+//	otherwise
+func (scm *SafeConcurrenceModel) GetConcurrence(i, j int) float64 {
+	scm.RLock()
+	defer scm.RUnlock()
+	return scm.cm.GetConcurrence(i, j)
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) GetConcurrencesOf
+// brief description: get the concurrences related to a node
+// input:
+//	i: a point ID
+// output:
+//	a copy of the concurrences of i.
+// note:
+//	The inner map is modified by later updates, so a defensive copy is
+//	returned. This costs O(deg(i)) time and memory per call.
+func (scm *SafeConcurrenceModel) GetConcurrencesOf(i int) map[int]float64 {
+	scm.RLock()
+	defer scm.RUnlock()
+	result := map[int]float64{}
+	for j, weightIJ := range scm.cm.GetConcurrencesOf(i) {
+		result[j] = weightIJ
+	}
+	return result
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) SetConcurrenceModel
+// brief description: replace the wrapped ConcurrenceModel
+// input:
+//	cm: the new ConcurrenceModel
+func (scm *SafeConcurrenceModel) SetConcurrenceModel(cm ConcurrenceModel) {
+	scm.Lock()
+	defer scm.Unlock()
+	scm.cm = cm
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) AddConcurrence
+// brief description: add an amount of concurrence between two nodes under the
+//	write lock. See ConcurrenceModel.AddConcurrence.
+func (scm *SafeConcurrenceModel) AddConcurrence(i, j int, delta float64) {
+	scm.Lock()
+	defer scm.Unlock()
+	scm.cm.AddConcurrence(i, j, delta)
+}
+
+// =============================================================================
+// func (scm *SafeConcurrenceModel) RemoveConcurrence
+// brief description: remove an amount of concurrence between two nodes under
+//	the write lock. See ConcurrenceModel.RemoveConcurrence.
+func (scm *SafeConcurrenceModel) RemoveConcurrence(i, j int, delta float64) {
+	scm.Lock()
+	defer scm.Unlock()
+	scm.cm.RemoveConcurrence(i, j, delta)
+}
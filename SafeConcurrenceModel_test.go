@@ -0,0 +1,53 @@
+package ConcurrenceBasedClustering
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSafeConcurrenceModelConcurrentAccess hammers a SafeConcurrenceModel with
+// reads and writes. Run it with -race to detect unsynchronized accesses.
+func TestSafeConcurrenceModelConcurrentAccess(t *testing.T) {
+	scm := NewSafeConcurrenceModel(twoTriangles(t))
+	const numWriters = 4
+	const numReaders = 8
+	const numSteps = 2000
+	var wg sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for step := 0; step < numSteps; step++ {
+				scm.AddConcurrence(w, 6+step%3, 2.0)
+				scm.RemoveConcurrence(w, 6+step%3, 1.0)
+			}
+		}(w)
+	}
+	for r := 0; r < numReaders; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for step := 0; step < numSteps; step++ {
+				u := (r + step) % 6
+				row := scm.GetConcurrencesOf(u)
+				for v, _ := range row {
+					// the row is a copy, so writing to it must be safe
+					row[v] += scm.GetConcurrence(u, v)
+				}
+				scm.GetN()
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	for w := 0; w < numWriters; w++ {
+		total := 0.0
+		for v := 6; v < 9; v++ {
+			total += scm.GetConcurrence(w, v)
+		}
+		if total != numSteps {
+			t.Fatalf("total concurrence of %d to the new nodes = %v, want %d",
+				w, total, numSteps)
+		}
+	}
+}
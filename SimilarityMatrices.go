@@ -0,0 +1,459 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// =============================================================================
+// func SparsifyTopK
+// brief description: keep only the k most similar neighbors of each node in a
+//	similarity matrix. Dense rows around hub nodes make DBScan expensive, and
+//	this removes them while keeping the matrix symmetric.
+// input:
+//	simMat: the similarity matrix. It must be symmetric. Diagonal elements, if
+//		any, are preserved.
+//	k: the number of neighbors kept for each node.
+//	mutual: if true, an edge (u,v) is kept only if v is among the top k of u
+//		and u is among the top k of v. Otherwise, it is kept if either holds.
+// output:
+//	the sparsified similarity matrix.
+// note:
+//	Ties at the k-th position are broken by node IDs: the smaller ID wins.
+func SparsifyTopK(simMat []map[int]float64, k int, mutual bool) []map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: find the top k neighbors of each node
+	n := len(simMat)
+	topK := make([]map[int]bool, n)
+	for u := 0; u < n; u++ {
+		neighbors := make([]int, 0, len(simMat[u]))
+		for v, _ := range simMat[u] {
+			if v != u {
+				neighbors = append(neighbors, v)
+			}
+		}
+		rowU := simMat[u]
+		sort.Slice(neighbors, func(i, j int) bool {
+			simI := rowU[neighbors[i]]
+			simJ := rowU[neighbors[j]]
+			if simI != simJ {
+				return simI > simJ
+			}
+			return neighbors[i] < neighbors[j]
+		})
+		if len(neighbors) > k {
+			neighbors = neighbors[:k]
+		}
+		topK[u] = map[int]bool{}
+		for _, v := range neighbors {
+			topK[u][v] = true
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: build a symmetric result from the top k neighbors
+	result := make([]map[int]float64, n)
+	for u := 0; u < n; u++ {
+		result[u] = map[int]float64{}
+		diagonal, exists := simMat[u][u]
+		if exists {
+			result[u][u] = diagonal
+		}
+	}
+	for u := 0; u < n; u++ {
+		for v, _ := range topK[u] {
+			if mutual && !topK[v][u] {
+				continue
+			}
+			result[u][v] = simMat[u][v]
+			result[v][u] = simMat[v][u]
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) SparsifyTopK
+// brief description: create a new ConcurrenceModel that keeps only the k
+//	strongest concurrences of each node. The result can be used directly by
+//	DBScan, e.g. cm.SparsifyTopK(k, true).DBScan(eps, minPts).
+// input:
+//	k: the number of neighbors kept for each node.
+//	mutual: whether an edge must be among the top k of both of its nodes.
+// output:
+//	the sparsified ConcurrenceModel
+func (cm ConcurrenceModel) SparsifyTopK(k int, mutual bool) ConcurrenceModel {
+	return newConcurrenceModel(SparsifyTopK(cm.concurrences, k, mutual), cm.cardinalities)
+}
+
+// =============================================================================
+// var NumWorkers
+// brief description: the number of goroutines used to induce similarity
+//	matrices. If it is <= 0, runtime.NumCPU() is used.
+var NumWorkers = 0
+
+// =============================================================================
+// const minParallelRows
+// brief description: loops with fewer rows than this run serially, since
+//	starting goroutines would cost more than it saves.
+const minParallelRows = 1024
+
+// =============================================================================
+// func getNumWorkers
+// brief description: get the number of goroutines to use
+// input:
+//	numWorkers: the configured number of goroutines, <= 0 for the default
+// output:
+//	numWorkers if it is > 0, runtime.NumCPU() otherwise
+func getNumWorkers(numWorkers int) int {
+	if numWorkers > 0 {
+		return numWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// =============================================================================
+// func parallelFor
+// brief description: call a function for 0..n-1 with a pool of goroutines, or
+//	serially if n is small.
+// input:
+//	n: the number of calls
+//	numWorkers: the number of goroutines, <= 0 for runtime.NumCPU().
+//	f: the function. It must be safe to call concurrently for different i.
+func parallelFor(n, numWorkers int, f func(i int)) {
+	numWorkers = getNumWorkers(numWorkers)
+	if n < minParallelRows || numWorkers == 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for idxWorker := 0; idxWorker < numWorkers; idxWorker++ {
+		go func(idxWorker int) {
+			i0 := n * idxWorker / numWorkers
+			i1 := n * (idxWorker + 1) / numWorkers
+			for i := i0; i < i1; i++ {
+				f(i)
+			}
+			wg.Done()
+		}(idxWorker)
+	}
+	wg.Wait()
+}
+
+// =============================================================================
+// func induceRows
+// brief description: compute the rows of a similarity matrix, in parallel if
+//	the matrix is large enough. Since the rows are independent and each one is
+//	written to its own element of the result, no locking is needed.
+// input:
+//	n: the number of rows
+//	induceRow: the function computing a row. It must be safe to call
+//		concurrently for different rows.
+// output:
+//	the similarity matrix, the same as computing the rows serially up to the
+//	rounding of sums taken in map iteration order.
+func induceRows(n int, induceRow func(u int) map[int]float64) []map[int]float64 {
+	simMat := make([]map[int]float64, n)
+	parallelFor(n, NumWorkers, func(u int) {
+		simMat[u] = induceRow(u)
+	})
+	return simMat
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) InduceCosineSimilarities
+// brief description: induce a similarity matrix from the concurrences. Each
+//	node is regarded as a sparse vector of its concurrence weights, and the
+//	similarity between two nodes is the cosine between their vectors.
+// output:
+//	the similarity matrix. Row u contains the nodes sharing at least one
+//	neighbor with u, and the diagonal is not stored, as in the concurrences.
+//	It can be used with cm.DBScanWithSim.
+func (cm ConcurrenceModel) InduceCosineSimilarities() []map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute the norm of each node's vector
+	n := cm.n
+	norms := make([]float64, n)
+	for u := 0; u < n; u++ {
+		sumSquares := 0.0
+		for _, weightUW := range cm.concurrences[u] {
+			sumSquares += weightUW * weightUW
+		}
+		norms[u] = math.Sqrt(sumSquares)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: accumulate the dot products over shared neighbors
+	simMat := induceRows(n, func(u int) map[int]float64 {
+		rowU := map[int]float64{}
+		for w, weightUW := range cm.concurrences[u] {
+			for v, weightWV := range cm.concurrences[w] {
+				if v == u {
+					continue
+				}
+				rowU[v] += weightUW * weightWV
+			}
+		}
+		for v, dotUV := range rowU {
+			rowU[v] = dotUV / (norms[u] * norms[v])
+		}
+		return rowU
+	})
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return simMat
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) InduceRuzickaSimilarities
+// brief description: induce a similarity matrix from the concurrences by the
+//	weighted Jaccard of Ruzicka. Each node is regarded as a sparse vector of its
+//	concurrence weights, and the similarity between two nodes u and v is
+//	sum_i min(w_ui, w_vi) / sum_i max(w_ui, w_vi) over the union of their
+//	neighbors.
+// output:
+//	the similarity matrix, within [0, 1]. Row u contains the nodes sharing at
+//	least one neighbor with u, and the diagonal is not stored, as in the
+//	concurrences. It can be used with cm.DBScanWithSim.
+// note:
+//	Unlike a "weighted Jaccard" normalizing each vector by the total weight
+//	of its node, the weights are compared as they are, so a node is only
+//	similar to nodes with concurrences of the same magnitude. It is 1 only for
+//	identical vectors, and reduces to the Jaccard of the neighbor sets for 0/1
+//	weights. Since max(a, b) = a + b - min(a, b), the denominator is
+//	s_u + s_v - sum_i min(w_ui, w_vi) with s_u the total weight of u, so only
+//	the shared neighbors are visited.
+func (cm ConcurrenceModel) InduceRuzickaSimilarities() []map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute the total weight of each node's vector
+	n := cm.n
+	sums := make([]float64, n)
+	for u := 0; u < n; u++ {
+		for _, weightUW := range cm.concurrences[u] {
+			sums[u] += weightUW
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: accumulate the minima over shared neighbors
+	simMat := induceRows(n, func(u int) map[int]float64 {
+		rowU := map[int]float64{}
+		for w, weightUW := range cm.concurrences[u] {
+			for v, weightVW := range cm.concurrences[w] {
+				if v == u {
+					continue
+				}
+				rowU[v] += math.Min(weightUW, weightVW)
+			}
+		}
+		for v, sumMinUV := range rowU {
+			rowU[v] = sumMinUV / (sums[u] + sums[v] - sumMinUV)
+		}
+		return rowU
+	})
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return simMat
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) induceNeighborSetSimilarities
+// brief description: induce a similarity matrix from the sets of neighbors
+//	of nodes, ignoring the weights of concurrences.
+// input:
+//	similarityOf: the similarity between two nodes given the number of
+//		their shared neighbors and their numbers of neighbors.
+// output:
+//	the similarity matrix. Row u contains the nodes sharing at least one
+//	neighbor with u, and the diagonal is not stored, as in the concurrences.
+func (cm ConcurrenceModel) induceNeighborSetSimilarities(
+	similarityOf func(numShared, degreeU, degreeV int) float64) []map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: count the neighbors of each node
+	n := cm.n
+	degrees := make([]int, n)
+	for u := 0; u < n; u++ {
+		for w, _ := range cm.concurrences[u] {
+			if w != u {
+				degrees[u]++
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: count the shared neighbors
+	simMat := induceRows(n, func(u int) map[int]float64 {
+		numShared := map[int]int{}
+		for w, _ := range cm.concurrences[u] {
+			if w == u {
+				continue
+			}
+			for v, _ := range cm.concurrences[w] {
+				if v == u || v == w {
+					continue
+				}
+				numShared[v]++
+			}
+		}
+		rowU := make(map[int]float64, len(numShared))
+		for v, numSharedUV := range numShared {
+			rowU[v] = similarityOf(numSharedUV, degrees[u], degrees[v])
+		}
+		return rowU
+	})
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return simMat
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) InduceDiceSimilarities
+// brief description: induce a similarity matrix from the concurrences by the
+//	Dice-Sorensen coefficient of neighbor sets, i.e. the similarity between two
+//	nodes u and v is 2|N(u) ∩ N(v)| / (|N(u)| + |N(v)|), where N(u) is the set
+//	of nodes with concurrences with u.
+// output:
+//	the similarity matrix, within [0, 1]. Row u contains the nodes sharing at
+//	least one neighbor with u, and the diagonal is not stored, as in the
+//	concurrences. It can be used with cm.DBScanWithSim.
+// note:
+//	The weights of concurrences are ignored. Compared with the Jaccard of the
+//	same sets, |N(u) ∩ N(v)| / |N(u) ∪ N(v)|, Dice weighs the intersection
+//	more heavily, and the two are monotone in each other: D = 2J / (1 + J).
+func (cm ConcurrenceModel) InduceDiceSimilarities() []map[int]float64 {
+	return cm.induceNeighborSetSimilarities(func(numShared, degreeU, degreeV int) float64 {
+		return 2.0 * float64(numShared) / float64(degreeU+degreeV)
+	})
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) InduceOverlapSimilarities
+// brief description: induce a similarity matrix from the concurrences by the
+//	overlap (Szymkiewicz-Simpson) coefficient of neighbor sets, i.e. the
+//	similarity between two nodes u and v is
+//	|N(u) ∩ N(v)| / min(|N(u)|, |N(v)|).
+// output:
+//	the similarity matrix, within [0, 1]. Row u contains the nodes sharing at
+//	least one neighbor with u, and the diagonal is not stored, as in the
+//	concurrences. It can be used with cm.DBScanWithSim.
+// note:
+//	The weights of concurrences are ignored. Unlike Jaccard and Dice, the
+//	similarity is 1 whenever one neighbor set contains the other, however
+//	different their sizes are, so a leaf node is fully similar to the hub
+//	whose neighborhood contains its own. This detects containment, but also
+//	makes nodes with few neighbors similar to many nodes.
+func (cm ConcurrenceModel) InduceOverlapSimilarities() []map[int]float64 {
+	return cm.induceNeighborSetSimilarities(func(numShared, degreeU, degreeV int) float64 {
+		minDegree := degreeU
+		if degreeV < minDegree {
+			minDegree = degreeV
+		}
+		return float64(numShared) / float64(minDegree)
+	})
+}
+
+// =============================================================================
+// struct IntPair
+// brief description: This is a pair of node IDs.
+type IntPair struct {
+	U, V int
+}
+
+// =============================================================================
+// func CheckSimMatrixSymmetry
+// brief description: measure how far a similarity matrix is from symmetric,
+//	for debugging, since DBScan and the other methods taking similarity
+//	matrices assume symmetry without checking it.
+// input:
+//	simMat: the similarity matrix. An element missing on one side counts as 0.
+// output:
+//	output 1: the largest |simMat[u][v] - simMat[v][u]|
+//	output 2: the pair (u, v) with u < v of output 1, the first one in the
+//		order of u and then v if several pairs have it, or (-1, -1) if the
+//		matrix is empty or diagonal.
+// note:
+//	Among the inducers of this package, InduceDiceSimilarities and
+//	InduceOverlapSimilarities are exactly symmetric, since they only count
+//	neighbors. InduceCosineSimilarities and InduceRuzickaSimilarities are
+//	symmetric by formula, but sum the same terms in different orders for
+//	(u, v) and (v, u), so they may differ by rounding errors of about 1e-16
+//	times the number of shared neighbors. SymmetrizeSimilarities removes them.
+func CheckSimMatrixSymmetry(simMat []map[int]float64) (float64, IntPair) {
+	maxAsymmetry := 0.0
+	worstPair := IntPair{U: -1, V: -1}
+	isWorse := func(asymmetry float64, u, v int) bool {
+		if worstPair.U < 0 || asymmetry > maxAsymmetry {
+			return true
+		}
+		return asymmetry == maxAsymmetry && (u < worstPair.U || (u == worstPair.U && v < worstPair.V))
+	}
+	for u, rowU := range simMat {
+		for v, simUV := range rowU {
+			if v == u {
+				continue
+			}
+			simVU := 0.0
+			if v >= 0 && v < len(simMat) {
+				simVU = simMat[v][u]
+			}
+			asymmetry := math.Abs(simUV - simVU)
+			a, b := u, v
+			if b < a {
+				a, b = b, a
+			}
+			if isWorse(asymmetry, a, b) {
+				maxAsymmetry = asymmetry
+				worstPair = IntPair{U: a, V: b}
+			}
+		}
+	}
+	return maxAsymmetry, worstPair
+}
+
+// =============================================================================
+// func SymmetrizeSimilarities
+// brief description: make a similarity matrix exactly symmetric by averaging
+//	each pair of elements, e.g. to remove the rounding errors of
+//	InduceCosineSimilarities before passing the matrix to methods assuming
+//	symmetry.
+// input:
+//	simMat: the similarity matrix. It is not modified. An element missing on
+//		one side counts as 0.
+// output:
+//	the symmetric matrix with 0.5*(simMat[u][v]+simMat[v][u]) for u != v, and
+//	the diagonal elements of simMat, if any.
+func SymmetrizeSimilarities(simMat []map[int]float64) []map[int]float64 {
+	n := len(simMat)
+	result := make([]map[int]float64, n)
+	for u := 0; u < n; u++ {
+		result[u] = make(map[int]float64, len(simMat[u]))
+	}
+	for u := 0; u < n; u++ {
+		for v, simUV := range simMat[u] {
+			if v == u {
+				result[u][u] = simUV
+				continue
+			}
+			if v < 0 || v >= n {
+				log.Fatalln(fmt.Sprintf("simMat[%d] has node %d out of range [0, %d)", u, v, n))
+			}
+			average := 0.5 * (simUV + simMat[v][u])
+			result[u][v] = average
+			result[v][u] = average
+		}
+	}
+	return result
+}
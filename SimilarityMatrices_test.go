@@ -0,0 +1,213 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// =============================================================================
+// func hubGraph
+// brief description: a random graph whose first numHubs nodes are joined to
+//	every other node, with random weights, plus sparse random edges
+func hubGraph(t testing.TB, n, numHubs int, rng *rand.Rand) ConcurrenceModel {
+	edges := []Edge{}
+	for hub := 0; hub < numHubs; hub++ {
+		for v := hub + 1; v < n; v++ {
+			edges = append(edges, Edge{hub, v, 1.0 + rng.Float64()})
+		}
+	}
+	for i := 0; i < 2*n; i++ {
+		u := numHubs + rng.Intn(n-numHubs)
+		v := numHubs + rng.Intn(n-numHubs)
+		if u != v {
+			edges = append(edges, Edge{u, v, 1.0 + rng.Float64()})
+		}
+	}
+	return newTestModel(t, edges)
+}
+
+// =============================================================================
+// func countEntries
+// brief description: count the stored elements of a similarity matrix
+func countEntries(simMat []map[int]float64) int {
+	result := 0
+	for _, row := range simMat {
+		result += len(row)
+	}
+	return result
+}
+
+func TestSparsifyTopKSymmetricWithDiagonal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cm := hubGraph(t, 200, 5, rng)
+	simMat := make([]map[int]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		simMat[u] = map[int]float64{u: 1.0}
+		for v, weightUV := range cm.concurrences[u] {
+			simMat[u][v] = weightUV
+		}
+	}
+	for _, mutual := range []bool{false, true} {
+		sparse := SparsifyTopK(simMat, 3, mutual)
+		asymmetry, pair := CheckSimMatrixSymmetry(sparse)
+		if asymmetry != 0.0 {
+			t.Fatalf("mutual = %v: asymmetry %v at %v", mutual, asymmetry, pair)
+		}
+		for u := 0; u < cm.n; u++ {
+			if sparse[u][u] != 1.0 {
+				t.Fatalf("mutual = %v: diagonal of %d = %v, want 1", mutual, u, sparse[u][u])
+			}
+			for v, sim := range sparse[u] {
+				if sim != simMat[u][v] {
+					t.Fatalf("mutual = %v: sim(%d, %d) = %v, want %v",
+						mutual, u, v, sim, simMat[u][v])
+				}
+			}
+		}
+		if countEntries(sparse) >= countEntries(simMat)/2 {
+			t.Fatalf("mutual = %v: %d of %d entries kept", mutual,
+				countEntries(sparse), countEntries(simMat))
+		}
+		if mutual {
+			for u := 0; u < cm.n; u++ {
+				if len(sparse[u]) > 3+1 {
+					t.Fatalf("row %d keeps %d entries, want at most 3 and the diagonal",
+						u, len(sparse[u]))
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSparsifyTopKHubs(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	cm := hubGraph(b, 5000, 20, rng)
+	b.ReportAllocs()
+	b.ResetTimer()
+	sparse := cm
+	for i := 0; i < b.N; i++ {
+		sparse = cm.SparsifyTopK(10, false)
+	}
+	b.ReportMetric(float64(countEntries(cm.concurrences)), "entries-before")
+	b.ReportMetric(float64(countEntries(sparse.concurrences)), "entries-after")
+}
+
+// =============================================================================
+// func inducers
+// brief description: the similarity inducers of a model, by name
+func inducers(cm ConcurrenceModel) map[string]func() []map[int]float64 {
+	return map[string]func() []map[int]float64{
+		"cosine":  cm.InduceCosineSimilarities,
+		"ruzicka": cm.InduceRuzickaSimilarities,
+		"dice":    cm.InduceDiceSimilarities,
+		"overlap": cm.InduceOverlapSimilarities,
+	}
+}
+
+func TestInduceSimilaritiesParallelEqualsSerial(t *testing.T) {
+	defer func(numWorkers int) { NumWorkers = numWorkers }(NumWorkers)
+	// more rows than minParallelRows, so that the parallel path is taken
+	cm := hubGraph(t, 3000, 0, rand.New(rand.NewSource(1)))
+	for name, induce := range inducers(cm) {
+		NumWorkers = 1
+		serial := induce()
+		NumWorkers = 8
+		parallel := induce()
+		// the sums follow the iteration order of the maps, which differs even
+		// between two serial runs, so only the last bits may differ
+		for u, rowU := range serial {
+			if len(parallel[u]) != len(rowU) {
+				t.Fatalf("%s: row %d has %d entries in parallel, %d serially",
+					name, u, len(parallel[u]), len(rowU))
+			}
+			for v, sim := range rowU {
+				if math.Abs(parallel[u][v]-sim) > 1e-12*math.Abs(sim) {
+					t.Fatalf("%s: sim(%d, %d) = %v in parallel, %v serially",
+						name, u, v, parallel[u][v], sim)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkInduceSimilarities(b *testing.B) {
+	defer func(numWorkers int) { NumWorkers = numWorkers }(NumWorkers)
+	cm := hubGraph(b, 20000, 0, rand.New(rand.NewSource(1)))
+	for name, induce := range inducers(cm) {
+		for _, numWorkers := range []int{1, 4} {
+			b.Run(fmt.Sprintf("%s/workers=%d", name, numWorkers), func(b *testing.B) {
+				NumWorkers = numWorkers
+				for i := 0; i < b.N; i++ {
+					induce()
+				}
+			})
+		}
+	}
+}
+
+func TestInduceSimilaritiesWithIsolatedNode(t *testing.T) {
+	// two triangles and the isolated node 6
+	cm, err := newConcurrenceModelFromEdges(7, []Edge{{0, 1, 1}, {1, 2, 1}, {0, 2, 1},
+		{3, 4, 1}, {4, 5, 1}, {3, 5, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, induce := range inducers(cm) {
+		simMat := induce()
+		if len(simMat) != cm.n || simMat[6] == nil || len(simMat[6]) != 0 {
+			t.Fatalf("%s: %d rows, row of the isolated node = %v", name, len(simMat), simMat[6])
+		}
+		communities, communityIDs := cm.DBScanWithSim(0.7, 2, simMat)
+		assertSamePartition(t, communities, []map[int]bool{
+			{0: true, 1: true, 2: true}, {3: true, 4: true, 5: true}, {6: true}})
+		if !communities[communityIDs[6]][6] {
+			t.Fatalf("%s: community ID of the isolated node = %d", name, communityIDs[6])
+		}
+	}
+}
+
+func TestInducedSimilaritiesAreSymmetric(t *testing.T) {
+	cm := hubGraph(t, 300, 3, rand.New(rand.NewSource(1)))
+	// the counting inducers are exactly symmetric, the others up to rounding
+	tolerances := map[string]float64{"cosine": 1e-12, "ruzicka": 1e-12, "dice": 0.0, "overlap": 0.0}
+	for name, induce := range inducers(cm) {
+		asymmetry, pair := CheckSimMatrixSymmetry(induce())
+		if asymmetry > tolerances[name] {
+			t.Fatalf("%s: asymmetry %v at %v", name, asymmetry, pair)
+		}
+	}
+
+	simMat := []map[int]float64{{0: 1, 1: 0.5, 2: 0.25}, {0: 0.5, 1: 1}, {0: 0.5, 1: 0.5, 2: 1}}
+	asymmetry, pair := CheckSimMatrixSymmetry(simMat)
+	if asymmetry != 0.5 || pair != (IntPair{U: 1, V: 2}) {
+		t.Fatalf("asymmetry %v at %v, want 0.5 at (1, 2)", asymmetry, pair)
+	}
+}
+
+func TestSymmetrizeSimilaritiesRemovesRoundingErrors(t *testing.T) {
+	// the hubs share hundreds of neighbors, whose terms are summed in different
+	// orders for (u, v) and (v, u)
+	cm := hubGraph(t, 300, 3, rand.New(rand.NewSource(1)))
+	raw := cm.InduceCosineSimilarities()
+	asymmetry, pair := CheckSimMatrixSymmetry(raw)
+	if asymmetry == 0.0 || asymmetry > 1e-12 {
+		t.Fatalf("raw asymmetry %v at %v, want a rounding error", asymmetry, pair)
+	}
+	symmetric := SymmetrizeSimilarities(raw)
+	asymmetry, pair = CheckSimMatrixSymmetry(symmetric)
+	if asymmetry != 0.0 {
+		t.Fatalf("asymmetry %v at %v after SymmetrizeSimilarities", asymmetry, pair)
+	}
+	for u, rowU := range raw {
+		if len(symmetric[u]) != len(rowU) {
+			t.Fatalf("row %d has %d entries, want %d", u, len(symmetric[u]), len(rowU))
+		}
+		for v, sim := range rowU {
+			if math.Abs(symmetric[u][v]-sim) > 1e-12 {
+				t.Fatalf("sim(%d, %d) = %v, want %v", u, v, symmetric[u][v], sim)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"sort"
+)
+
+// =============================================================================
+// struct SparseConcurrenceModel
+// brief description: This is a ConcurrenceModel over arbitrary, possibly
+//	non-contiguous node IDs, e.g. IDs coming from a database. Internally, the
+//	IDs are remapped to the dense range 0..n-1, and all the methods of this
+//	struct translate IDs back, so callers only see their own IDs.
+type SparseConcurrenceModel struct {
+	cm          ConcurrenceModel
+	externalIDs []int
+	internalIDs map[int]int
+}
+
+// =============================================================================
+// func NewSparseConcurrenceModel
+// brief description: create a new SparseConcurrenceModel
+// input:
+//	concurrences: the concurrences between arbitrary node IDs. Each pair may
+//		be given in either or both directions, but when given in both
+//		directions, the two weights must be the same.
+// output:
+//	output 1: the new SparseConcurrenceModel. Dense IDs are assigned in the
+//		ascending order of the original IDs.
+//	output 2: an error if the input is invalid, nil otherwise
+func NewSparseConcurrenceModel(concurrences map[int]map[int]float64,
+) (SparseConcurrenceModel, error) {
+	// -------------------------------------------------------------------------
+	// step 1: collect and sort the original IDs
+	idSet := map[int]bool{}
+	for u, concurrencesOfU := range concurrences {
+		idSet[u] = true
+		for v, _ := range concurrencesOfU {
+			idSet[v] = true
+		}
+	}
+	externalIDs := make([]int, 0, len(idSet))
+	for id, _ := range idSet {
+		externalIDs = append(externalIDs, id)
+	}
+	sort.Ints(externalIDs)
+	internalIDs := make(map[int]int, len(externalIDs))
+	for internalID, externalID := range externalIDs {
+		internalIDs[externalID] = internalID
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: fill the dense concurrence matrix
+	n := len(externalIDs)
+	denseConcurrences := make([]map[int]float64, n)
+	cardinalities := make([]int, n)
+	for i := 0; i < n; i++ {
+		denseConcurrences[i] = map[int]float64{}
+		cardinalities[i] = 1
+	}
+	for u, concurrencesOfU := range concurrences {
+		for v, weightUV := range concurrencesOfU {
+			if u == v {
+				return SparseConcurrenceModel{}, fmt.Errorf("self concurrence of node %d", u)
+			}
+			if weightUV <= 0.0 {
+				return SparseConcurrenceModel{}, fmt.Errorf(
+					"concurrence(%d, %d) = %v must be > 0", u, v, weightUV)
+			}
+			weightVU, exists := concurrences[v][u]
+			if exists && weightVU != weightUV {
+				return SparseConcurrenceModel{}, fmt.Errorf(
+					"concurrence(%d, %d) = %v != concurrence(%d, %d) = %v",
+					u, v, weightUV, v, u, weightVU)
+			}
+			i := internalIDs[u]
+			j := internalIDs[v]
+			denseConcurrences[i][j] = weightUV
+			denseConcurrences[j][i] = weightUV
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return SparseConcurrenceModel{
+		cm:          newConcurrenceModel(denseConcurrences, cardinalities),
+		externalIDs: externalIDs,
+		internalIDs: internalIDs,
+	}, nil
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) GetModel
+// brief description: get the underlying ConcurrenceModel over dense IDs. Its
+//	results can be translated back with ToExternal.
+func (sm SparseConcurrenceModel) GetModel() ConcurrenceModel {
+	return sm.cm
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) GetN
+func (sm SparseConcurrenceModel) GetN() int {
+	return sm.cm.n
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) GetIDs
+// brief description: get the original IDs of all nodes in ascending order. The
+//	i-th element is the original ID of dense ID i.
+func (sm SparseConcurrenceModel) GetIDs() []int {
+	return sm.externalIDs
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) GetConcurrence
+// brief description: get concurrence between i and j
+// input:
+//	i, j: two original node IDs
+// output:
+//	the frequency of the concurrence between i and j if the edge exists, 0
+//	otherwise
+func (sm SparseConcurrenceModel) GetConcurrence(i, j int) float64 {
+	internalI, existsI := sm.internalIDs[i]
+	internalJ, existsJ := sm.internalIDs[j]
+	if !existsI || !existsJ {
+		return 0.0
+	}
+	return sm.cm.GetConcurrence(internalI, internalJ)
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) GetConcurrencesOf
+// brief description: get the concurrences related to a node
+// input:
+//	i: an original node ID
+// output:
+//	the concurrences of i keyed by original node IDs
+func (sm SparseConcurrenceModel) GetConcurrencesOf(i int) map[int]float64 {
+	result := map[int]float64{}
+	internalI, exists := sm.internalIDs[i]
+	if !exists {
+		return result
+	}
+	for internalJ, weightIJ := range sm.cm.concurrences[internalI] {
+		result[sm.externalIDs[internalJ]] = weightIJ
+	}
+	return result
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) ToExternal
+// brief description: translate communities of dense IDs into original IDs
+// input:
+//	communities: a list of clusters over dense IDs.
+// output:
+//	the same clusters over original IDs.
+func (sm SparseConcurrenceModel) ToExternal(communities []map[int]bool) []map[int]bool {
+	result := make([]map[int]bool, len(communities))
+	for idxC, c := range communities {
+		newC := make(map[int]bool, len(c))
+		for u, _ := range c {
+			newC[sm.externalIDs[u]] = true
+		}
+		result[idxC] = newC
+	}
+	return result
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) ToInternal
+// brief description: translate communities of original IDs into dense IDs
+// input:
+//	communities: a list of clusters over original IDs.
+// output:
+//	output 1: the same clusters over dense IDs.
+//	output 2: an error if a node ID is unknown, nil otherwise
+func (sm SparseConcurrenceModel) ToInternal(communities []map[int]bool) ([]map[int]bool, error) {
+	result := make([]map[int]bool, len(communities))
+	for idxC, c := range communities {
+		newC := make(map[int]bool, len(c))
+		for u, _ := range c {
+			internalU, exists := sm.internalIDs[u]
+			if !exists {
+				return nil, fmt.Errorf("unknown node ID %d", u)
+			}
+			newC[internalU] = true
+		}
+		result[idxC] = newC
+	}
+	return result, nil
+}
+
+// =============================================================================
+// func (sm SparseConcurrenceModel) DBScan
+// brief description: run DBScan on the model and report the result in original
+//	IDs. See ConcurrenceModel.DBScan.
+// output:
+//	output 1: A list of clusters over original IDs.
+//	output 2: the community ID of each original node ID.
+func (sm SparseConcurrenceModel) DBScan(eps float64, minPts int) ([]map[int]bool, map[int]int) {
+	communities, communityIDs := sm.cm.DBScan(eps, minPts)
+	externalCommunityIDs := make(map[int]int, len(communityIDs))
+	for u, c := range communityIDs {
+		externalCommunityIDs[sm.externalIDs[u]] = c
+	}
+	return sm.ToExternal(communities), externalCommunityIDs
+}
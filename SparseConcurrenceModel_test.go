@@ -0,0 +1,49 @@
+package ConcurrenceBasedClustering
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSparseConcurrenceModelWithGaps(t *testing.T) {
+	sm, err := NewSparseConcurrenceModel(map[int]map[int]float64{
+		5:       {1000000: 1.0, 7: 1.0},
+		7:       {1000000: 1.0},
+		3000000: {42: 1.0},
+		42:      {99: 0.2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.GetN() != 6 || !reflect.DeepEqual(sm.GetIDs(), []int{5, 7, 42, 99, 1000000, 3000000}) {
+		t.Fatalf("n = %d, IDs = %v", sm.GetN(), sm.GetIDs())
+	}
+	if sm.GetConcurrence(1000000, 5) != 1.0 || sm.GetConcurrence(42, 3000000) != 1.0 ||
+		sm.GetConcurrence(5, 42) != 0.0 {
+		t.Fatal("GetConcurrence does not use the original IDs")
+	}
+	if !reflect.DeepEqual(sm.GetConcurrencesOf(7), map[int]float64{5: 1.0, 1000000: 1.0}) {
+		t.Fatalf("GetConcurrencesOf(7) = %v", sm.GetConcurrencesOf(7))
+	}
+
+	communities, communityIDs := sm.DBScan(0.5, 2)
+	want := []map[int]bool{{5: true, 7: true, 1000000: true}, {42: true, 3000000: true}, {99: true}}
+	assertSamePartition(t, communities, want)
+	for id, c := range communityIDs {
+		if !communities[c][id] {
+			t.Fatalf("community ID of %d is %d, but %v does not contain it", id, c, communities[c])
+		}
+	}
+
+	internal, err := sm.ToInternal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sm.ToExternal(internal), want) {
+		t.Fatalf("ToExternal(ToInternal(%v)) = %v", want, sm.ToExternal(internal))
+	}
+	_, err = sm.ToInternal([]map[int]bool{{6: true}})
+	if err == nil {
+		t.Fatal("ToInternal accepts an unknown ID")
+	}
+}
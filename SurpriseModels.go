@@ -0,0 +1,440 @@
+package ConcurrenceBasedClustering
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// func klBernoulli
+// brief description: the Kullback-Leibler divergence D(x || y) between two
+//	Bernoulli distributions, which is the asymptotic form of the log binomial
+//	probabilities used by Surprise and Significance.
+// input:
+//	x, y: two probabilities. They are clamped to [0, 1] and (0, 1)
+//		respectively, so that the result is always finite. Clamping x only
+//		guards against rounding, since NewSurprise and NewSignificance reject
+//		concurrences > 1.
+// output:
+//	x log(x/y) + (1-x) log((1-x)/(1-y))
+func klBernoulli(x, y float64) float64 {
+	x = math.Min(1.0, math.Max(0.0, x))
+	y = math.Min(1.0-1e-12, math.Max(1e-12, y))
+	result := 0.0
+	if x > 0.0 {
+		result += x * math.Log(x/y)
+	}
+	if x < 1.0 {
+		result += (1.0 - x) * math.Log((1.0-x)/(1.0-y))
+	}
+	return result
+}
+
+// =============================================================================
+// func numPairs
+// brief description: the number of unordered pairs among a total cardinality
+func numPairs(cardinality int) float64 {
+	return float64(cardinality) * float64(cardinality-1) / 2.0
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getSelfWeight
+// brief description: get the weight inside a node, which is 0 except for the
+//	nodes of an aggregated model, since aggregated models keep the weights
+//	inside their nodes in sumConcurrencesOf only.
+// input:
+//	u: a node ID
+// output:
+//	the weight between the members of u, each pair counted once
+func (cm ConcurrenceModel) getSelfWeight(u int) float64 {
+	externalWeight := 0.0
+	for v, weightUV := range cm.concurrences[u] {
+		if v == u {
+			continue
+		}
+		externalWeight += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+	}
+	return math.Max(0.0, (cm.sumConcurrencesOf[u]-externalWeight)/2.0)
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getCommunityWeightAndSize
+// brief description: get the weight inside a community and its size
+// input:
+//	c: a community
+// output:
+//	output 1: the weight between the members of c, each pair counted once
+//	output 2: the sum of cardinalities of the members of c
+func (cm ConcurrenceModel) getCommunityWeightAndSize(c map[int]bool) (float64, int) {
+	weight := 0.0
+	size := 0
+	for u, _ := range c {
+		size += cm.cardinalities[u]
+		weight += cm.getSelfWeight(u)
+		for v, weightUV := range cm.concurrences[u] {
+			if v <= u || !c[v] {
+				continue
+			}
+			weight += weightUV * float64(cm.cardinalities[u]*cm.cardinalities[v])
+		}
+	}
+	return weight, size
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getTotalCardinality
+// brief description: get the sum of cardinalities of all nodes
+func (cm ConcurrenceModel) getTotalCardinality() int {
+	result := 0
+	for u := 0; u < cm.n; u++ {
+		result += cm.cardinalities[u]
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) checkProbabilities
+// brief description: check that the concurrences can be used as edge
+//	probabilities, i.e. that they are within [0, 1].
+// input:
+//	modelName: the name of the quality model, for the error message.
+// note:
+//	Self-loops are ignored, as everywhere else in the model.
+func (cm ConcurrenceModel) checkProbabilities(modelName string) {
+	for u := 0; u < cm.n; u++ {
+		for v, weightUV := range cm.concurrences[u] {
+			if v != u && weightUV > 1.0 {
+				log.Fatalln(fmt.Sprintf("%s needs concurrences within [0, 1], but (%d, %d) is %v",
+					modelName, u, v, weightUV))
+			}
+		}
+	}
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getMove
+// brief description: get the changes of the two communities involved when a
+//	node moves from one to the other.
+// input:
+//	communities: a list of clusters.
+//	u: a node ID in communities[oldCu].
+//	oldCu, newCu: the IDs of the old and new communities of u.
+// output:
+//	output 1: the weights inside the old community before and after the move
+//	output 2: the sizes of the old community before and after the move
+//	output 3: the weights inside the new community before and after the move
+//	output 4: the sizes of the new community before and after the move
+func (cm ConcurrenceModel) getMove(communities []map[int]bool, u, oldCu, newCu int) (
+	[2]float64, [2]int, [2]float64, [2]int) {
+	// -------------------------------------------------------------------------
+	// step 1: get the weights and sizes before the move
+	var oldWeights, newWeights [2]float64
+	var oldSizes, newSizes [2]int
+	oldWeights[0], oldSizes[0] = cm.getCommunityWeightAndSize(communities[oldCu])
+	newWeights[0], newSizes[0] = cm.getCommunityWeightAndSize(communities[newCu])
+
+	// -------------------------------------------------------------------------
+	// step 2: get the weights between u and the two communities
+	cardU := cm.cardinalities[u]
+	weightToOld := 0.0
+	weightToNew := 0.0
+	for v, weightUV := range cm.concurrences[u] {
+		if v == u {
+			continue
+		}
+		weightUV *= float64(cardU * cm.cardinalities[v])
+		if communities[oldCu][v] {
+			weightToOld += weightUV
+		}
+		if communities[newCu][v] {
+			weightToNew += weightUV
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: get the weights and sizes after the move
+	selfWeightU := cm.getSelfWeight(u)
+	oldWeights[1] = oldWeights[0] - selfWeightU - weightToOld
+	oldSizes[1] = oldSizes[0] - cardU
+	newWeights[1] = newWeights[0] + selfWeightU + weightToNew
+	newSizes[1] = newSizes[0] + cardU
+	return oldWeights, oldSizes, newWeights, newSizes
+}
+
+// =============================================================================
+// struct Surprise
+// brief introduction: this is an implementation of the asymptotic Surprise
+//	quality model for network clustering (Traag, Aldecoa and Delvenne, 2015),
+//	which does not suffer from the resolution limit of Modularity.
+// note:
+//	The concurrences are treated as edge probabilities, so they must be within
+//	[0, 1]. Scale the weights of other graphs into [0, 1] first, e.g. by
+//	dividing them by the largest one.
+type Surprise struct {
+	ConcurrenceModel
+}
+
+// =============================================================================
+// func NewSurprise
+// brief description: create a new Surprise
+// input:
+//	cm: the concurrence model. Its concurrences must be within [0, 1].
+func NewSurprise(cm ConcurrenceModel) Surprise {
+	cm.checkProbabilities("Surprise")
+	return Surprise{
+		ConcurrenceModel: cm,
+	}
+}
+
+func (qm Surprise) GetNeighbors(u int) map[int]float64 {
+	return qm.concurrences[u]
+}
+
+// =============================================================================
+// func (qm Surprise) Aggregate
+func (qm Surprise) Aggregate(communities []map[int]bool) QualityModel {
+	return QualityModel(Surprise{qm.ConcurrenceModel.Aggregate(communities)})
+}
+
+// =============================================================================
+// func (qm Surprise) surprise
+// brief description: compute Surprise from the totals of a partition
+// input:
+//	internalWeight: the total weight inside communities
+//	internalPairs: the total number of pairs inside communities
+//	totalPairs: the total number of pairs of nodes
+// output:
+//	the value of Surprise
+func (qm Surprise) surprise(internalWeight, internalPairs, totalPairs float64) float64 {
+	totalWeight := qm.sumConcurrences / 2.0
+	if totalWeight <= 0.0 || totalPairs <= 0.0 {
+		return 0.0
+	}
+	return totalWeight * klBernoulli(internalWeight/totalWeight, internalPairs/totalPairs)
+}
+
+// =============================================================================
+// func (qm Surprise) getInternalTotals
+// brief description: get the total weight and the total number of pairs inside
+//	communities
+func (qm Surprise) getInternalTotals(communities []map[int]bool) (float64, float64) {
+	internalWeight := 0.0
+	internalPairs := 0.0
+	for _, c := range communities {
+		weightC, sizeC := qm.getCommunityWeightAndSize(c)
+		internalWeight += weightC
+		internalPairs += numPairs(sizeC)
+	}
+	return internalWeight, internalPairs
+}
+
+// =============================================================================
+// func (qm Surprise) Quality
+// brief description: this implements Quality for interface QualityModel
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the value of Surprise
+func (qm Surprise) Quality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute Surprise using the following equation:
+	// Surprise = m D(q || <q>),
+	// where:
+	//	m is the total weight,
+	//	q is the fraction of the total weight inside communities,
+	//	<q> is the fraction of pairs of nodes inside communities,
+	//	D(x || y) = x log(x/y) + (1-x) log((1-x)/(1-y)).
+	internalWeight, internalPairs := qm.getInternalTotals(communities)
+	result := qm.surprise(internalWeight, internalPairs, numPairs(qm.getTotalCardinality()))
+
+	// -------------------------------------------------------------------------
+	// step 2: return the result
+	return result
+}
+
+// =============================================================================
+// func (qm Surprise) DeltaQuality
+// brief description: this implements DeltaQuality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+//	u: a node ID, 0 <= u < n.
+//	oldCu: the ID of the cluster u currently locates in.
+//	newCu: the ID of the cluster u wants to move in.
+// output:
+//	The change amount of Surprise.
+// note:
+//	Surprise is not a sum over communities, so this needs the totals of the
+//	whole partition, which costs a scan of all the concurrences. Louvain
+//	avoids it by keeping the totals, see getCachedDeltaQuality.
+func (qm Surprise) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check whether oldCu and newCu are the same one.
+	// no change if oldCu == newCu
+	if oldCu == newCu {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the totals before the move and the changes of the two
+	// communities involved
+	internalWeight, internalPairs := qm.getInternalTotals(communities)
+	oldWeights, oldSizes, newWeights, newSizes := qm.getMove(communities, u, oldCu, newCu)
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return qm.getDeltaSurprise(internalWeight, internalPairs, oldWeights, oldSizes, newWeights,
+		newSizes, numPairs(qm.getTotalCardinality()))
+}
+
+// =============================================================================
+// func (qm Surprise) getDeltaSurprise
+// brief description: compute the change of Surprise from the totals before a
+//	move and the changes of the two communities involved, see getMove.
+// input:
+//	internalWeight, internalPairs: the totals before the move, see
+//		getInternalTotals
+//	oldWeights, oldSizes: the old community before and after the move
+//	newWeights, newSizes: the new community before and after the move
+//	totalPairs: the total number of pairs of nodes
+// output:
+//	The change amount of Surprise.
+func (qm Surprise) getDeltaSurprise(internalWeight, internalPairs float64,
+	oldWeights [2]float64, oldSizes [2]int, newWeights [2]float64, newSizes [2]int,
+	totalPairs float64) float64 {
+	newInternalWeight := internalWeight -
+		oldWeights[0] + oldWeights[1] - newWeights[0] + newWeights[1]
+	newInternalPairs := internalPairs -
+		numPairs(oldSizes[0]) + numPairs(oldSizes[1]) -
+		numPairs(newSizes[0]) + numPairs(newSizes[1])
+	return qm.surprise(newInternalWeight, newInternalPairs, totalPairs) -
+		qm.surprise(internalWeight, internalPairs, totalPairs)
+}
+
+// =============================================================================
+// struct Significance
+// brief introduction: this is an implementation of the asymptotic Significance
+//	quality model for network clustering (Traag, Krings and Van Dooren, 2013),
+//	which does not suffer from the resolution limit of Modularity.
+// note:
+//	The concurrences are treated as edge probabilities, so they must be within
+//	[0, 1]. Scale the weights of other graphs into [0, 1] first, e.g. by
+//	dividing them by the largest one.
+type Significance struct {
+	ConcurrenceModel
+}
+
+// =============================================================================
+// func NewSignificance
+// brief description: create a new Significance
+// input:
+//	cm: the concurrence model. Its concurrences must be within [0, 1].
+func NewSignificance(cm ConcurrenceModel) Significance {
+	cm.checkProbabilities("Significance")
+	return Significance{
+		ConcurrenceModel: cm,
+	}
+}
+
+func (qm Significance) GetNeighbors(u int) map[int]float64 {
+	return qm.concurrences[u]
+}
+
+// =============================================================================
+// func (qm Significance) Aggregate
+func (qm Significance) Aggregate(communities []map[int]bool) QualityModel {
+	return QualityModel(Significance{qm.ConcurrenceModel.Aggregate(communities)})
+}
+
+// =============================================================================
+// func (qm Significance) significanceOf
+// brief description: compute the contribution of one community to Significance
+// input:
+//	weightC: the weight inside the community
+//	sizeC: the size of the community
+//	totalPairs: the total number of pairs of nodes
+// output:
+//	the contribution of the community
+func (qm Significance) significanceOf(weightC float64, sizeC int, totalPairs float64) float64 {
+	pairsC := numPairs(sizeC)
+	if pairsC <= 0.0 || totalPairs <= 0.0 {
+		return 0.0
+	}
+	return pairsC * klBernoulli(weightC/pairsC, qm.sumConcurrences/2.0/totalPairs)
+}
+
+// =============================================================================
+// func (qm Significance) Quality
+// brief description: this implements Quality for interface QualityModel
+// input:
+//	communities: a list of clusters. They must not overlap.
+// output:
+//	the value of Significance
+func (qm Significance) Quality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute Significance using the following equation:
+	// Significance = sum_c pairs_c D(p_c || p),
+	// where:
+	//	c is a community,
+	//	pairs_c is the number of pairs of nodes in c,
+	//	p_c is the density of c, i.e. the weight inside c / pairs_c,
+	//	p is the density of the whole graph,
+	//	D(x || y) = x log(x/y) + (1-x) log((1-x)/(1-y)).
+	totalPairs := numPairs(qm.getTotalCardinality())
+	result := 0.0
+	for _, c := range communities {
+		weightC, sizeC := qm.getCommunityWeightAndSize(c)
+		result += qm.significanceOf(weightC, sizeC, totalPairs)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: return the result
+	return result
+}
+
+// =============================================================================
+// func (qm Significance) DeltaQuality
+// brief description: this implements DeltaQuality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+//	u: a node ID, 0 <= u < n.
+//	oldCu: the ID of the cluster u currently locates in.
+//	newCu: the ID of the cluster u wants to move in.
+// output:
+//	The change amount of Significance.
+func (qm Significance) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check whether oldCu and newCu are the same one.
+	// no change if oldCu == newCu
+	if oldCu == newCu {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: only the two communities involved change
+	oldWeights, oldSizes, newWeights, newSizes := qm.getMove(communities, u, oldCu, newCu)
+	result := qm.getDeltaSignificance(oldWeights, oldSizes, newWeights, newSizes,
+		numPairs(qm.getTotalCardinality()))
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return result
+}
+
+// =============================================================================
+// func (qm Significance) getDeltaSignificance
+// brief description: compute the change of Significance from the changes of
+//	the two communities involved in a move, see getMove.
+// input:
+//	oldWeights, oldSizes: the old community before and after the move
+//	newWeights, newSizes: the new community before and after the move
+//	totalPairs: the total number of pairs of nodes
+// output:
+//	The change amount of Significance.
+func (qm Significance) getDeltaSignificance(oldWeights [2]float64, oldSizes [2]int,
+	newWeights [2]float64, newSizes [2]int, totalPairs float64) float64 {
+	return qm.significanceOf(oldWeights[1], oldSizes[1], totalPairs) -
+		qm.significanceOf(oldWeights[0], oldSizes[0], totalPairs) +
+		qm.significanceOf(newWeights[1], newSizes[1], totalPairs) -
+		qm.significanceOf(newWeights[0], newSizes[0], totalPairs)
+}
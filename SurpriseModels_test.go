@@ -0,0 +1,25 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSurpriseAndSignificanceDeltas(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cm, _ := plantedPartition(t, rng, 3, 8, 0.6, 0.1)
+	cm = randomWeights(t, cm, rng)
+	groups := randomCommunities(rng, cm.GetN(), 10)
+	for name, qm := range map[string]QualityModel{
+		"surprise":     NewSurprise(cm),
+		"significance": NewSignificance(cm),
+	} {
+		// the aggregated model has self-weights inside its nodes
+		for _, model := range []QualityModel{qm, qm.Aggregate(groups)} {
+			if err := CheckQualityModel(model, 20, rng); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			checkCachedDeltaQuality(t, model, 20, rng)
+		}
+	}
+}
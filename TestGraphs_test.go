@@ -0,0 +1,263 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// =============================================================================
+// func newTestModel
+// brief description: create a ConcurrenceModel from edges, failing the test on
+//	invalid edges
+func newTestModel(t testing.TB, edges []Edge) ConcurrenceModel {
+	t.Helper()
+	cm, err := NewConcurrenceModelFromEdges(edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+// =============================================================================
+// func twoTriangles
+// brief description: the triangles {0,1,2} and {3,4,5} joined by the edge
+//	(2,3), all weights 1
+func twoTriangles(t testing.TB) ConcurrenceModel {
+	return newTestModel(t, []Edge{{0, 1, 1}, {1, 2, 1}, {0, 2, 1},
+		{3, 4, 1}, {4, 5, 1}, {3, 5, 1}, {2, 3, 1}})
+}
+
+// =============================================================================
+// func cliqueEdges
+// brief description: the edges of a clique over nodes first..first+size-1
+func cliqueEdges(first, size int, weight float64) []Edge {
+	edges := []Edge{}
+	for i := 0; i < size; i++ {
+		for j := i + 1; j < size; j++ {
+			edges = append(edges, Edge{first + i, first + j, weight})
+		}
+	}
+	return edges
+}
+
+// =============================================================================
+// func ringOfCliques
+// brief description: k cliques of a size, each joined to the next one by a
+//	single edge. The cliques are nodes c*size..c*size+size-1.
+func ringOfCliques(t testing.TB, k, size int) ConcurrenceModel {
+	edges := []Edge{}
+	for c := 0; c < k; c++ {
+		edges = append(edges, cliqueEdges(c*size, size, 1)...)
+		edges = append(edges, Edge{c * size, ((c+1)%k)*size + 1, 1})
+	}
+	return newTestModel(t, edges)
+}
+
+// =============================================================================
+// func barbell
+// brief description: two cliques of a size, nodes 0..size-1 and
+//	size..2size-1, joined by the single edge (size-1, size)
+func barbell(t testing.TB, size int) ConcurrenceModel {
+	edges := append(cliqueEdges(0, size, 1), cliqueEdges(size, size, 1)...)
+	edges = append(edges, Edge{size - 1, size, 1})
+	return newTestModel(t, edges)
+}
+
+// =============================================================================
+// func plantedPartition
+// brief description: a random graph with numGroups planted groups of a size.
+//	Nodes in the same group are joined with probability pIn, other nodes with
+//	probability pOut, all with weight 1. Every node gets at least one edge
+//	inside its group, so that no node is isolated.
+// output:
+//	output 1: the model
+//	output 2: the planted groups, group g being nodes g*size..g*size+size-1
+func plantedPartition(t testing.TB, rng *rand.Rand, numGroups, size int, pIn, pOut float64,
+) (ConcurrenceModel, []map[int]bool) {
+	n := numGroups * size
+	edges := []Edge{}
+	for u := 0; u < n; u++ {
+		hasEdge := false
+		for v := u + 1; v < n; v++ {
+			p := pOut
+			if u/size == v/size {
+				p = pIn
+			}
+			if rng.Float64() < p {
+				edges = append(edges, Edge{u, v, 1})
+				hasEdge = hasEdge || u/size == v/size
+			}
+		}
+		if !hasEdge && u%size != size-1 {
+			edges = append(edges, Edge{u, u + 1, 1})
+		}
+	}
+	truth := make([]map[int]bool, numGroups)
+	for g := 0; g < numGroups; g++ {
+		truth[g] = map[int]bool{}
+		for u := g * size; u < (g+1)*size; u++ {
+			truth[g][u] = true
+		}
+	}
+	return newTestModel(t, edges), truth
+}
+
+// =============================================================================
+// func karateClub
+// brief description: Zachary's karate club, unweighted, with nodes 0..33 as in
+//	networkx.karate_club_graph
+// output:
+//	output 1: the model
+//	output 2: the two factions, "Mr. Hi" first and "Officer" second
+func karateClub(t testing.TB) (ConcurrenceModel, []map[int]bool) {
+	adjacency := [][]int{
+		0:  {1, 2, 3, 4, 5, 6, 7, 8, 10, 11, 12, 13, 17, 19, 21, 31},
+		1:  {2, 3, 7, 13, 17, 19, 21, 30},
+		2:  {3, 7, 8, 9, 13, 27, 28, 32},
+		3:  {7, 12, 13},
+		4:  {6, 10},
+		5:  {6, 10, 16},
+		6:  {16},
+		8:  {30, 32, 33},
+		9:  {33},
+		13: {33},
+		14: {32, 33},
+		15: {32, 33},
+		18: {32, 33},
+		19: {33},
+		20: {32, 33},
+		22: {32, 33},
+		23: {25, 27, 29, 32, 33},
+		24: {25, 27, 31},
+		25: {31},
+		26: {29, 33},
+		27: {33},
+		28: {31, 33},
+		29: {32, 33},
+		30: {32, 33},
+		31: {32, 33},
+		32: {33},
+	}
+	edges := []Edge{}
+	for u, neighbors := range adjacency {
+		for _, v := range neighbors {
+			edges = append(edges, Edge{u, v, 1})
+		}
+	}
+	mrHi := map[int]bool{}
+	for _, u := range []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 10, 11, 12, 13, 16, 17, 19, 21} {
+		mrHi[u] = true
+	}
+	officer := map[int]bool{}
+	for u := 0; u < 34; u++ {
+		if !mrHi[u] {
+			officer[u] = true
+		}
+	}
+	return newTestModel(t, edges), []map[int]bool{mrHi, officer}
+}
+
+// =============================================================================
+// func assertSameModel
+// brief description: check that two models have the same nodes, weights and
+//	cardinalities, and the same statistics up to rounding
+func assertSameModel(t testing.TB, got, want ConcurrenceModel) {
+	t.Helper()
+	if got.n != want.n || !reflect.DeepEqual(got.cardinalities, want.cardinalities) {
+		t.Fatalf("n or cardinalities differ: %d %v, want %d %v",
+			got.n, got.cardinalities, want.n, want.cardinalities)
+	}
+	if !reflect.DeepEqual(got.concurrences, want.concurrences) {
+		t.Fatalf("concurrences differ:\n%v\nwant\n%v", got.concurrences, want.concurrences)
+	}
+	for u := 0; u < want.n; u++ {
+		if math.Abs(got.sumConcurrencesOf[u]-want.sumConcurrencesOf[u]) > 1e-9 {
+			t.Fatalf("sumConcurrencesOf[%d] = %v, want %v",
+				u, got.sumConcurrencesOf[u], want.sumConcurrencesOf[u])
+		}
+	}
+	if math.Abs(got.sumConcurrences-want.sumConcurrences) > 1e-9 {
+		t.Fatalf("sumConcurrences = %v, want %v", got.sumConcurrences, want.sumConcurrences)
+	}
+}
+
+// =============================================================================
+// func assertSamePartition
+// brief description: check that two partitions have the same communities,
+//	ignoring their order
+func assertSamePartition(t testing.TB, got, want []map[int]bool) {
+	t.Helper()
+	if !reflect.DeepEqual(Partition(got).Canonicalize(), Partition(want).Canonicalize()) {
+		t.Fatalf("partition = %v, want %v", got, want)
+	}
+}
+
+// =============================================================================
+// func largestSize
+// brief description: the size of the largest community
+func largestSize(communities []map[int]bool) int {
+	result := 0
+	for _, c := range communities {
+		if len(c) > result {
+			result = len(c)
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func randomCommunities
+// brief description: a random partition of n nodes into at most k communities
+func randomCommunities(rng *rand.Rand, n, k int) []map[int]bool {
+	labels := make([]int, n)
+	for u := 0; u < n; u++ {
+		labels[u] = rng.Intn(k)
+	}
+	return LabelsToCommunities(labels)
+}
+
+// =============================================================================
+// func checkCachedDeltaQuality
+// brief description: check that the DeltaQuality function Louvain uses for a
+//	sweep, which is cached for some quality models, agrees with DeltaQuality
+//	on random partitions and moves, including moves into an empty community
+func checkCachedDeltaQuality(t testing.TB, qm QualityModel, trials int, rng *rand.Rand) {
+	t.Helper()
+	n := qm.GetN()
+	for trial := 0; trial < trials; trial++ {
+		communities := append(randomCommunities(rng, n, 1+rng.Intn(8)), map[int]bool{})
+		communityIDs, err := CommunitiesToLabels(communities, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deltaQuality := getDeltaQualityFunc(qm, communities)
+		for move := 0; move < 20; move++ {
+			u := rng.Intn(n)
+			newCu := rng.Intn(len(communities))
+			want := qm.DeltaQuality(communities, u, communityIDs[u], newCu)
+			got := deltaQuality(u, communityIDs[u], newCu)
+			if math.Abs(got-want) > 1e-9*math.Max(1.0, math.Abs(want)) {
+				t.Fatalf("trial %d: cached delta of moving %d from %d to %d = %v, want %v",
+					trial, u, communityIDs[u], newCu, got, want)
+			}
+		}
+	}
+}
+
+// =============================================================================
+// func randomWeights
+// brief description: replace the weights of a model by random weights within
+//	(0, 1], keeping its edges
+func randomWeights(t testing.TB, cm ConcurrenceModel, rng *rand.Rand) ConcurrenceModel {
+	edges := cm.GetEdges()
+	for idxE, _ := range edges {
+		edges[idxE].W = 1.0 - rng.Float64()
+	}
+	result, err := newConcurrenceModelFromEdges(cm.n, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -0,0 +1,383 @@
+package ConcurrenceBasedClustering
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"math"
+)
+
+// =============================================================================
+// struct walktrapCommunity
+// brief description: This is a community of Walktrap with the statistics
+//	needed to merge it.
+type walktrapCommunity struct {
+	// the number of nodes
+	size int
+
+	// the probabilities of walks of length t from a random node of the
+	// community to each node
+	probabilities map[int]float64
+
+	// the total concurrence to each adjacent community
+	weights map[int]float64
+
+	// the current distance to each adjacent community
+	sigmas map[int]float64
+
+	// the concurrences inside the community, counted in both directions
+	internalWeight float64
+
+	// the sum of the degrees of the nodes, without the added loops
+	totalWeight float64
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getWalkDegrees
+// brief description: get the degree of each node in the walk graph, i.e. the
+//	concurrence graph with a loop added to each node, weighing the mean
+//	concurrence of the node, or 1 for isolated nodes. The loops make the walks
+//	aperiodic.
+// output:
+//	output 1: the degree of each node, the loop included
+//	output 2: the weight of the loop of each node
+func (cm ConcurrenceModel) getWalkDegrees() ([]float64, []float64) {
+	degrees := make([]float64, cm.n)
+	loops := make([]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		sumWeights := 0.0
+		numNeighbors := 0
+		for v, weightUV := range cm.concurrences[u] {
+			if v != u {
+				sumWeights += weightUV
+				numNeighbors++
+			}
+		}
+		loops[u] = 1.0
+		if numNeighbors > 0 {
+			loops[u] = sumWeights / float64(numNeighbors)
+		}
+		degrees[u] = sumWeights + loops[u]
+	}
+	return degrees, loops
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) getWalkProbabilities
+// brief description: get the probabilities of random walks of length t
+// input:
+//	t: the length of walks
+//	degrees, loops: the output of getWalkDegrees
+// output:
+//	the probability to reach each node from each node in t steps
+func (cm ConcurrenceModel) getWalkProbabilities(t int, degrees, loops []float64,
+) []map[int]float64 {
+	probabilities := make([]map[int]float64, cm.n)
+	parallelFor(cm.n, NumWorkers, func(u int) {
+		current := map[int]float64{u: 1.0}
+		for step := 0; step < t; step++ {
+			next := make(map[int]float64, len(current))
+			for x, probabilityX := range current {
+				next[x] += probabilityX * loops[x] / degrees[x]
+				for v, weightXV := range cm.concurrences[x] {
+					if v != x {
+						next[v] += probabilityX * weightXV / degrees[x]
+					}
+				}
+			}
+			current = next
+		}
+		probabilities[u] = current
+	})
+	return probabilities
+}
+
+// =============================================================================
+// func getWalkDistance
+// brief description: the squared distance between two communities, i.e. the
+//	distance between their walk probabilities weighted by 1/degree.
+// input:
+//	p1, p2: the walk probabilities of two communities
+//	degrees: the degree of each node
+// output:
+//	the squared distance r^2
+func getWalkDistance(p1, p2 map[int]float64, degrees []float64) float64 {
+	result := 0.0
+	for k, p1K := range p1 {
+		diff := p1K - p2[k]
+		result += diff * diff / degrees[k]
+	}
+	for k, p2K := range p2 {
+		_, exists := p1[k]
+		if !exists {
+			result += p2K * p2K / degrees[k]
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) WalktrapDistances
+// brief description: the random walk distances of Pons and Latapy between
+//	adjacent nodes, i.e. r_uv = sqrt(sum_k (P^t_uk - P^t_vk)^2 / d(k)), where
+//	P^t_uk is the probability to reach k from u in t steps and d(k) is the
+//	degree of k.
+// input:
+//	t: the length of random walks, must be > 0.
+// output:
+//	the distance between each pair of adjacent nodes, stored in both
+//	directions like the concurrences.
+// note:
+//	The walks run on the same graph as Walktrap, see getWalkDegrees. P^t is
+//	not materialized: the walks of each node take t sparse steps, so the cost
+//	depends on the number of nodes reached in t steps.
+func (cm ConcurrenceModel) WalktrapDistances(t int) map[int]map[int]float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and compute the walks of single nodes
+	if t <= 0 {
+		log.Fatalln(fmt.Sprintf("t = %d must be > 0", t))
+	}
+	degrees, loops := cm.getWalkDegrees()
+	probabilities := cm.getWalkProbabilities(t, degrees, loops)
+
+	// -------------------------------------------------------------------------
+	// step 2: compute the distances between adjacent nodes
+	result := make(map[int]map[int]float64, cm.n)
+	for u := 0; u < cm.n; u++ {
+		for v, _ := range cm.concurrences[u] {
+			if v == u {
+				continue
+			}
+			if result[u] == nil {
+				result[u] = map[int]float64{}
+			}
+			if result[v] == nil {
+				result[v] = map[int]float64{}
+			}
+			_, exists := result[u][v]
+			if !exists {
+				distance := math.Sqrt(getWalkDistance(probabilities[u], probabilities[v], degrees))
+				result[u][v] = distance
+				result[v][u] = distance
+			}
+		}
+	}
+	return result
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) walktrapDendrogram
+// brief description: the agglomeration of Walktrap, see Walktrap
+// input:
+//	t: the length of random walks, > 0.
+// output:
+//	output 1: the dendrogram of the merges, at the distances sigma
+//	output 2: the modularity before any merge and after each merge
+func (cm ConcurrenceModel) walktrapDendrogram(t int) (*Dendrogram, []float64) {
+	// -------------------------------------------------------------------------
+	// step 1: compute the walks of single nodes
+	n := cm.n
+	degrees, loops := cm.getWalkDegrees()
+	probabilities := cm.getWalkProbabilities(t, degrees, loops)
+
+	// -------------------------------------------------------------------------
+	// step 2: initialize the single node communities and their distances
+	communities := make([]*walktrapCommunity, n, 2*n)
+	sumWeights := 0.0
+	for u := 0; u < n; u++ {
+		communities[u] = &walktrapCommunity{
+			size:          1,
+			probabilities: probabilities[u],
+			weights:       map[int]float64{},
+			sigmas:        map[int]float64{},
+		}
+		for v, weightUV := range cm.concurrences[u] {
+			if v != u {
+				communities[u].weights[v] = weightUV
+				communities[u].totalWeight += weightUV
+			}
+		}
+		sumWeights += communities[u].totalWeight
+	}
+	h := &clusterPairHeap{}
+	for u := 0; u < n; u++ {
+		for v, _ := range communities[u].weights {
+			if u < v {
+				sigma := 0.5 / float64(n) * getWalkDistance(probabilities[u], probabilities[v], degrees)
+				communities[u].sigmas[v] = sigma
+				communities[v].sigmas[u] = sigma
+				*h = append(*h, clusterPair{distance: sigma, a: u, b: v})
+			}
+		}
+	}
+	heap.Init(h)
+
+	// -------------------------------------------------------------------------
+	// step 3: merge the closest adjacent communities until none is left,
+	// recording the merges and the modularity after each of them
+	modularity := 0.0
+	if sumWeights > 0.0 {
+		for u := 0; u < n; u++ {
+			x := communities[u].totalWeight / sumWeights
+			modularity -= x * x
+		}
+	}
+	dendrogram := &Dendrogram{N: n, Merges: make([]DendrogramMerge, 0, n)}
+	cardinalities := make([]int, n, 2*n)
+	copy(cardinalities, cm.cardinalities)
+	modularities := []float64{modularity}
+	alive := make([]bool, n, 2*n)
+	for u := 0; u < n; u++ {
+		alive[u] = true
+	}
+	for h.Len() > 0 {
+		// (3.1) pop the closest pair, skipping stale ones
+		pair := heap.Pop(h).(clusterPair)
+		if !alive[pair.a] || !alive[pair.b] || communities[pair.a].sigmas[pair.b] != pair.distance {
+			continue
+		}
+		c1 := communities[pair.a]
+		c2 := communities[pair.b]
+
+		// (3.2) create the merged community
+		size := c1.size + c2.size
+		c3 := &walktrapCommunity{
+			size:           size,
+			probabilities:  map[int]float64{},
+			weights:        map[int]float64{},
+			sigmas:         map[int]float64{},
+			internalWeight: c1.internalWeight + c2.internalWeight + 2.0*c1.weights[pair.b],
+			totalWeight:    c1.totalWeight + c2.totalWeight,
+		}
+		for k, p := range c1.probabilities {
+			c3.probabilities[k] += p * float64(c1.size) / float64(size)
+		}
+		for k, p := range c2.probabilities {
+			c3.probabilities[k] += p * float64(c2.size) / float64(size)
+		}
+		idxC3 := len(communities)
+		communities = append(communities, c3)
+		alive = append(alive, true)
+		alive[pair.a] = false
+		alive[pair.b] = false
+
+		// (3.3) update the adjacent communities and their distances to c3
+		for _, idxC := range []int{pair.a, pair.b} {
+			for idxD, weight := range communities[idxC].weights {
+				if idxD != pair.a && idxD != pair.b {
+					c3.weights[idxD] += weight
+				}
+			}
+		}
+		for idxD, weight := range c3.weights {
+			d := communities[idxD]
+			delete(d.weights, pair.a)
+			delete(d.weights, pair.b)
+			delete(d.sigmas, pair.a)
+			delete(d.sigmas, pair.b)
+			d.weights[idxC3] = weight
+			sigma1, adjacent1 := c1.sigmas[idxD]
+			sigma2, adjacent2 := c2.sigmas[idxD]
+			sigma := 0.0
+			if adjacent1 && adjacent2 {
+				sigma = (float64(c1.size+d.size)*sigma1 + float64(c2.size+d.size)*sigma2 -
+					float64(d.size)*pair.distance) / float64(size+d.size)
+			} else {
+				sigma = float64(size*d.size) / float64(size+d.size) / float64(n) *
+					getWalkDistance(c3.probabilities, d.probabilities, degrees)
+			}
+			c3.sigmas[idxD] = sigma
+			d.sigmas[idxC3] = sigma
+			heap.Push(h, clusterPair{distance: sigma, a: idxD, b: idxC3})
+		}
+		communities[pair.a] = nil
+		communities[pair.b] = nil
+
+		// (3.4) record the merge and the modularity after it
+		x1 := c1.totalWeight / sumWeights
+		x2 := c2.totalWeight / sumWeights
+		x3 := c3.totalWeight / sumWeights
+		modularity += (c3.internalWeight-c1.internalWeight-c2.internalWeight)/sumWeights -
+			x3*x3 + x1*x1 + x2*x2
+		cardinalities = append(cardinalities, cardinalities[pair.a]+cardinalities[pair.b])
+		dendrogram.Merges = append(dendrogram.Merges, DendrogramMerge{
+			Left:     pair.a,
+			Right:    pair.b,
+			Distance: pair.distance,
+			Size:     cardinalities[idxC3],
+		})
+		modularities = append(modularities, modularity)
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: return the result
+	return dendrogram, modularities
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) WalktrapDendrogram
+// brief description: the dendrogram of the Walktrap algorithm, see Walktrap
+// input:
+//	t: the length of random walks, must be > 0.
+// output:
+//	output 1: the dendrogram, whose distances are the Ward-like distances
+//		sigma of the merged communities. Since only adjacent communities are
+//		merged, it has one root per connected component of the concurrence
+//		graph.
+//	output 2: an error if t <= 0, nil otherwise
+func (cm ConcurrenceModel) WalktrapDendrogram(t int) (*Dendrogram, error) {
+	if t <= 0 {
+		return nil, fmt.Errorf("t = %d must be > 0", t)
+	}
+	dendrogram, _ := cm.walktrapDendrogram(t)
+	return dendrogram, nil
+}
+
+// =============================================================================
+// func (cm ConcurrenceModel) Walktrap
+// brief description: the Walktrap algorithm of Pons and Latapy. Starting from
+//	single node communities, it repeatedly merges the two adjacent communities
+//	with the smallest Ward-like distance between their random walks of length
+//	t, and returns the best cut of the merges.
+// input:
+//	t: the length of random walks, must be > 0. 3 to 5 are common choices.
+//	numClusters: the number of communities of the cut. If it is 0, the cut
+//		maximizing the standard modularity (see NewModularityStandard with
+//		r = 1) is used.
+// output:
+//	the communities, in canonical order. Since only adjacent communities are
+//	merged, there are more than numClusters communities if the concurrence
+//	graph has more than numClusters connected components.
+// note:
+//	The walks run on the concurrence graph with a loop added to each node, see
+//	getWalkDegrees. Cardinalities are not used. The distance between two
+//	communities C1 and C2 is sigma = 1/n |C1||C2|/(|C1|+|C2|) r^2(C1, C2), and
+//	after merging them into C3, the distance from C3 to a community adjacent
+//	to both is updated by the Lance-Williams formula of Ward's method, so only
+//	the distances to communities adjacent to one of them are recomputed. Use
+//	WalktrapDendrogram to cut the merges in other ways.
+func (cm ConcurrenceModel) Walktrap(t int, numClusters int) []map[int]bool {
+	// -------------------------------------------------------------------------
+	// step 1: check the input and agglomerate
+	if t <= 0 {
+		log.Fatalln(fmt.Sprintf("t = %d must be > 0", t))
+	}
+	if numClusters < 0 {
+		log.Fatalln(fmt.Sprintf("numClusters = %d must be >= 0", numClusters))
+	}
+	dendrogram, modularities := cm.walktrapDendrogram(t)
+
+	// -------------------------------------------------------------------------
+	// step 2: choose the cut
+	if numClusters > 0 {
+		communities, _ := dendrogram.CutK(numClusters)
+		return communities
+	}
+	numMerges := 0
+	for i, q := range modularities {
+		if q > modularities[numMerges] {
+			numMerges = i
+		}
+	}
+	return dendrogram.cut(numMerges)
+}
@@ -0,0 +1,177 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWalktrapRecoversCommunities(t *testing.T) {
+	ring := ringOfCliques(t, 6, 5)
+	ringTruth := make([]map[int]bool, 6)
+	for c := 0; c < 6; c++ {
+		ringTruth[c] = map[int]bool{}
+		for u := c * 5; u < (c+1)*5; u++ {
+			ringTruth[c][u] = true
+		}
+	}
+	planted, plantedTruth := plantedPartition(t, rand.New(rand.NewSource(1)), 4, 25, 0.4, 0.02)
+	for name, test := range map[string]struct {
+		cm    ConcurrenceModel
+		truth []map[int]bool
+	}{"ring of cliques": {ring, ringTruth}, "planted partition": {planted, plantedTruth}} {
+		// the default cut maximizes modularity, and a cut at the planted number
+		// of communities gives the same partition
+		for _, numClusters := range []int{0, len(test.truth)} {
+			communities := test.cm.Walktrap(4, numClusters)
+			if len(communities) != len(test.truth) {
+				t.Fatalf("%s, numClusters = %d: %d communities, want %d", name, numClusters,
+					len(communities), len(test.truth))
+			}
+			assertSamePartition(t, communities, test.truth)
+		}
+	}
+}
+
+func TestWalktrapNumClusters(t *testing.T) {
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(2)), 3, 10, 0.5, 0.1)
+	if len(cm.ConnectedComponents(1.0)) != 1 {
+		t.Fatal("the planted partition is not connected")
+	}
+	for k := 1; k <= cm.GetN(); k++ {
+		communities := cm.Walktrap(3, k)
+		if len(communities) != k {
+			t.Fatalf("numClusters = %d: %d communities", k, len(communities))
+		}
+		if err := ValidatePartition(communities, cm.GetN()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalktrapMergeDistancesMatchDirectComputation(t *testing.T) {
+	// each merge is at the distance sigma between its two communities, whether
+	// it was updated by the Lance-Williams formula or computed directly
+	const walkLength = 3
+	cm, _ := plantedPartition(t, rand.New(rand.NewSource(3)), 3, 8, 0.6, 0.1)
+	dendrogram, _ := cm.walktrapDendrogram(walkLength)
+	degrees, loops := cm.getWalkDegrees()
+	probabilities := cm.getWalkProbabilities(walkLength, degrees, loops)
+	n := cm.GetN()
+	members := make([][]int, n, 2*n)
+	for u := 0; u < n; u++ {
+		members[u] = []int{u}
+	}
+	meanProbabilities := func(nodes []int) map[int]float64 {
+		result := map[int]float64{}
+		for _, u := range nodes {
+			for k, p := range probabilities[u] {
+				result[k] += p / float64(len(nodes))
+			}
+		}
+		return result
+	}
+	if len(dendrogram.Merges) != n-1 {
+		t.Fatalf("%d merges, want %d", len(dendrogram.Merges), n-1)
+	}
+	for i, merge := range dendrogram.Merges {
+		left := members[merge.Left]
+		right := members[merge.Right]
+		size1 := float64(len(left))
+		size2 := float64(len(right))
+		want := size1 * size2 / (size1 + size2) / float64(n) *
+			getWalkDistance(meanProbabilities(left), meanProbabilities(right), degrees)
+		if math.Abs(merge.Distance-want) > 1e-12*math.Max(want, 1e-3) {
+			t.Fatalf("merge %d of %v and %v at %v, want %v", i, left, right, merge.Distance, want)
+		}
+		members = append(members, append(append([]int{}, left...), right...))
+	}
+}
+
+func TestWalktrapDistancesMatchDensePowers(t *testing.T) {
+	// -------------------------------------------------------------------------
+	// step 1: two triangles joined by an edge, an edge apart and the isolated
+	// node 8, with random weights
+	edges := append(twoTriangles(t).GetEdges(), Edge{0, 5, 1}, Edge{6, 7, 1})
+	cm, err := newConcurrenceModelFromEdges(9, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm = randomWeights(t, cm, rand.New(rand.NewSource(1)))
+	n := cm.GetN()
+
+	// -------------------------------------------------------------------------
+	// step 2: compute P^t densely, with the loop of each node weighing its
+	// mean concurrence, or 1 for isolated nodes
+	const walkLength = 3
+	degrees := make([]float64, n)
+	transitions := make([][]float64, n)
+	for u := 0; u < n; u++ {
+		transitions[u] = make([]float64, n)
+		row := cm.GetConcurrencesOf(u)
+		loop := 1.0
+		if len(row) > 0 {
+			loop = 0.0
+			for _, weight := range row {
+				loop += weight / float64(len(row))
+			}
+		}
+		degrees[u] = loop
+		for _, weight := range row {
+			degrees[u] += weight
+		}
+		transitions[u][u] = loop / degrees[u]
+		for v, weight := range row {
+			transitions[u][v] = weight / degrees[u]
+		}
+	}
+	powers := transitions
+	for step := 1; step < walkLength; step++ {
+		next := make([][]float64, n)
+		for u := 0; u < n; u++ {
+			next[u] = make([]float64, n)
+			for x := 0; x < n; x++ {
+				for v := 0; v < n; v++ {
+					next[u][v] += powers[u][x] * transitions[x][v]
+				}
+			}
+		}
+		powers = next
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: compare the distances of all adjacent pairs
+	distances := cm.WalktrapDistances(walkLength)
+	numPairs := 0
+	for u := 0; u < n; u++ {
+		for v, _ := range cm.GetConcurrencesOf(u) {
+			want := 0.0
+			for k := 0; k < n; k++ {
+				diff := powers[u][k] - powers[v][k]
+				want += diff * diff / degrees[k]
+			}
+			want = math.Sqrt(want)
+			if math.Abs(distances[u][v]-want) > 1e-12 {
+				t.Fatalf("distance(%d, %d) = %v, want %v", u, v, distances[u][v], want)
+			}
+			numPairs++
+		}
+	}
+	if numPairs != 2*len(edges) || len(distances[8]) != 0 {
+		t.Fatalf("%d distances for %d edges, row of the isolated node = %v", numPairs,
+			len(edges), distances[8])
+	}
+
+	// -------------------------------------------------------------------------
+	// step 4: the dendrogram has one root per connected component, which are
+	// the clusters after all merges
+	dendrogram, err := cm.WalktrapDendrogram(walkLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := cm.ConnectedComponents(1.0)
+	if dendrogram.N-len(dendrogram.Merges) != len(components) {
+		t.Fatalf("%d roots, want %d", dendrogram.N-len(dendrogram.Merges), len(components))
+	}
+	assertSamePartition(t, dendrogram.cut(len(dendrogram.Merges)), components)
+}
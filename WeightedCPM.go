@@ -0,0 +1,130 @@
+package ConcurrenceBasedClustering
+
+// =============================================================================
+// struct WeightedCPM
+// brief introduction: this is the volume variant of the Constant Potts quality
+//	model, which penalizes each community by the square of its volume, i.e.
+//	the sum of the degrees of its nodes, instead of the square of its size.
+// note:
+//	WeightedCPM = sum_c (w_c - r vol_c^2) with vol_c the sum of
+//	sumConcurrencesOf over c, and w_c as in CPM. So up to a constant, it is 2m
+//	times Modularity with resolution 2m*r, where 2m is sumConcurrences, since
+//	Modularity leaves out the terms of each node with itself: both find the
+//	same communities, but r of WeightedCPM does not depend on the total weight
+//	of the model, like r of CPM.
+type WeightedCPM struct {
+	r float64
+	ConcurrenceModel
+}
+
+func (qm WeightedCPM) GetNeighbors(u int) map[int]float64 {
+	return qm.concurrences[u]
+}
+
+// =============================================================================
+// func NewWeightedCPM
+// brief description: create a new WeightedCPM
+// input:
+//	r: a threshold of WeightedCPM
+func NewWeightedCPM(r float64, cm ConcurrenceModel) WeightedCPM {
+	return WeightedCPM{
+		r:                r,
+		ConcurrenceModel: cm,
+	}
+}
+
+// =============================================================================
+// func (qm WeightedCPM) Aggregate
+// note:
+//	The aggregated model keeps the volumes of the communities as the degrees of
+//	its nodes, so the quality gains are the same on it.
+func (qm WeightedCPM) Aggregate(communities []map[int]bool) QualityModel {
+	return QualityModel(WeightedCPM{qm.r, qm.ConcurrenceModel.Aggregate(communities)})
+}
+
+// =============================================================================
+// func (qm WeightedCPM) Quality
+// brief description: this implements Quality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+// output:
+//	the value of WeightedCPM
+func (qm WeightedCPM) Quality(communities []map[int]bool) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: compute WeightedCPM using the following equation:
+	// WeightedCPM = sum_c (w_c - r vol_c^2),
+	// where:
+	//	c is a community,
+	//	vol_c is the sum of sumConcurrencesOf[i] for all i in c,
+	//	w_c is the sum of weight(i,j) for all i, j in c.
+	result := 0.0
+	for _, c := range communities {
+		volumeC := 0.0
+		sumWeightsOfC := 0.0
+		for i, _ := range c {
+			volumeC += qm.sumConcurrencesOf[i]
+			for j, weightIJ := range qm.concurrences[i] {
+				if i != j && c[j] {
+					sumWeightsOfC += weightIJ * float64(qm.cardinalities[i]*qm.cardinalities[j])
+				}
+			}
+		}
+		result += sumWeightsOfC - qm.r*volumeC*volumeC
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: return the result
+	return result
+}
+
+// =============================================================================
+// func (qm WeightedCPM) DeltaQuality
+// brief description: this implements DeltaQuality for interface QualityModel
+// input:
+//	communities: a list of clusters.
+//	u: a node ID, 0 <= u < n.
+//	oldCu: the ID of the cluster u currently locates in.
+//	newCu: the ID of the cluster u wants to move in.
+// output:
+//	The change amount of WeightedCPM.
+func (qm WeightedCPM) DeltaQuality(communities []map[int]bool, u, oldCu, newCu int) float64 {
+	// -------------------------------------------------------------------------
+	// step 1: check whether oldCu and newCu are the same one.
+	// no change if oldCu == newCu
+	if oldCu == newCu {
+		return 0.0
+	}
+
+	// -------------------------------------------------------------------------
+	// step 2: compute delta WeightedCPM the same way as delta CPM, with the
+	// sizes replaced by the volumes:
+	// delta WeightedCPM = delta w_oldCu + delta w_newCu
+	//	- 2 r * k_u * (vol_newCu - vol_oldCu + k_u),
+	// where k_u is sumConcurrencesOf[u] and vol_oldCu includes k_u.
+	ku := qm.sumConcurrencesOf[u]
+	volumeOldCu := 0.0
+	for j, _ := range communities[oldCu] {
+		volumeOldCu += qm.sumConcurrencesOf[j]
+	}
+	volumeNewCu := 0.0
+	for j, _ := range communities[newCu] {
+		volumeNewCu += qm.sumConcurrencesOf[j]
+	}
+	weightToOld := 0.0
+	weightToNew := 0.0
+	for j, weightUJ := range qm.concurrences[u] {
+		if j == u {
+			continue
+		}
+		weight := weightUJ * float64(qm.cardinalities[u]*qm.cardinalities[j])
+		if communities[oldCu][j] {
+			weightToOld += weight
+		} else if communities[newCu][j] {
+			weightToNew += weight
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// step 3: return the result
+	return 2.0*(weightToNew-weightToOld) - 2.0*qm.r*ku*(volumeNewCu-volumeOldCu+ku)
+}
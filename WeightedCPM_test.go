@@ -0,0 +1,60 @@
+package ConcurrenceBasedClustering
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightedCPMAndCPMOnWeightedGraph(t *testing.T) {
+	// a heavy clique 0-3 of weight 10 and a light clique 4-7 of weight 1
+	edges := append(cliqueEdges(0, 4, 10), cliqueEdges(4, 4, 1)...)
+	cm := newTestModel(t, append(edges, Edge{3, 4, 0.5}))
+	heavy := map[int]bool{0: true, 1: true, 2: true, 3: true}
+	light := map[int]bool{4: true, 5: true, 6: true, 7: true}
+	singletons := func(c map[int]bool) []map[int]bool {
+		result := []map[int]bool{}
+		for u, _ := range c {
+			result = append(result, map[int]bool{u: true})
+		}
+		return result
+	}
+	opts := ClusteringOptions{MaxIters: 100, Seed: 1}
+
+	// -------------------------------------------------------------------------
+	// step 1: a threshold between the two weights makes CPM break the light
+	// clique, while WeightedCPM, which scales with the volumes, breaks the
+	// heavy one at the same resolution relative to the total weight
+	communities, _, err := LouvainWithOptions(NewCPM(2.0, cm), nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, append(singletons(light), heavy))
+	twoM := cm.sumConcurrences
+	communities, _, err = LouvainWithOptions(NewWeightedCPM(2.0/twoM, cm), nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, append(singletons(heavy), light))
+	communities, _, err = LouvainWithOptions(NewWeightedCPM(1.0/twoM, cm), nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePartition(t, communities, []map[int]bool{heavy, light})
+
+	// -------------------------------------------------------------------------
+	// step 2: WeightedCPM differs from 2m times Modularity with resolution 2m*r
+	// by the same constant for all partitions
+	rng := rand.New(rand.NewSource(1))
+	weighted := NewWeightedCPM(2.0/twoM, cm)
+	modularity := NewModularity(2.0, cm)
+	offset := weighted.Quality(communities) - twoM*modularity.Quality(communities)
+	for trial := 0; trial < 20; trial++ {
+		communities := randomCommunities(rng, cm.n, 1+rng.Intn(cm.n))
+		difference := weighted.Quality(communities) - twoM*modularity.Quality(communities)
+		if math.Abs(difference-offset) > 1e-9 {
+			t.Fatalf("%v: WeightedCPM - 2m Modularity = %v, want %v", communities,
+				difference, offset)
+		}
+	}
+}